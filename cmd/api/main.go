@@ -2,20 +2,22 @@ package main
 
 import (
 	"context"
+	"expvar"
 	"log"
+	"os"
 
 	"github.com/go-redis/redis/v8"
 	"go.uber.org/zap"
-	"gorm.io/driver/mysql"
-	"gorm.io/gorm"
-
-	"database/sql"
 
+	"timer-microservice/internal/auth"
+	"timer-microservice/internal/clock"
 	"timer-microservice/internal/config"
 	"timer-microservice/internal/handlers"
-	"timer-microservice/internal/repository"
+	"timer-microservice/internal/lease"
 	"timer-microservice/internal/server"
 	"timer-microservice/internal/service"
+	"timer-microservice/internal/storage"
+	"timer-microservice/internal/validation"
 	"timer-microservice/internal/websocket"
 )
 
@@ -31,47 +33,90 @@ func main() {
 		sugar.Fatalf("Failed to load configuration: %v", err)
 	}
 
-	// Initialize database
-	db, err := sql.Open("mysql", cfg.GetDatabaseDSN())
+	// Manage the database schema instead of serving
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(cfg, sugar, os.Args[2:]); err != nil {
+			sugar.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+	signingKeys, activeKeyID, err := cfg.SigningKeys()
 	if err != nil {
-		sugar.Fatalf("Failed to connect to database: %v", err)
+		sugar.Fatalf("Invalid token signing keys: %v", err)
 	}
-	db.SetConnMaxLifetime(0)
-	db.SetMaxIdleConns(50)
-	db.SetMaxOpenConns(50)
 
-	gormDb, err := gorm.Open(mysql.New(mysql.Config{
-		Conn: db,
-	}), &gorm.Config{})
-
-	// Migrate the schema
-	if err := repository.Migrate(gormDb); err != nil {
-		sugar.Fatalf("Failed to migrate database schema: %v", err)
+	// Open the configured storage backend and apply pending migrations
+	store, err := storage.Open(cfg, sugar)
+	if err != nil {
+		sugar.Fatalf("Failed to open storage: %v", err)
+	}
+	defer store.Close()
+	repo := store.Timers
+	hintRepo := store.Hints
+
+	// Initialize Redis client. Without Redis a single instance runs on
+	// its own.
+	var redisClient *redis.Client
+	if cfg.RedisEnabled() {
+		redisClient = redis.NewClient(&redis.Options{
+			Addr:     cfg.GetRedisAddr(),
+			Password: cfg.RedisPass,
+			DB:       0, // use default DB
+		})
+
+		_, err = redisClient.Ping(context.Background()).Result()
+		if err != nil {
+			sugar.Fatalf("Failed to connect to Redis: %v", err)
+		}
+	} else {
+		sugar.Warnw("Running without Redis: timers are not cached, and events and ticks are not shared with other instances")
 	}
 
-	// Initialize Redis client
-	redisClient := redis.NewClient(&redis.Options{
-		Addr:     cfg.GetRedisAddr(),
-		Password: cfg.RedisPass,
-		DB:       0, // use default DB
-	})
-
-	_, err = redisClient.Ping(context.Background()).Result()
+	clk := clock.New()
+	signer, err := auth.NewHMACSigner(signingKeys, activeKeyID, clk)
 	if err != nil {
-		sugar.Fatalf("Failed to connect to Redis: %v", err)
+		sugar.Fatalf("Failed to initialize token signer: %v", err)
 	}
 
-	// Initialize repository
-	repo := repository.NewTimerRepository(gormDb)
+	validationRules, err := cfg.ValidationRules()
+	if err != nil {
+		sugar.Fatalf("Invalid validation settings: %v", err)
+	}
+	validator := validation.New(validationRules)
 
 	// Initialize WebSocket handler
-	wsHandler := websocket.NewHandler(nil, sugar)
+	wsHandler := websocket.NewHandler(nil, sugar, clk, websocket.Options{
+		SendBufferSize:   cfg.WSSendBuffer,
+		WriteWait:        cfg.WSWriteWait,
+		SlowClientPolicy: websocket.SlowClientPolicy(cfg.WSSlowClientPolicy),
+		PingInterval:     cfg.WSPingInterval,
+		PongWait:         cfg.WSPongWait,
+		ReadLimit:        cfg.WSReadLimit,
+	})
+	expvar.Publish("websocket", expvar.Func(func() interface{} {
+		return wsHandler.Stats()
+	}))
 
 	// Initialize service
-	timerService := service.NewTimerService(repo, sugar, redisClient, wsHandler)
+	timerService := service.NewTimerService(repo, sugar, redisClient, wsHandler, clk)
 
-	// Set the service in WebSocket handler
+	hintService := service.NewHintService(hintRepo, repo, timerService, sugar, wsHandler, clk, cfg.HintPenalty)
+
+	// Set the services in WebSocket handler
 	wsHandler.SetService(timerService)
+	wsHandler.SetHintService(hintService)
+	wsHandler.SetVerifier(signer)
+	wsHandler.SetValidator(validator)
+
+	// Relay broadcasts between the instances sharing Redis
+	if redisClient != nil {
+		wsHandler.SetBroker(websocket.NewRedisBroker(redisClient, cfg.EventsChannel, sugar))
+		go func() {
+			if err := wsHandler.RelayBroadcasts(context.Background()); err != nil {
+				sugar.Errorw("Stopped relaying broadcasts from other instances", "error", err)
+			}
+		}()
+	}
 
 	// Restore timers on startup
 	err = timerService.RestoreTimers()
@@ -79,14 +124,20 @@ func main() {
 		sugar.Errorw("Failed to restore timers", "error", err)
 	}
 
+	// Only the instance holding the lease runs the tick loop
+	if redisClient != nil {
+		timerService.SetTickLease(lease.New(redisClient, "lease:timer-ticks", cfg.TickLeaseTTL))
+	}
 	go timerService.StartTimerUpdates()
 
 	// Initialize handlers
-	timerHandler := handlers.NewTimerHandler(timerService, sugar)
+	timerHandler := handlers.NewTimerHandler(timerService, validator, sugar)
+	hintHandler := handlers.NewHintHandler(hintService, sugar)
+	tokenHandler := handlers.NewTokenHandler(signer, cfg.DisplayTokenTTL, clk, sugar)
 
 	// Initialize and start server
 	srv := server.NewServer(cfg, sugar)
-	srv.SetupRoutes(timerHandler, wsHandler)
+	srv.SetupRoutes(timerHandler, hintHandler, tokenHandler, wsHandler, signer)
 	if err := srv.Start(); err != nil {
 		log.Fatalf("Server failed to start: %v", err)
 	}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"go.uber.org/zap"
+
+	"timer-microservice/internal/config"
+	"timer-microservice/internal/migrations"
+	"timer-microservice/internal/storage"
+)
+
+const migrateUsage = "usage: main migrate [up | down [steps] | status]"
+
+// runMigrate applies, reverts or lists the schema migrations of the
+// configured database without starting the server.
+func runMigrate(cfg *config.Config, sugar *zap.SugaredLogger, args []string) error {
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+	if command != "up" && command != "down" && command != "status" {
+		return fmt.Errorf("unknown migrate command %q\n%s", command, migrateUsage)
+	}
+	steps := 1
+	if command == "down" && len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid number of steps %q\n%s", args[1], migrateUsage)
+		}
+		steps = n
+	}
+
+	db, err := storage.OpenDB(cfg)
+	if err != nil {
+		return err
+	}
+	if sqlDb, err := db.DB(); err == nil {
+		defer sqlDb.Close()
+	}
+	migrator, err := migrations.New(db, sugar)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "up":
+		_, err = migrator.Up()
+	case "down":
+		_, err = migrator.Down(steps)
+	case "status":
+		return printStatus(migrator)
+	}
+	if err != nil {
+		return err
+	}
+
+	version, err := migrator.Version()
+	if err != nil {
+		return err
+	}
+	sugar.Infow("Database schema migrated", "command", command, "version", version)
+	return nil
+}
+
+func printStatus(migrator *migrations.Migrator) error {
+	statuses, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+	for _, status := range statuses {
+		applied := "pending"
+		if status.AppliedAt != nil {
+			applied = status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\n", status.Version, status.Name, applied)
+	}
+	return w.Flush()
+}
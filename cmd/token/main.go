@@ -0,0 +1,55 @@
+// Command token mints an access token signed with the active key, e.g. for
+// a game master console:
+//
+//	go run ./cmd/token -role gamemaster -sessions '*' -ttl 720h
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"timer-microservice/internal/auth"
+	"timer-microservice/internal/clock"
+	"timer-microservice/internal/config"
+)
+
+func main() {
+	role := flag.String("role", string(auth.RoleGameMaster), "token role: gamemaster or customer")
+	sessions := flag.String("sessions", "*", "comma-separated session IDs or glob patterns the token grants")
+	subject := flag.String("subject", "", "who the token is for")
+	venue := flag.String("venue", "", "venue the token is issued for")
+	ttl := flag.Duration("ttl", 24*time.Hour, "how long the token is valid")
+	flag.Parse()
+
+	if *role != string(auth.RoleGameMaster) && *role != string(auth.RoleCustomer) {
+		log.Fatalf("unknown role %q", *role)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	keys, activeKeyID, err := cfg.SigningKeys()
+	if err != nil {
+		log.Fatalf("Invalid token signing keys: %v", err)
+	}
+
+	signer, err := auth.NewHMACSigner(keys, activeKeyID, clock.New())
+	if err != nil {
+		log.Fatalf("Failed to initialize token signer: %v", err)
+	}
+	token, err := signer.Sign(auth.Claims{
+		Subject:  *subject,
+		Role:     auth.Role(*role),
+		Sessions: strings.Split(*sessions, ","),
+		Venue:    *venue,
+	}, *ttl)
+	if err != nil {
+		log.Fatalf("Failed to sign token: %v", err)
+	}
+
+	fmt.Println(token)
+}
@@ -0,0 +1,20 @@
+// Package authtest provides token signers for tests.
+package authtest
+
+import (
+	"testing"
+
+	"timer-microservice/internal/auth"
+	"timer-microservice/internal/clock"
+)
+
+// NewSigner returns an HMACSigner with a single key whose tokens are dated
+// by clk.
+func NewSigner(t testing.TB, clk clock.Clock) *auth.HMACSigner {
+	t.Helper()
+	signer, err := auth.NewHMACSigner(map[string][]byte{"default": []byte("secret")}, "default", clk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"timer-microservice/internal/problem"
+	"timer-microservice/internal/types"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type contextKey struct{}
+
+// WithClaims returns a copy of ctx carrying claims.
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, contextKey{}, claims)
+}
+
+// ClaimsFromContext returns the claims stored by RequireRole, if any.
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(contextKey{}).(*Claims)
+	return claims, ok
+}
+
+// BearerToken returns the token of an "Authorization: Bearer" header.
+func BearerToken(r *http.Request) string {
+	value := r.Header.Get("Authorization")
+	if len(value) > 7 && strings.EqualFold(value[:7], "Bearer ") {
+		return strings.TrimSpace(value[7:])
+	}
+	return ""
+}
+
+// TokenSubprotocolPrefix marks a token offered as a WebSocket subprotocol,
+// e.g. "token.<jwt>". Browsers cannot set headers on WebSocket requests.
+const TokenSubprotocolPrefix = "token."
+
+// WebSocketToken returns the token of a WebSocket upgrade request, taken
+// from the Authorization header, the access_token query parameter or a
+// Sec-WebSocket-Protocol entry, in that order.
+func WebSocketToken(r *http.Request) string {
+	if token := BearerToken(r); token != "" {
+		return token
+	}
+	if token := r.URL.Query().Get("access_token"); token != "" {
+		return token
+	}
+	for _, value := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, protocol := range strings.Split(value, ",") {
+			protocol = strings.TrimSpace(protocol)
+			if strings.HasPrefix(protocol, TokenSubprotocolPrefix) {
+				return strings.TrimPrefix(protocol, TokenSubprotocolPrefix)
+			}
+		}
+	}
+	return ""
+}
+
+// Authenticate verifies the request's bearer token and returns its claims.
+func Authenticate(verifier Verifier, r *http.Request) (*Claims, error) {
+	token := BearerToken(r)
+	if token == "" {
+		return nil, errors.New("missing bearer token")
+	}
+	return verifier.Verify(token)
+}
+
+// RequireRole is a chi middleware that rejects requests without a valid
+// bearer token for one of roles, and stores the token's claims in the
+// request context.
+func RequireRole(verifier Verifier, roles ...Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, err := Authenticate(verifier, r)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="timer"`)
+				problem.Write(w, r, http.StatusUnauthorized, types.ErrCodeUnauthorized, err.Error())
+				return
+			}
+			if !hasRole(claims, roles) {
+				problem.Write(w, r, http.StatusForbidden, types.ErrCodeForbidden, fmt.Sprintf("role %s may not do this", claims.Role))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithClaims(r.Context(), claims)))
+		})
+	}
+}
+
+func hasRole(claims *Claims, roles []Role) bool {
+	for _, role := range roles {
+		if claims.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireSession is a chi middleware that rejects requests whose claims do
+// not grant access to the session named by the urlParam route parameter.
+// It must run after RequireRole.
+func RequireSession(urlParam string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := ClaimsFromContext(r.Context())
+			sessionID := chi.URLParam(r, urlParam)
+			if !ok || !claims.AllowsSession(sessionID) {
+				problem.Write(w, r, http.StatusForbidden, types.ErrCodeForbidden, "token does not grant access to session "+sessionID)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
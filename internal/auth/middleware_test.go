@@ -0,0 +1,113 @@
+package auth_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"timer-microservice/internal/auth"
+	"timer-microservice/internal/auth/authtest"
+	"timer-microservice/internal/clock/clocktest"
+	"timer-microservice/internal/types"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireRole(t *testing.T) {
+	signer := authtest.NewSigner(t, clocktest.NewFake(time.Now()))
+
+	router := chi.NewRouter()
+	router.Use(auth.RequireRole(signer, auth.RoleGameMaster))
+	router.Post("/timer", func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := auth.ClaimsFromContext(r.Context())
+		assert.True(t, ok)
+		assert.Equal(t, "alice", claims.Subject)
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	gameMaster, _ := signer.Sign(auth.Claims{Subject: "alice", Role: auth.RoleGameMaster, Sessions: []string{"*"}}, time.Hour)
+	customer, _ := signer.Sign(auth.Claims{Role: auth.RoleCustomer, Sessions: []string{"room-a"}}, time.Hour)
+
+	tests := []struct {
+		authorization string
+		status        int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer not-a-token", http.StatusUnauthorized},
+		{"Basic " + gameMaster, http.StatusUnauthorized},
+		{"Bearer " + customer, http.StatusForbidden},
+		{"Bearer " + gameMaster, http.StatusCreated},
+		{"bearer " + gameMaster, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/timer", nil)
+		if tt.authorization != "" {
+			r.Header.Set("Authorization", tt.authorization)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		assert.Equal(t, tt.status, w.Code, tt.authorization)
+		if tt.status >= http.StatusBadRequest {
+			assertProblem(t, w, tt.status)
+		}
+	}
+}
+
+func TestRequireSession(t *testing.T) {
+	signer := authtest.NewSigner(t, clocktest.NewFake(time.Now()))
+
+	router := chi.NewRouter()
+	router.Use(auth.RequireRole(signer, auth.RoleCustomer))
+	router.With(auth.RequireSession("sessionID")).Get("/sessions/{sessionID}/hints", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	token, _ := signer.Sign(auth.Claims{Role: auth.RoleCustomer, Sessions: []string{"room-a"}}, time.Hour)
+
+	for path, status := range map[string]int{
+		"/sessions/room-a/hints": http.StatusOK,
+		"/sessions/room-b/hints": http.StatusForbidden,
+	} {
+		r := httptest.NewRequest("GET", path, nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		assert.Equal(t, status, w.Code, path)
+		if status == http.StatusForbidden {
+			assertProblem(t, w, status)
+		}
+	}
+}
+
+// assertProblem checks that w holds a problem details body for status.
+func assertProblem(t *testing.T, w *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	var problem types.Problem
+	if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem)) {
+		assert.Equal(t, status, problem.Status)
+		code := types.ErrCodeForbidden
+		if status == http.StatusUnauthorized {
+			code = types.ErrCodeUnauthorized
+		}
+		assert.Equal(t, code, problem.Code)
+	}
+}
+
+func TestWebSocketToken(t *testing.T) {
+	r := httptest.NewRequest("GET", "/ws/customer/room-a", nil)
+	assert.Equal(t, "", auth.WebSocketToken(r))
+
+	r.Header.Set("Sec-WebSocket-Protocol", "timer.v2, token.from-protocol")
+	assert.Equal(t, "from-protocol", auth.WebSocketToken(r))
+
+	r = httptest.NewRequest("GET", "/ws/customer/room-a?access_token=from-query", nil)
+	r.Header.Set("Sec-WebSocket-Protocol", "timer.v2, token.from-protocol")
+	assert.Equal(t, "from-query", auth.WebSocketToken(r))
+
+	r.Header.Set("Authorization", "Bearer from-header")
+	assert.Equal(t, "from-header", auth.WebSocketToken(r))
+}
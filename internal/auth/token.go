@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"timer-microservice/internal/clock"
+)
+
+type Role string
+
+const (
+	RoleCustomer   Role = "customer"
+	RoleGameMaster Role = "gamemaster"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("token has expired")
+	ErrUnknownKey   = errors.New("unknown signing key")
+)
+
+// Claims are the contents of a token. Sessions lists the session IDs the
+// holder may access; entries may be glob patterns such as "paris-*" to cover
+// a whole venue, and "*" allows every session. Venue names the venue the
+// token was issued for and is informational.
+type Claims struct {
+	Subject   string   `json:"sub,omitempty"`
+	Role      Role     `json:"role"`
+	Sessions  []string `json:"sessions,omitempty"`
+	Venue     string   `json:"venue,omitempty"`
+	IssuedAt  int64    `json:"iat"`
+	ExpiresAt int64    `json:"exp"`
+}
+
+// AllowsSession reports whether the claims grant access to sessionID.
+func (c *Claims) AllowsSession(sessionID string) bool {
+	for _, pattern := range c.Sessions {
+		if ok, _ := path.Match(pattern, sessionID); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsAllSessions reports whether the claims grant every session.
+func (c *Claims) AllowsAllSessions() bool {
+	for _, pattern := range c.Sessions {
+		if pattern == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Verifier checks a token and returns its claims.
+type Verifier interface {
+	Verify(token string) (*Claims, error)
+}
+
+// Signer mints tokens.
+type Signer interface {
+	Sign(claims Claims, ttl time.Duration) (string, error)
+}
+
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+	KeyID     string `json:"kid,omitempty"`
+}
+
+// HMACSigner signs and verifies HS256 JSON Web Tokens with shared secrets.
+// Tokens are signed with the active key and name it in their kid header;
+// any key of the set verifies. To rotate, add a new key and make it active,
+// then drop the old one once the tokens it signed have expired.
+type HMACSigner struct {
+	keys        map[string][]byte
+	activeKeyID string
+	clock       clock.Clock
+}
+
+func NewHMACSigner(keys map[string][]byte, activeKeyID string, clk clock.Clock) (*HMACSigner, error) {
+	for id, secret := range keys {
+		if len(secret) == 0 {
+			return nil, fmt.Errorf("signing key %q is empty", id)
+		}
+	}
+	if _, ok := keys[activeKeyID]; !ok {
+		return nil, fmt.Errorf("active signing key %q: %w", activeKeyID, ErrUnknownKey)
+	}
+	return &HMACSigner{keys: keys, activeKeyID: activeKeyID, clock: clk}, nil
+}
+
+// Sign returns a token for claims valid for ttl. IssuedAt and ExpiresAt are
+// set from the clock.
+func (s *HMACSigner) Sign(claims Claims, ttl time.Duration) (string, error) {
+	now := s.clock.Now()
+	claims.IssuedAt = now.Unix()
+	claims.ExpiresAt = now.Add(ttl).Unix()
+
+	headerJSON, err := json.Marshal(header{Algorithm: "HS256", Type: "JWT", KeyID: s.activeKeyID})
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := encodeSegment(headerJSON) + "." + encodeSegment(claimsJSON)
+	return signingInput + "." + encodeSegment(signature(s.keys[s.activeKeyID], signingInput)), nil
+}
+
+// Verify checks the token's signature with the key named by its kid header,
+// or with the active key for tokens without one.
+func (s *HMACSigner) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	var h header
+	if err := decodeJSONSegment(parts[0], &h); err != nil || h.Algorithm != "HS256" {
+		return nil, ErrInvalidToken
+	}
+
+	keyID := h.KeyID
+	if keyID == "" {
+		keyID = s.activeKeyID
+	}
+	secret, ok := s.keys[keyID]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	sig, err := decodeSegment(parts[2])
+	if err != nil || !hmac.Equal(sig, signature(secret, parts[0]+"."+parts[1])) {
+		return nil, ErrInvalidToken
+	}
+
+	var claims Claims
+	if err := decodeJSONSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if s.clock.Now().Unix() >= claims.ExpiresAt {
+		return nil, ErrExpiredToken
+	}
+	return &claims, nil
+}
+
+func signature(secret []byte, signingInput string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signingInput))
+	return mac.Sum(nil)
+}
+
+func encodeSegment(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeSegment(segment string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(segment)
+}
+
+func decodeJSONSegment(segment string, v interface{}) error {
+	data, err := decodeSegment(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
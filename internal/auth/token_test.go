@@ -0,0 +1,120 @@
+package auth_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"timer-microservice/internal/auth"
+	"timer-microservice/internal/auth/authtest"
+	"timer-microservice/internal/clock/clocktest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	signer := authtest.NewSigner(t, clk)
+
+	token, err := signer.Sign(auth.Claims{
+		Subject:  "alice",
+		Role:     auth.RoleGameMaster,
+		Sessions: []string{"paris-*"},
+		Venue:    "paris",
+	}, time.Hour)
+	assert.NoError(t, err)
+
+	claims, err := signer.Verify(token)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "alice", claims.Subject)
+		assert.Equal(t, auth.RoleGameMaster, claims.Role)
+		assert.Equal(t, "paris", claims.Venue)
+		assert.Equal(t, clk.Now().Unix(), claims.IssuedAt)
+		assert.Equal(t, clk.Now().Add(time.Hour).Unix(), claims.ExpiresAt)
+	}
+
+	clk.Advance(time.Hour)
+	_, err = signer.Verify(token)
+	assert.ErrorIs(t, err, auth.ErrExpiredToken)
+}
+
+func TestVerifyRejectsForgedTokens(t *testing.T) {
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	signer := authtest.NewSigner(t, clk)
+
+	token, err := signer.Sign(auth.Claims{Role: auth.RoleCustomer, Sessions: []string{"room-a"}}, time.Hour)
+	assert.NoError(t, err)
+
+	other, err := auth.NewHMACSigner(map[string][]byte{"default": []byte("other")}, "default", clk)
+	assert.NoError(t, err)
+	_, err = other.Verify(token)
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+
+	// Swapping the claims for a game master's keeps the old signature.
+	forged, err := signer.Sign(auth.Claims{Role: auth.RoleGameMaster, Sessions: []string{"*"}}, time.Hour)
+	assert.NoError(t, err)
+	parts := strings.Split(token, ".")
+	forgedParts := strings.Split(forged, ".")
+	_, err = signer.Verify(parts[0] + "." + forgedParts[1] + "." + parts[2])
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+
+	for _, token := range []string{"", "a.b", "a.b.c", "...."} {
+		_, err = signer.Verify(token)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken, token)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	claims := auth.Claims{Role: auth.RoleCustomer, Sessions: []string{"room-a"}}
+
+	old, err := auth.NewHMACSigner(map[string][]byte{"2024a": []byte("old")}, "2024a", clk)
+	assert.NoError(t, err)
+	oldToken, err := old.Sign(claims, time.Hour)
+	assert.NoError(t, err)
+
+	// The new key signs while the old one still verifies.
+	rotated, err := auth.NewHMACSigner(map[string][]byte{
+		"2024a": []byte("old"),
+		"2024b": []byte("new"),
+	}, "2024b", clk)
+	assert.NoError(t, err)
+	newToken, err := rotated.Sign(claims, time.Hour)
+	assert.NoError(t, err)
+
+	_, err = rotated.Verify(oldToken)
+	assert.NoError(t, err)
+	_, err = rotated.Verify(newToken)
+	assert.NoError(t, err)
+	_, err = old.Verify(newToken)
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+
+	// Once the old key is dropped its tokens stop working.
+	retired, err := auth.NewHMACSigner(map[string][]byte{"2024b": []byte("new")}, "2024b", clk)
+	assert.NoError(t, err)
+	_, err = retired.Verify(oldToken)
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	_, err = retired.Verify(newToken)
+	assert.NoError(t, err)
+}
+
+func TestNewHMACSignerValidatesKeys(t *testing.T) {
+	clk := clocktest.NewFake(time.Now())
+
+	_, err := auth.NewHMACSigner(map[string][]byte{"a": []byte("secret")}, "b", clk)
+	assert.ErrorIs(t, err, auth.ErrUnknownKey)
+
+	_, err = auth.NewHMACSigner(map[string][]byte{"a": []byte("secret"), "b": nil}, "a", clk)
+	assert.Error(t, err)
+}
+
+func TestAllowsSession(t *testing.T) {
+	claims := &auth.Claims{Sessions: []string{"room-a", "paris-*"}}
+	assert.True(t, claims.AllowsSession("room-a"))
+	assert.True(t, claims.AllowsSession("paris-escape-1"))
+	assert.False(t, claims.AllowsSession("room-b"))
+	assert.False(t, claims.AllowsSession("lyon-escape-1"))
+
+	assert.True(t, (&auth.Claims{Sessions: []string{"*"}}).AllowsSession("anything"))
+	assert.False(t, (&auth.Claims{}).AllowsSession("room-a"))
+}
@@ -0,0 +1,179 @@
+// Package clocktest provides a manually driven clock.Clock for tests.
+package clocktest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"timer-microservice/internal/clock"
+)
+
+// Fake is a clock.Clock whose time only moves when Advance is called.
+//
+// Unlike time.Ticker, a fake ticker never drops ticks: Advance blocks until
+// every tick that falls inside the advanced window has been received (or the
+// ticker is stopped), so a test can run an hour of game time and observe
+// every tick.
+type Fake struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	tickers []*fakeTicker
+	waiters []*waiter
+}
+
+type waiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+var _ clock.Clock = (*Fake)(nil)
+
+// NewFake returns a Fake clock set to now.
+func NewFake(now time.Time) *Fake {
+	f := &Fake{now: now}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) NewTicker(d time.Duration) clock.Ticker {
+	if d <= 0 {
+		panic("clocktest: non-positive interval for NewTicker")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t := &fakeTicker{
+		clock:    f,
+		interval: d,
+		next:     f.now.Add(d),
+		ch:       make(chan time.Time),
+		stopped:  make(chan struct{}),
+	}
+	f.tickers = append(f.tickers, t)
+	f.cond.Broadcast()
+	return t
+}
+
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w := &waiter{at: f.now.Add(d), ch: make(chan time.Time, 1)}
+	if d <= 0 {
+		w.ch <- f.now
+		return w.ch
+	}
+	f.waiters = append(f.waiters, w)
+	f.cond.Broadcast()
+	return w.ch
+}
+
+// BlockUntil waits until at least n tickers and After waiters are pending.
+// Use it to make sure the code under test has set up its ticker before
+// calling Advance.
+func (f *Fake) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.tickers)+len(f.waiters) < n {
+		f.cond.Wait()
+	}
+}
+
+// Advance moves the clock forward by d, firing every tick and After waiter
+// that becomes due, in chronological order.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	end := f.now.Add(d)
+	f.mu.Unlock()
+
+	for {
+		f.mu.Lock()
+		at, fire := f.nextEvent(end)
+		if fire == nil {
+			f.now = end
+			f.mu.Unlock()
+			return
+		}
+		f.now = at
+		f.mu.Unlock()
+
+		fire()
+	}
+}
+
+// nextEvent returns the earliest event due at or before end. It must be
+// called with f.mu held.
+func (f *Fake) nextEvent(end time.Time) (time.Time, func()) {
+	sort.SliceStable(f.waiters, func(i, j int) bool {
+		return f.waiters[i].at.Before(f.waiters[j].at)
+	})
+
+	var next *fakeTicker
+	for _, t := range f.tickers {
+		if next == nil || t.next.Before(next.next) {
+			next = t
+		}
+	}
+
+	if len(f.waiters) > 0 && !f.waiters[0].at.After(end) &&
+		(next == nil || !f.waiters[0].at.After(next.next)) {
+		w := f.waiters[0]
+		f.waiters = f.waiters[1:]
+		return w.at, func() { w.ch <- w.at }
+	}
+
+	if next != nil && !next.next.After(end) {
+		at := next.next
+		next.next = at.Add(next.interval)
+		return at, func() { next.deliver(at) }
+	}
+
+	return time.Time{}, nil
+}
+
+func (f *Fake) removeTicker(t *fakeTicker) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, other := range f.tickers {
+		if other == t {
+			f.tickers = append(f.tickers[:i], f.tickers[i+1:]...)
+			return
+		}
+	}
+}
+
+type fakeTicker struct {
+	clock    *Fake
+	interval time.Duration
+	next     time.Time
+	ch       chan time.Time
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *fakeTicker) Stop() {
+	t.stopOnce.Do(func() {
+		close(t.stopped)
+		t.clock.removeTicker(t)
+	})
+}
+
+func (t *fakeTicker) deliver(at time.Time) {
+	select {
+	case t.ch <- at:
+	case <-t.stopped:
+	}
+}
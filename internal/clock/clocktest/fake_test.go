@@ -0,0 +1,66 @@
+package clocktest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeTickerDeliversEveryTick(t *testing.T) {
+	start := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	clk := NewFake(start)
+	ticker := clk.NewTicker(time.Second)
+
+	received := make(chan []time.Time)
+	go func() {
+		var ticks []time.Time
+		for i := 0; i < 3600; i++ {
+			ticks = append(ticks, <-ticker.C())
+		}
+		received <- ticks
+	}()
+
+	clk.Advance(time.Hour)
+	ticks := <-received
+	ticker.Stop()
+
+	assert.Len(t, ticks, 3600)
+	assert.Equal(t, start.Add(time.Second), ticks[0])
+	assert.Equal(t, start.Add(time.Hour), ticks[len(ticks)-1])
+	assert.Equal(t, start.Add(time.Hour), clk.Now())
+}
+
+func TestFakeAfter(t *testing.T) {
+	start := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	clk := NewFake(start)
+	ch := clk.After(10 * time.Second)
+
+	clk.Advance(9 * time.Second)
+	select {
+	case <-ch:
+		t.Fatal("After fired early")
+	default:
+	}
+
+	clk.Advance(time.Second)
+	assert.Equal(t, start.Add(10*time.Second), <-ch)
+}
+
+func TestStoppedTickerDoesNotBlockAdvance(t *testing.T) {
+	clk := NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	ticker := clk.NewTicker(time.Second)
+	ticker.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		clk.Advance(time.Minute)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Advance blocked on a stopped ticker")
+	}
+}
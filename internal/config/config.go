@@ -2,6 +2,13 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"timer-microservice/internal/validation"
 
 	"github.com/spf13/viper"
 )
@@ -15,9 +22,65 @@ type Config struct {
 	DBUsername string `mapstructure:"DB_USERNAME"`
 	DBPassword string `mapstructure:"DB_PASSWORD"`
 	DBRootPass string `mapstructure:"DB_ROOT_PASSWORD"`
-	RedisHost  string `mapstructure:"REDIS_HOST"`
-	RedisPort  string `mapstructure:"REDIS_PORT"`
-	RedisPass  string `mapstructure:"REDIS_PASSWORD"`
+
+	// DBSSLMode is the sslmode of PostgreSQL connections, "disable" unless
+	// set.
+	DBSSLMode string `mapstructure:"DB_SSLMODE"`
+
+	// StorageDriver selects where timers and hints are kept: "mysql", the
+	// default, or "postgres" in the database of the DB_ settings, "sqlite"
+	// in the file SQLitePath, or "memory", which loses them when the
+	// process exits.
+	StorageDriver string `mapstructure:"STORAGE_DRIVER"`
+	SQLitePath    string `mapstructure:"SQLITE_PATH"`
+
+	// Redis is optional for a single instance: leave RedisHost empty to run
+	// without the timer cache and without sharing events and ticks.
+	RedisHost string `mapstructure:"REDIS_HOST"`
+	RedisPort string `mapstructure:"REDIS_PORT"`
+	RedisPass string `mapstructure:"REDIS_PASSWORD"`
+
+	// EventsChannel is the Redis pub/sub channel the instances relay
+	// timer events on. Instances sharing it serve the same clients.
+	EventsChannel string `mapstructure:"REDIS_EVENTS_CHANNEL"`
+
+	// TickLeaseTTL is how long the instance running the tick loop keeps
+	// the lease on it without renewing. Another instance takes over the
+	// ticks within this time when it dies. Zero means 3s.
+	TickLeaseTTL time.Duration `mapstructure:"TICK_LEASE_TTL"`
+
+	// HintPenalty is the number of seconds a hint costs unless the game
+	// master overrides it. Zero means hints are free.
+	HintPenalty int64 `mapstructure:"HINT_PENALTY_SECONDS"`
+
+	// AuthSecret signs and verifies the access tokens of game masters and
+	// room displays. AuthKeys replaces it with a set of named keys for
+	// rotation, as "id:secret,id:secret"; AuthActiveKey names the key new
+	// tokens are signed with and defaults to the first one.
+	AuthSecret    string `mapstructure:"AUTH_SECRET"`
+	AuthKeys      string `mapstructure:"AUTH_KEYS"`
+	AuthActiveKey string `mapstructure:"AUTH_ACTIVE_KEY"`
+
+	// DisplayTokenTTL is how long the tokens minted for room displays stay
+	// valid.
+	DisplayTokenTTL time.Duration `mapstructure:"DISPLAY_TOKEN_TTL"`
+
+	// Limits of timer requests. Zero values fall back to the validator's
+	// defaults: 1s to 24h, and session IDs of up to 64 letters, digits,
+	// dots, dashes and underscores.
+	TimerMinDuration   time.Duration `mapstructure:"TIMER_MIN_DURATION"`
+	TimerMaxDuration   time.Duration `mapstructure:"TIMER_MAX_DURATION"`
+	SessionIDMaxLength int           `mapstructure:"SESSION_ID_MAX_LENGTH"`
+	SessionIDPattern   string        `mapstructure:"SESSION_ID_PATTERN"`
+
+	// WebSocket write pump and heartbeat settings. Zero values fall back
+	// to the handler's defaults.
+	WSSendBuffer       int           `mapstructure:"WS_SEND_BUFFER"`
+	WSWriteWait        time.Duration `mapstructure:"WS_WRITE_WAIT"`
+	WSSlowClientPolicy string        `mapstructure:"WS_SLOW_CLIENT_POLICY"`
+	WSPingInterval     time.Duration `mapstructure:"WS_PING_INTERVAL"`
+	WSPongWait         time.Duration `mapstructure:"WS_PONG_WAIT"`
+	WSReadLimit        int64         `mapstructure:"WS_READ_LIMIT"`
 }
 
 func Load() (*Config, error) {
@@ -38,12 +101,81 @@ func Load() (*Config, error) {
 	return &config, nil
 }
 
-// Helper method to get DatabaseDSN
+// SigningKeys returns the token signing keys by ID and the ID of the active
+// key. Without AUTH_KEYS, AUTH_SECRET is the only key, with ID "default".
+func (c *Config) SigningKeys() (map[string][]byte, string, error) {
+	if c.AuthKeys == "" {
+		if c.AuthSecret == "" {
+			return nil, "", fmt.Errorf("AUTH_SECRET or AUTH_KEYS must be set")
+		}
+		return map[string][]byte{"default": []byte(c.AuthSecret)}, "default", nil
+	}
+
+	keys := make(map[string][]byte)
+	active := c.AuthActiveKey
+	for _, entry := range strings.Split(c.AuthKeys, ",") {
+		id, secret, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || id == "" || secret == "" {
+			return nil, "", fmt.Errorf("invalid AUTH_KEYS entry %q, want id:secret", entry)
+		}
+		keys[id] = []byte(secret)
+		if active == "" {
+			active = id
+		}
+	}
+	if _, ok := keys[active]; !ok {
+		return nil, "", fmt.Errorf("AUTH_ACTIVE_KEY %q is not in AUTH_KEYS", active)
+	}
+	return keys, active, nil
+}
+
+// ValidationRules returns the limits timer requests are checked against.
+func (c *Config) ValidationRules() (validation.Rules, error) {
+	rules := validation.Rules{
+		MinDuration:        c.TimerMinDuration,
+		MaxDuration:        c.TimerMaxDuration,
+		SessionIDMaxLength: c.SessionIDMaxLength,
+	}
+	if c.TimerMaxDuration > 0 && c.TimerMaxDuration < c.TimerMinDuration {
+		return rules, fmt.Errorf("TIMER_MAX_DURATION %s is below TIMER_MIN_DURATION %s", c.TimerMaxDuration, c.TimerMinDuration)
+	}
+	if c.SessionIDPattern != "" {
+		pattern, err := regexp.Compile(c.SessionIDPattern)
+		if err != nil {
+			return rules, fmt.Errorf("invalid SESSION_ID_PATTERN: %w", err)
+		}
+		rules.SessionIDPattern = pattern
+	}
+	return rules, nil
+}
+
+// GetDatabaseDSN returns the DSN of the database of the DB_ settings, in
+// the syntax of StorageDriver.
 func (c *Config) GetDatabaseDSN() string {
+	if c.StorageDriver == "postgres" {
+		sslMode := c.DBSSLMode
+		if sslMode == "" {
+			sslMode = "disable"
+		}
+		dsn := url.URL{
+			Scheme:   "postgres",
+			User:     url.UserPassword(c.DBUsername, c.DBPassword),
+			Host:     net.JoinHostPort(c.DBHost, c.DBPort),
+			Path:     "/" + c.DBName,
+			RawQuery: url.Values{"sslmode": {sslMode}}.Encode(),
+		}
+		return dsn.String()
+	}
+
 	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true",
 		c.DBUsername, c.DBPassword, c.DBHost, c.DBPort, c.DBName)
 }
 
+// RedisEnabled reports whether a Redis server is configured.
+func (c *Config) RedisEnabled() bool {
+	return c.RedisHost != ""
+}
+
 // Helper method to get Redis address
 func (c *Config) GetRedisAddr() string {
 	return fmt.Sprintf("%s:%s", c.RedisHost, c.RedisPort)
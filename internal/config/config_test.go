@@ -0,0 +1,64 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSigningKeys(t *testing.T) {
+	keys, active, err := (&Config{AuthSecret: "secret"}).SigningKeys()
+	assert.NoError(t, err)
+	assert.Equal(t, "default", active)
+	assert.Equal(t, map[string][]byte{"default": []byte("secret")}, keys)
+
+	keys, active, err = (&Config{AuthSecret: "ignored", AuthKeys: "2024a:old, 2024b:new"}).SigningKeys()
+	assert.NoError(t, err)
+	assert.Equal(t, "2024a", active)
+	assert.Equal(t, map[string][]byte{"2024a": []byte("old"), "2024b": []byte("new")}, keys)
+
+	_, active, err = (&Config{AuthKeys: "2024a:old,2024b:new", AuthActiveKey: "2024b"}).SigningKeys()
+	assert.NoError(t, err)
+	assert.Equal(t, "2024b", active)
+
+	for _, cfg := range []Config{
+		{},
+		{AuthKeys: "2024a"},
+		{AuthKeys: "2024a:"},
+		{AuthKeys: "2024a:old", AuthActiveKey: "2024b"},
+	} {
+		_, _, err := cfg.SigningKeys()
+		assert.Error(t, err, cfg.AuthKeys)
+	}
+}
+
+func TestValidationRules(t *testing.T) {
+	rules, err := (&Config{TimerMinDuration: time.Minute, TimerMaxDuration: 4 * time.Hour, SessionIDPattern: `^room-[0-9]+$`}).ValidationRules()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Minute, rules.MinDuration)
+	assert.Equal(t, 4*time.Hour, rules.MaxDuration)
+	assert.True(t, rules.SessionIDPattern.MatchString("room-12"))
+
+	rules, err = (&Config{}).ValidationRules()
+	assert.NoError(t, err)
+	assert.Nil(t, rules.SessionIDPattern)
+
+	_, err = (&Config{TimerMinDuration: time.Hour, TimerMaxDuration: time.Minute}).ValidationRules()
+	assert.Error(t, err)
+
+	_, err = (&Config{SessionIDPattern: "["}).ValidationRules()
+	assert.Error(t, err)
+}
+
+func TestGetDatabaseDSN(t *testing.T) {
+	cfg := Config{DBHost: "db", DBPort: "3306", DBName: "timerdb", DBUsername: "timer", DBPassword: "p@ss word"}
+	assert.Equal(t, "timer:p@ss word@tcp(db:3306)/timerdb?parseTime=true", cfg.GetDatabaseDSN())
+
+	cfg.StorageDriver = "postgres"
+	cfg.DBPort = "5432"
+	assert.Equal(t, "postgres://timer:p%40ss%20word@db:5432/timerdb?sslmode=disable", cfg.GetDatabaseDSN())
+
+	cfg.DBSSLMode = "require"
+	assert.Equal(t, "postgres://timer:p%40ss%20word@db:5432/timerdb?sslmode=require", cfg.GetDatabaseDSN())
+}
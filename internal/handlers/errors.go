@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+
+	"timer-microservice/internal/problem"
+	"timer-microservice/internal/service"
+	"timer-microservice/internal/types"
+	"timer-microservice/internal/validation"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/zap"
+)
+
+func badRequest(w http.ResponseWriter, r *http.Request, detail string) {
+	problem.Write(w, r, http.StatusBadRequest, types.ErrCodeInvalidArgument, detail)
+}
+
+func forbidden(w http.ResponseWriter, r *http.Request, detail string) {
+	problem.Write(w, r, http.StatusForbidden, types.ErrCodeForbidden, detail)
+}
+
+// writeServiceError reports err with the status of its kind, listing the
+// invalid fields of rejected input. Internal errors are logged as msg and
+// only msg is shown to the client.
+func writeServiceError(w http.ResponseWriter, r *http.Request, logger *zap.SugaredLogger, err error, msg string, keysAndValues ...interface{}) {
+	keysAndValues = append(keysAndValues, "error", err, "requestID", middleware.GetReqID(r.Context()))
+
+	var status int
+	var code types.ErrorCode
+	switch service.KindOf(err) {
+	case service.KindNotFound:
+		status, code = http.StatusNotFound, types.ErrCodeNotFound
+	case service.KindInvalidArgument:
+		status, code = http.StatusBadRequest, types.ErrCodeInvalidArgument
+	case service.KindConflict:
+		status, code = http.StatusConflict, types.ErrCodeConflict
+	case service.KindInvalidState:
+		status, code = http.StatusUnprocessableEntity, types.ErrCodeInvalidState
+	case service.KindPreconditionFailed:
+		status, code = http.StatusPreconditionFailed, types.ErrCodePreconditionFailed
+	default:
+		logger.Errorw(msg, keysAndValues...)
+		problem.Write(w, r, http.StatusInternalServerError, types.ErrCodeInternal, msg)
+		return
+	}
+
+	logger.Warnw(msg, keysAndValues...)
+	problem.Write(w, r, status, code, err.Error(), validation.FieldErrors(err)...)
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"timer-microservice/internal/problem"
+	"timer-microservice/internal/types"
+)
+
+// setETag tags the response with the timer's version, for clients to send
+// back in If-Match.
+func setETag(w http.ResponseWriter, timer *types.Timer) {
+	w.Header().Set("ETag", fmt.Sprintf(`"%d"`, timer.Version))
+}
+
+// ifMatch returns the version named by the request's If-Match header, or
+// zero when the request is unconditional. Only the strong ETags set by
+// setETag can match; anything else fails the precondition.
+func ifMatch(r *http.Request) (uint, bool) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" || value == "*" {
+		return 0, true
+	}
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return 0, false
+	}
+	version, err := strconv.ParseUint(value[1:len(value)-1], 10, 32)
+	if err != nil || version == 0 {
+		return 0, false
+	}
+	return uint(version), true
+}
+
+func preconditionFailed(w http.ResponseWriter, r *http.Request) {
+	problem.Write(w, r, http.StatusPreconditionFailed, types.ErrCodePreconditionFailed, "If-Match does not name a version of this timer")
+}
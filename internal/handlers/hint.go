@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"timer-microservice/internal/service"
+	"timer-microservice/internal/types"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+type HintHandler struct {
+	service service.HintServiceInterface
+	logger  *zap.SugaredLogger
+}
+
+func NewHintHandler(service service.HintServiceInterface, logger *zap.SugaredLogger) *HintHandler {
+	return &HintHandler{service: service, logger: logger}
+}
+
+func (h *HintHandler) ListHints(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionID")
+
+	hints, err := h.service.GetHints(sessionID)
+	if err != nil {
+		writeServiceError(w, r, h.logger, err, "Failed to list hints", "sessionID", sessionID)
+		return
+	}
+
+	response := make([]types.HintResponse, 0, len(hints))
+	for i := range hints {
+		response = append(response, types.NewHintResponse(&hints[i]))
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
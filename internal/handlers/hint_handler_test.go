@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"timer-microservice/internal/types"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+// MockHintService is a mock of HintServiceInterface
+type MockHintService struct {
+	mock.Mock
+}
+
+func (m *MockHintService) SendHint(sessionID string, req types.HintRequest) (*types.Hint, error) {
+	args := m.Called(sessionID, req)
+	return args.Get(0).(*types.Hint), args.Error(1)
+}
+
+func (m *MockHintService) GetHints(sessionID string) ([]types.Hint, error) {
+	args := m.Called(sessionID)
+	return args.Get(0).([]types.Hint), args.Error(1)
+}
+
+func TestListHints(t *testing.T) {
+	mockService := new(MockHintService)
+	logger, _ := zap.NewDevelopment()
+	sugar := logger.Sugar()
+
+	handler := NewHintHandler(mockService, sugar)
+
+	hints := []types.Hint{
+		{ID: 1, SessionID: "test-session", TimerID: 1, Text: "Look under the rug", Penalty: 120},
+		{ID: 2, SessionID: "test-session", TimerID: 1, Text: "Count the candles"},
+	}
+
+	mockService.On("GetHints", "test-session").Return(hints, nil)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/sessions/test-session/hints", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("sessionID", "test-session")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+
+	handler.ListHints(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response []types.HintResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Len(t, response, 2)
+	assert.Equal(t, "Look under the rug", response[0].Text)
+	assert.Equal(t, int64(120), response[0].Penalty)
+
+	mockService.AssertExpectations(t)
+}
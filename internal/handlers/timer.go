@@ -2,120 +2,310 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
 
+	"timer-microservice/internal/auth"
 	"timer-microservice/internal/service"
 	"timer-microservice/internal/types"
+	"timer-microservice/internal/validation"
 
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 )
 
 type TimerHandler struct {
-	service service.TimerServiceInterface
-	logger  *zap.SugaredLogger
+	service   service.TimerServiceInterface
+	validator *validation.Validator
+	logger    *zap.SugaredLogger
 }
 
-func NewTimerHandler(service service.TimerServiceInterface, logger *zap.SugaredLogger) *TimerHandler {
-	return &TimerHandler{service: service, logger: logger}
+func NewTimerHandler(service service.TimerServiceInterface, validator *validation.Validator, logger *zap.SugaredLogger) *TimerHandler {
+	return &TimerHandler{service: service, validator: validator, logger: logger}
 }
 
 func (h *TimerHandler) CreateTimer(w http.ResponseWriter, r *http.Request) {
 	var req types.TimerRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.logger.Errorw("Failed to decode request body", "error", err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := validation.Decode(r.Body, &req); err != nil {
+		writeServiceError(w, r, h.logger, err, "Invalid request body")
+		return
+	}
+	if err := h.validator.TimerRequest(req); err != nil {
+		writeServiceError(w, r, h.logger, err, "Invalid timer request", "sessionID", req.SessionID)
+		return
+	}
+
+	if claims, ok := auth.ClaimsFromContext(r.Context()); ok && !claims.AllowsSession(req.SessionID) {
+		h.logger.Warnw("Rejected timer for session outside token scope", "sessionID", req.SessionID, "subject", claims.Subject)
+		forbidden(w, r, fmt.Sprintf("token does not grant session %q", req.SessionID))
 		return
 	}
 
-	timer, err := h.service.CreateTimer(req.SessionID, req.MaxTime)
+	timer, replayed, err := h.createTimer(r.Header.Get("Idempotency-Key"), req)
 	if err != nil {
-		h.logger.Errorw("Failed to create timer", "error", err)
-		http.Error(w, "Failed to create timer", http.StatusInternalServerError)
+		writeServiceError(w, r, h.logger, err, "Failed to create timer")
 		return
 	}
 
+	if replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
+	setETag(w, timer)
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(timer)
+	json.NewEncoder(w).Encode(types.NewTimerResponse(timer))
+}
+
+// createTimer creates the timer of req, at most once per idempotency key
+// when the client sent one.
+func (h *TimerHandler) createTimer(key string, req types.TimerRequest) (*types.Timer, bool, error) {
+	if key == "" {
+		timer, err := h.service.CreateTimer(req)
+		return timer, false, err
+	}
+	if err := h.validator.IdempotencyKey("Idempotency-Key", key); err != nil {
+		return nil, false, err
+	}
+	return h.service.CreateTimerIdempotent(key, req)
 }
 
 func (h *TimerHandler) PauseTimer(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
 	if err != nil {
 		h.logger.Errorw("Invalid timer ID", "error", err)
-		http.Error(w, "Invalid timer ID", http.StatusBadRequest)
+		badRequest(w, r, "Invalid timer ID")
+		return
+	}
+	if !h.authorizeTimer(w, r, uint(id)) {
 		return
 	}
 
-	timer, err := h.service.PauseTimer(uint(id))
+	version, ok := ifMatch(r)
+	if !ok {
+		preconditionFailed(w, r)
+		return
+	}
+
+	timer, err := h.service.PauseTimer(uint(id), version)
 	if err != nil {
-		h.logger.Errorw("Failed to pause timer", "error", err, "id", id)
-		http.Error(w, "Failed to pause timer", http.StatusInternalServerError)
+		writeServiceError(w, r, h.logger, err, "Failed to pause timer", "id", id)
 		return
 	}
 
-	json.NewEncoder(w).Encode(timer)
+	setETag(w, timer)
+	json.NewEncoder(w).Encode(types.NewTimerResponse(timer))
 }
 
 func (h *TimerHandler) ResumeTimer(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
 	if err != nil {
 		h.logger.Errorw("Invalid timer ID", "error", err)
-		http.Error(w, "Invalid timer ID", http.StatusBadRequest)
+		badRequest(w, r, "Invalid timer ID")
+		return
+	}
+	if !h.authorizeTimer(w, r, uint(id)) {
 		return
 	}
 
-	timer, err := h.service.ResumeTimer(uint(id))
+	version, ok := ifMatch(r)
+	if !ok {
+		preconditionFailed(w, r)
+		return
+	}
+
+	timer, err := h.service.ResumeTimer(uint(id), version)
 	if err != nil {
-		h.logger.Errorw("Failed to resume timer", "error", err, "id", id)
-		http.Error(w, "Failed to resume timer", http.StatusInternalServerError)
+		writeServiceError(w, r, h.logger, err, "Failed to resume timer", "id", id)
 		return
 	}
 
-	json.NewEncoder(w).Encode(timer)
+	setETag(w, timer)
+	json.NewEncoder(w).Encode(types.NewTimerResponse(timer))
 }
 
 func (h *TimerHandler) StopTimer(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
 	if err != nil {
 		h.logger.Errorw("Invalid timer ID", "error", err)
-		http.Error(w, "Invalid timer ID", http.StatusBadRequest)
+		badRequest(w, r, "Invalid timer ID")
+		return
+	}
+	if !h.authorizeTimer(w, r, uint(id)) {
+		return
+	}
+
+	version, ok := ifMatch(r)
+	if !ok {
+		preconditionFailed(w, r)
 		return
 	}
 
-	err = h.service.StopTimer(uint(id))
+	timer, err := h.service.StopTimer(uint(id), version)
 	if err != nil {
-		h.logger.Errorw("Failed to stop timer", "error", err, "id", id)
-		http.Error(w, "Failed to stop timer", http.StatusInternalServerError)
+		writeServiceError(w, r, h.logger, err, "Failed to stop timer", "id", id)
 		return
 	}
 
+	setETag(w, timer)
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Timer stopped and deleted"})
+	json.NewEncoder(w).Encode(map[string]string{"message": "Timer stopped"})
 }
 
 func (h *TimerHandler) ModifyTimer(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
 	if err != nil {
 		h.logger.Errorw("Invalid timer ID", "error", err)
-		http.Error(w, "Invalid timer ID", http.StatusBadRequest)
+		badRequest(w, r, "Invalid timer ID")
+		return
+	}
+	if !h.authorizeTimer(w, r, uint(id)) {
 		return
 	}
 
-	var req types.TimerRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.logger.Errorw("Failed to decode request body", "error", err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	version, ok := ifMatch(r)
+	if !ok {
+		preconditionFailed(w, r)
+		return
+	}
+
+	var req types.TimerModifyRequest
+	if err := validation.Decode(r.Body, &req); err != nil {
+		writeServiceError(w, r, h.logger, err, "Invalid request body")
+		return
+	}
+	if err := h.validator.MaxTime(req.MaxTime); err != nil {
+		writeServiceError(w, r, h.logger, err, "Invalid timer modification", "id", id)
+		return
+	}
+
+	timer, err := h.service.ModifyTimer(uint(id), version, req.MaxTime)
+	if err != nil {
+		writeServiceError(w, r, h.logger, err, "Failed to modify timer", "id", id)
+		return
+	}
+
+	setETag(w, timer)
+	json.NewEncoder(w).Encode(types.NewTimerResponse(timer))
+}
+
+func (h *TimerHandler) AdjustTimer(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		h.logger.Errorw("Invalid timer ID", "error", err)
+		badRequest(w, r, "Invalid timer ID")
+		return
+	}
+	if !h.authorizeTimer(w, r, uint(id)) {
+		return
+	}
+
+	version, ok := ifMatch(r)
+	if !ok {
+		preconditionFailed(w, r)
+		return
+	}
+
+	var req types.TimerAdjustRequest
+	if err := validation.Decode(r.Body, &req); err != nil {
+		writeServiceError(w, r, h.logger, err, "Invalid request body")
+		return
+	}
+	if err := h.validator.Adjustment(req); err != nil {
+		writeServiceError(w, r, h.logger, err, "Invalid timer adjustment", "id", id)
+		return
+	}
+
+	timer, err := h.service.AdjustTimer(uint(id), version, req.Delta, req.Reason)
+	if err != nil {
+		writeServiceError(w, r, h.logger, err, "Failed to adjust timer", "id", id)
+		return
+	}
+
+	setETag(w, timer)
+	json.NewEncoder(w).Encode(types.NewTimerResponse(timer))
+}
+
+// authorizeTimer loads timer id and checks that the request's token grants
+// its session. When it does not, or the timer cannot be loaded, it writes
+// the error response and returns false.
+func (h *TimerHandler) authorizeTimer(w http.ResponseWriter, r *http.Request, id uint) bool {
+	claims, ok := auth.ClaimsFromContext(r.Context())
+	if !ok || claims.AllowsAllSessions() {
+		return true
+	}
+
+	timer, err := h.service.GetTimer(id)
+	if err != nil {
+		writeServiceError(w, r, h.logger, err, "Failed to get timer", "id", id)
+		return false
+	}
+	if !claims.AllowsSession(timer.SessionID) {
+		h.logger.Warnw("Rejected command on timer outside token scope", "id", id, "sessionID", timer.SessionID, "subject", claims.Subject)
+		forbidden(w, r, fmt.Sprintf("token does not grant session %q", timer.SessionID))
+		return false
+	}
+	return true
+}
+
+// GetTimer returns a timer with its current time.
+func (h *TimerHandler) GetTimer(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		h.logger.Errorw("Invalid timer ID", "error", err)
+		badRequest(w, r, "Invalid timer ID")
 		return
 	}
 
-	timer, err := h.service.ModifyTimer(uint(id), req.MaxTime)
+	timer, err := h.service.GetTimer(uint(id))
 	if err != nil {
-		h.logger.Errorw("Failed to modify timer", "error", err, "id", id)
-		http.Error(w, "Failed to modify timer", http.StatusInternalServerError)
+		writeServiceError(w, r, h.logger, err, "Failed to get timer", "id", id)
+		return
+	}
+
+	if claims, ok := auth.ClaimsFromContext(r.Context()); ok && !claims.AllowsSession(timer.SessionID) {
+		forbidden(w, r, fmt.Sprintf("token does not grant session %q", timer.SessionID))
 		return
 	}
 
-	json.NewEncoder(w).Encode(timer)
+	setETag(w, timer)
+	json.NewEncoder(w).Encode(types.NewTimerResponse(timer))
+}
+
+// ListTimers returns a page of timers, filtered and sorted by the query
+// parameters described in parseTimerQuery.
+func (h *TimerHandler) ListTimers(w http.ResponseWriter, r *http.Request) {
+	query, err := parseTimerQuery(r.URL.Query())
+	if err != nil {
+		h.logger.Warnw("Invalid timer list query", "error", err)
+		badRequest(w, r, err.Error())
+		return
+	}
+
+	// Tokens scoped to some sessions may only list one of them.
+	if claims, ok := auth.ClaimsFromContext(r.Context()); ok && !claims.AllowsAllSessions() {
+		if query.SessionID == "" {
+			forbidden(w, r, "sessionId is required for tokens scoped to some sessions")
+			return
+		}
+		if !claims.AllowsSession(query.SessionID) {
+			forbidden(w, r, fmt.Sprintf("token does not grant session %q", query.SessionID))
+			return
+		}
+	}
+
+	timers, next, err := h.service.ListTimers(query)
+	if err != nil {
+		writeServiceError(w, r, h.logger, err, "Failed to list timers")
+		return
+	}
+
+	response := types.TimerListResponse{Timers: make([]types.TimerResponse, 0, len(timers))}
+	for i := range timers {
+		response.Timers = append(response.Timers, types.NewTimerResponse(&timers[i]))
+	}
+	if next != nil {
+		response.NextCursor = encodeCursor(*next, query.Sort)
+	}
+
+	json.NewEncoder(w).Encode(response)
 }
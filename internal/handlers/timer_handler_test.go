@@ -4,13 +4,22 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"timer-microservice/internal/auth"
+	"timer-microservice/internal/lease"
+	"timer-microservice/internal/repository"
+	"timer-microservice/internal/service"
 	"timer-microservice/internal/types"
+	"timer-microservice/internal/validation"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"go.uber.org/zap"
@@ -29,28 +38,43 @@ func (m *MockTimerService) StopTimerUpdates() {
 	m.Called()
 }
 
-func (m *MockTimerService) CreateTimer(sessionID string, maxTime int64) (*types.Timer, error) {
-	args := m.Called(sessionID, maxTime)
+func (m *MockTimerService) CreateTimer(req types.TimerRequest) (*types.Timer, error) {
+	args := m.Called(req)
 	return args.Get(0).(*types.Timer), args.Error(1)
 }
 
-func (m *MockTimerService) PauseTimer(id uint) (*types.Timer, error) {
-	args := m.Called(id)
+func (m *MockTimerService) CreateTimerIdempotent(key string, req types.TimerRequest) (*types.Timer, bool, error) {
+	args := m.Called(key, req)
+	return args.Get(0).(*types.Timer), args.Bool(1), args.Error(2)
+}
+
+func (m *MockTimerService) PauseTimer(id, ifMatch uint) (*types.Timer, error) {
+	args := m.Called(id, ifMatch)
 	return args.Get(0).(*types.Timer), args.Error(1)
 }
 
-func (m *MockTimerService) ResumeTimer(id uint) (*types.Timer, error) {
-	args := m.Called(id)
+func (m *MockTimerService) ResumeTimer(id, ifMatch uint) (*types.Timer, error) {
+	args := m.Called(id, ifMatch)
 	return args.Get(0).(*types.Timer), args.Error(1)
 }
 
-func (m *MockTimerService) StopTimer(id uint) error {
-	args := m.Called(id)
-	return args.Error(0)
+func (m *MockTimerService) StopTimer(id, ifMatch uint) (*types.Timer, error) {
+	args := m.Called(id, ifMatch)
+	return args.Get(0).(*types.Timer), args.Error(1)
 }
 
-func (m *MockTimerService) ModifyTimer(id uint, newMaxTime int64) (*types.Timer, error) {
-	args := m.Called(id, newMaxTime)
+func (m *MockTimerService) ModifyTimer(id, ifMatch uint, newMaxTime int64) (*types.Timer, error) {
+	args := m.Called(id, ifMatch, newMaxTime)
+	return args.Get(0).(*types.Timer), args.Error(1)
+}
+
+func (m *MockTimerService) AdjustTimer(id, ifMatch uint, delta int64, reason string) (*types.Timer, error) {
+	args := m.Called(id, ifMatch, delta, reason)
+	return args.Get(0).(*types.Timer), args.Error(1)
+}
+
+func (m *MockTimerService) RecordHint(id uint, penalty int64) (*types.Timer, error) {
+	args := m.Called(id, penalty)
 	return args.Get(0).(*types.Timer), args.Error(1)
 }
 
@@ -59,6 +83,29 @@ func (m *MockTimerService) GetAllTimers() ([]types.Timer, error) {
 	return args.Get(0).([]types.Timer), args.Error(1)
 }
 
+func (m *MockTimerService) GetLiveTimers(sessionID string) ([]types.Timer, error) {
+	args := m.Called(sessionID)
+	return args.Get(0).([]types.Timer), args.Error(1)
+}
+
+func (m *MockTimerService) GetTimer(id uint) (*types.Timer, error) {
+	args := m.Called(id)
+	if timer, ok := args.Get(0).(*types.Timer); ok {
+		return timer, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockTimerService) ListTimers(query repository.TimerQuery) ([]types.Timer, *repository.TimerCursor, error) {
+	args := m.Called(query)
+	next, _ := args.Get(1).(*repository.TimerCursor)
+	return args.Get(0).([]types.Timer), next, args.Error(2)
+}
+
+func (m *MockTimerService) SetTickLease(tickLease *lease.Lease) {
+	m.Called(tickLease)
+}
+
 func (m *MockTimerService) RestoreTimers() error {
 	args := m.Called()
 	return args.Error(0)
@@ -69,7 +116,7 @@ func TestCreateTimer(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
 	sugar := logger.Sugar()
 
-	handler := NewTimerHandler(mockService, sugar)
+	handler := NewTimerHandler(mockService, validation.New(validation.DefaultRules()), sugar)
 
 	req := types.TimerRequest{
 		SessionID: "test-session",
@@ -84,7 +131,7 @@ func TestCreateTimer(t *testing.T) {
 		IsPaused:    false,
 	}
 
-	mockService.On("CreateTimer", req.SessionID, req.MaxTime).Return(expectedTimer, nil)
+	mockService.On("CreateTimer", req).Return(expectedTimer, nil)
 
 	body, _ := json.Marshal(req)
 	w := httptest.NewRecorder()
@@ -106,12 +153,254 @@ func TestCreateTimer(t *testing.T) {
 	mockService.AssertExpectations(t)
 }
 
+func TestCreateTimerRejectsInvalidRequest(t *testing.T) {
+	mockService := new(MockTimerService)
+	handler := NewTimerHandler(mockService, validation.New(validation.DefaultRules()), zap.NewNop().Sugar())
+
+	tests := []struct {
+		body   string
+		fields []types.FieldError
+	}{
+		{`{"sessionId":"","maxTime":-1}`, []types.FieldError{
+			{Field: "sessionId", Message: "is required"},
+			{Field: "maxTime", Message: "must be at least 1 second"},
+		}},
+		{`{"sessionId":"room-1","maxTime":60,"duration":60}`, []types.FieldError{
+			{Field: "duration", Message: "unknown field"},
+		}},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handler.CreateTimer(w, httptest.NewRequest("POST", "/timer", bytes.NewBufferString(tt.body)))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, tt.body)
+		var problem types.Problem
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&problem))
+		assert.Equal(t, types.ErrCodeInvalidArgument, problem.Code)
+		assert.Equal(t, tt.fields, problem.Errors)
+	}
+
+	mockService.AssertNotCalled(t, "CreateTimer", mock.Anything)
+}
+
+func TestModifyTimerRejectsInvalidRequest(t *testing.T) {
+	mockService := new(MockTimerService)
+	handler := NewTimerHandler(mockService, validation.New(validation.DefaultRules()), zap.NewNop().Sugar())
+
+	tests := []struct {
+		body   string
+		fields []types.FieldError
+	}{
+		{`{"maxTime":100000000}`, []types.FieldError{
+			{Field: "maxTime", Message: "must be at most 86400 seconds"},
+		}},
+		// Only the soft cap can be modified; the other create fields are
+		// rejected rather than ignored.
+		{`{"sessionId":"room-1","maxTime":60}`, []types.FieldError{
+			{Field: "sessionId", Message: "unknown field"},
+		}},
+		{`{"maxTime":60,"mode":"countup"}`, []types.FieldError{
+			{Field: "mode", Message: "unknown field"},
+		}},
+		{`{"maxTime":60,"allowOvertime":true}`, []types.FieldError{
+			{Field: "allowOvertime", Message: "unknown field"},
+		}},
+	}
+
+	for _, tt := range tests {
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", "1")
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("PUT", "/timer/1/modify", bytes.NewBufferString(tt.body))
+		r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+
+		handler.ModifyTimer(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, tt.body)
+		var problem types.Problem
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&problem))
+		assert.Equal(t, tt.fields, problem.Errors, tt.body)
+	}
+
+	mockService.AssertNotCalled(t, "ModifyTimer", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAdjustTimerRejectsLongReason(t *testing.T) {
+	mockService := new(MockTimerService)
+	handler := NewTimerHandler(mockService, validation.New(validation.DefaultRules()), zap.NewNop().Sugar())
+
+	body, _ := json.Marshal(types.TimerAdjustRequest{Delta: -120, Reason: strings.Repeat("x", 256)})
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "1")
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("PUT", "/timer/1/adjust", bytes.NewBuffer(body))
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+
+	handler.AdjustTimer(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var problem types.Problem
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&problem))
+	assert.Equal(t, []types.FieldError{{Field: "reason", Message: "must be at most 255 characters"}}, problem.Errors)
+	mockService.AssertNotCalled(t, "AdjustTimer", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCreateTimerWithIdempotencyKey(t *testing.T) {
+	mockService := new(MockTimerService)
+	handler := NewTimerHandler(mockService, validation.New(validation.DefaultRules()), zap.NewNop().Sugar())
+
+	req := types.TimerRequest{SessionID: "room-a", MaxTime: 60}
+	timer := &types.Timer{ID: 1, SessionID: "room-a", MaxTime: 60, CurrentTime: 60}
+	mockService.On("CreateTimerIdempotent", "booking-42", req).Return(timer, false, nil).Once()
+	mockService.On("CreateTimerIdempotent", "booking-42", req).Return(timer, true, nil).Once()
+
+	post := func() *httptest.ResponseRecorder {
+		body, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/timer", bytes.NewBuffer(body))
+		r.Header.Set("Idempotency-Key", "booking-42")
+		handler.CreateTimer(w, r)
+		return w
+	}
+
+	first := post()
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get("Idempotent-Replayed"))
+
+	retry := post()
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+	assert.JSONEq(t, first.Body.String(), retry.Body.String())
+
+	mockService.On("CreateTimerIdempotent", "booking-42", mock.Anything).
+		Return((*types.Timer)(nil), false, &service.Error{Kind: service.KindConflict, Message: "idempotency key was already used"}).Once()
+	body, _ := json.Marshal(types.TimerRequest{SessionID: "room-a", MaxTime: 90})
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/timer", bytes.NewBuffer(body))
+	r.Header.Set("Idempotency-Key", "booking-42")
+	handler.CreateTimer(w, r)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", "/timer", bytes.NewBufferString(`{"sessionId":"room-a","maxTime":60}`))
+	r.Header.Set("Idempotency-Key", strings.Repeat("k", 256))
+	handler.CreateTimer(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mockService.AssertExpectations(t)
+	mockService.AssertNotCalled(t, "CreateTimer", mock.Anything)
+}
+
+func TestCreateTimerWithOvertime(t *testing.T) {
+	mockService := new(MockTimerService)
+	logger, _ := zap.NewDevelopment()
+	sugar := logger.Sugar()
+
+	handler := NewTimerHandler(mockService, validation.New(validation.DefaultRules()), sugar)
+
+	req := types.TimerRequest{
+		SessionID:     "test-session",
+		MaxTime:       60,
+		AllowOvertime: true,
+	}
+
+	expectedTimer := &types.Timer{
+		ID:            1,
+		SessionID:     "test-session",
+		MaxTime:       60,
+		CurrentTime:   -12,
+		State:         types.StateRunning,
+		Mode:          types.ModeCountdown,
+		AllowOvertime: true,
+		Overtime:      12,
+	}
+
+	mockService.On("CreateTimer", req).Return(expectedTimer, nil)
+
+	body, _ := json.Marshal(req)
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/timer", bytes.NewBuffer(body))
+
+	handler.CreateTimer(w, r)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response types.TimerResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.True(t, response.AllowOvertime)
+	assert.Equal(t, int64(12), response.Overtime)
+	assert.Equal(t, int64(-12), response.CurrentTime)
+	assert.Equal(t, types.StateRunning, response.State)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestCreateTimerOutsideTokenScope(t *testing.T) {
+	mockService := new(MockTimerService)
+	logger, _ := zap.NewDevelopment()
+	sugar := logger.Sugar()
+
+	handler := NewTimerHandler(mockService, validation.New(validation.DefaultRules()), sugar)
+
+	body, _ := json.Marshal(types.TimerRequest{SessionID: "lyon-1", MaxTime: 60})
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/timer", bytes.NewBuffer(body))
+	claims := &auth.Claims{Role: auth.RoleGameMaster, Sessions: []string{"paris-*"}}
+	r = r.WithContext(auth.WithClaims(r.Context(), claims))
+
+	handler.CreateTimer(w, r)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockService.AssertNotCalled(t, "CreateTimer", mock.Anything)
+}
+
+func TestTimerCommandsOutsideTokenScope(t *testing.T) {
+	mockService := new(MockTimerService)
+	logger, _ := zap.NewDevelopment()
+	sugar := logger.Sugar()
+
+	handler := NewTimerHandler(mockService, validation.New(validation.DefaultRules()), sugar)
+
+	mockService.On("GetTimer", uint(1)).Return(&types.Timer{ID: 1, SessionID: "lyon-1", MaxTime: 60, Version: 1}, nil)
+
+	tests := []struct {
+		command string
+		handle  http.HandlerFunc
+		body    string
+	}{
+		{"PauseTimer", handler.PauseTimer, ""},
+		{"ResumeTimer", handler.ResumeTimer, ""},
+		{"StopTimer", handler.StopTimer, ""},
+		{"ModifyTimer", handler.ModifyTimer, `{"maxTime": 120}`},
+		{"AdjustTimer", handler.AdjustTimer, `{"delta": -60, "reason": "hint"}`},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("PUT", "/timer/1", strings.NewReader(tt.body))
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", "1")
+		claims := &auth.Claims{Role: auth.RoleGameMaster, Sessions: []string{"paris-*"}}
+		r = r.WithContext(context.WithValue(auth.WithClaims(r.Context(), claims), chi.RouteCtxKey, rctx))
+
+		tt.handle(w, r)
+
+		assert.Equal(t, http.StatusForbidden, w.Code, tt.command)
+	}
+
+	// The timer was loaded to check its session, and left alone.
+	for _, call := range mockService.Calls {
+		assert.Equal(t, "GetTimer", call.Method)
+	}
+}
+
 func TestPauseTimer(t *testing.T) {
 	mockService := new(MockTimerService)
 	logger, _ := zap.NewDevelopment()
 	sugar := logger.Sugar()
 
-	handler := NewTimerHandler(mockService, sugar)
+	handler := NewTimerHandler(mockService, validation.New(validation.DefaultRules()), sugar)
 
 	timerID := uint(1)
 	expectedTimer := &types.Timer{
@@ -122,7 +411,7 @@ func TestPauseTimer(t *testing.T) {
 		IsPaused:    true,
 	}
 
-	mockService.On("PauseTimer", timerID).Return(expectedTimer, nil)
+	mockService.On("PauseTimer", timerID, uint(0)).Return(expectedTimer, nil)
 
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest("PUT", "/timer/1/pause", nil)
@@ -151,7 +440,7 @@ func TestResumeTimer(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
 	sugar := logger.Sugar()
 
-	handler := NewTimerHandler(mockService, sugar)
+	handler := NewTimerHandler(mockService, validation.New(validation.DefaultRules()), sugar)
 
 	timerID := uint(1)
 	expectedTimer := &types.Timer{
@@ -162,7 +451,7 @@ func TestResumeTimer(t *testing.T) {
 		IsPaused:    false,
 	}
 
-	mockService.On("ResumeTimer", timerID).Return(expectedTimer, nil)
+	mockService.On("ResumeTimer", timerID, uint(0)).Return(expectedTimer, nil)
 
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest("PUT", "/timer/1/resume", nil)
@@ -191,11 +480,11 @@ func TestStopTimer(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
 	sugar := logger.Sugar()
 
-	handler := NewTimerHandler(mockService, sugar)
+	handler := NewTimerHandler(mockService, validation.New(validation.DefaultRules()), sugar)
 
 	timerID := uint(1)
 
-	mockService.On("StopTimer", timerID).Return(nil)
+	mockService.On("StopTimer", timerID, uint(0)).Return(&types.Timer{ID: timerID, State: types.StateStopped, Version: 3}, nil)
 
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest("PUT", "/timer/1/stop", nil)
@@ -210,7 +499,8 @@ func TestStopTimer(t *testing.T) {
 	var response map[string]string
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
-	assert.Equal(t, "Timer stopped and deleted", response["message"])
+	assert.Equal(t, "Timer stopped", response["message"])
+	assert.Equal(t, `"3"`, w.Header().Get("ETag"))
 
 	mockService.AssertExpectations(t)
 }
@@ -220,7 +510,7 @@ func TestModifyTimer(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
 	sugar := logger.Sugar()
 
-	handler := NewTimerHandler(mockService, sugar)
+	handler := NewTimerHandler(mockService, validation.New(validation.DefaultRules()), sugar)
 
 	timerID := uint(1)
 	newMaxTime := int64(90)
@@ -232,9 +522,9 @@ func TestModifyTimer(t *testing.T) {
 		IsPaused:    false,
 	}
 
-	mockService.On("ModifyTimer", timerID, newMaxTime).Return(expectedTimer, nil)
+	mockService.On("ModifyTimer", timerID, uint(0), newMaxTime).Return(expectedTimer, nil)
 
-	req := types.TimerRequest{
+	req := types.TimerModifyRequest{
 		MaxTime: newMaxTime,
 	}
 	body, _ := json.Marshal(req)
@@ -260,3 +550,252 @@ func TestModifyTimer(t *testing.T) {
 
 	mockService.AssertExpectations(t)
 }
+
+func TestAdjustTimer(t *testing.T) {
+	mockService := new(MockTimerService)
+	logger, _ := zap.NewDevelopment()
+	sugar := logger.Sugar()
+
+	handler := NewTimerHandler(mockService, validation.New(validation.DefaultRules()), sugar)
+
+	timerID := uint(1)
+	expectedTimer := &types.Timer{
+		ID:          timerID,
+		SessionID:   "test-session",
+		MaxTime:     3600,
+		CurrentTime: 1080,
+		Adjustments: []types.TimerAdjustment{{Delta: -120, Reason: "hint"}},
+	}
+
+	mockService.On("AdjustTimer", timerID, uint(0), int64(-120), "hint").Return(expectedTimer, nil)
+
+	body, _ := json.Marshal(types.TimerAdjustRequest{Delta: -120, Reason: "hint"})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("PUT", "/timer/1/adjust", bytes.NewBuffer(body))
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "1")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+
+	handler.AdjustTimer(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response types.TimerResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedTimer.CurrentTime, response.CurrentTime)
+	assert.Equal(t, expectedTimer.MaxTime, response.MaxTime)
+	assert.Len(t, response.Adjustments, 1)
+	assert.Equal(t, "hint", response.Adjustments[0].Reason)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestGetTimer(t *testing.T) {
+	mockService := new(MockTimerService)
+	logger, _ := zap.NewDevelopment()
+	sugar := logger.Sugar()
+
+	handler := NewTimerHandler(mockService, validation.New(validation.DefaultRules()), sugar)
+
+	timer := &types.Timer{ID: 1, SessionID: "paris-1", MaxTime: 60, CurrentTime: 42, State: types.StateRunning}
+	mockService.On("GetTimer", uint(1)).Return(timer, nil)
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "1")
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/timer/1", nil)
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+
+	handler.GetTimer(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response types.TimerResponse
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Equal(t, int64(42), response.CurrentTime)
+
+	// A token for another venue may not read the timer.
+	claims := &auth.Claims{Role: auth.RoleGameMaster, Sessions: []string{"lyon-*"}}
+	w = httptest.NewRecorder()
+	handler.GetTimer(w, r.WithContext(auth.WithClaims(r.Context(), claims)))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestListTimers(t *testing.T) {
+	mockService := new(MockTimerService)
+	logger, _ := zap.NewDevelopment()
+	sugar := logger.Sugar()
+
+	handler := NewTimerHandler(mockService, validation.New(validation.DefaultRules()), sugar)
+
+	createdAt := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	next := &repository.TimerCursor{CreatedAt: createdAt, ID: 7}
+	mockService.On("ListTimers", repository.TimerQuery{
+		SessionID: "paris-1",
+		States:    []types.TimerState{types.StateRunning, types.StatePaused},
+		Sort:      repository.SortCreatedAtDesc,
+		Limit:     1,
+	}).Return([]types.Timer{{ID: 7, SessionID: "paris-1", State: types.StateRunning, CreatedAt: createdAt}}, next, nil).Once()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/timers?sessionId=paris-1&state=running,paused&limit=1", nil)
+	handler.ListTimers(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response types.TimerListResponse
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Len(t, response.Timers, 1)
+	assert.NotEmpty(t, response.NextCursor)
+
+	// The cursor resumes after the last timer of the previous page.
+	mockService.On("ListTimers", repository.TimerQuery{
+		SessionID: "paris-1",
+		Sort:      repository.SortCreatedAtDesc,
+		After:     next,
+		Limit:     50,
+	}).Return([]types.Timer{}, nil, nil).Once()
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/timers?sessionId=paris-1&cursor="+response.NextCursor, nil)
+	handler.ListTimers(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"timers":[]}`, w.Body.String())
+
+	// A cursor is only valid for the sort it was issued for.
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/timers?sort=id&cursor="+response.NextCursor, nil)
+	handler.ListTimers(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestListTimersRejectsBadQuery(t *testing.T) {
+	mockService := new(MockTimerService)
+	logger, _ := zap.NewDevelopment()
+	sugar := logger.Sugar()
+
+	handler := NewTimerHandler(mockService, validation.New(validation.DefaultRules()), sugar)
+
+	for _, query := range []string{
+		"state=finished",
+		"paused=maybe",
+		"createdFrom=yesterday",
+		"sort=name",
+		"limit=0",
+		"limit=500",
+		"cursor=!!!",
+	} {
+		w := httptest.NewRecorder()
+		handler.ListTimers(w, httptest.NewRequest("GET", "/timers?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+
+	mockService.AssertNotCalled(t, "ListTimers", mock.Anything)
+}
+
+func TestListTimersRequiresSessionForScopedToken(t *testing.T) {
+	mockService := new(MockTimerService)
+	logger, _ := zap.NewDevelopment()
+	sugar := logger.Sugar()
+
+	handler := NewTimerHandler(mockService, validation.New(validation.DefaultRules()), sugar)
+
+	claims := &auth.Claims{Role: auth.RoleGameMaster, Sessions: []string{"paris-*"}}
+	for _, query := range []string{"", "sessionId=lyon-1"} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/timers?"+query, nil)
+		handler.ListTimers(w, r.WithContext(auth.WithClaims(r.Context(), claims)))
+		assert.Equal(t, http.StatusForbidden, w.Code, query)
+	}
+
+	mockService.AssertNotCalled(t, "ListTimers", mock.Anything)
+}
+
+func TestServiceErrorsAreProblems(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   types.ErrorCode
+		detail string
+	}{
+		{"not found", &service.Error{Kind: service.KindNotFound, Message: "timer 9 not found"}, http.StatusNotFound, types.ErrCodeNotFound, "timer 9 not found"},
+		{"invalid argument", &service.Error{Kind: service.KindInvalidArgument, Message: "maxTime must not be negative, got -1"}, http.StatusBadRequest, types.ErrCodeInvalidArgument, "maxTime must not be negative, got -1"},
+		{"conflict", &service.Error{Kind: service.KindConflict, Message: "conflict"}, http.StatusConflict, types.ErrCodeConflict, "conflict"},
+		{"precondition failed", &service.Error{Kind: service.KindPreconditionFailed, Message: "stale"}, http.StatusPreconditionFailed, types.ErrCodePreconditionFailed, "stale"},
+		{"invalid state", &service.InvalidStateError{TimerID: 9, State: types.StateExpired, Action: "pause"}, http.StatusUnprocessableEntity, types.ErrCodeInvalidState, "cannot pause timer 9: timer is expired"},
+		// Unexpected errors are not echoed back.
+		{"internal", errors.New("connection refused"), http.StatusInternalServerError, types.ErrCodeInternal, "Failed to pause timer"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockTimerService)
+			handler := NewTimerHandler(mockService, validation.New(validation.DefaultRules()), zap.NewNop().Sugar())
+
+			mockService.On("PauseTimer", uint(9), uint(0)).Return((*types.Timer)(nil), tt.err)
+
+			router := chi.NewRouter()
+			router.Use(middleware.RequestID)
+			router.Put("/timer/{id}/pause", handler.PauseTimer)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("PUT", "/timer/9/pause", nil)
+			r.Header.Set(middleware.RequestIDHeader, "req-42")
+			router.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+
+			var problem types.Problem
+			assert.NoError(t, json.NewDecoder(w.Body).Decode(&problem))
+			assert.Equal(t, types.Problem{
+				Type:      "about:blank",
+				Title:     http.StatusText(tt.status),
+				Status:    tt.status,
+				Detail:    tt.detail,
+				Instance:  "/timer/9/pause",
+				Code:      tt.code,
+				RequestID: "req-42",
+			}, problem)
+		})
+	}
+}
+
+func TestPauseTimerWithIfMatch(t *testing.T) {
+	mockService := new(MockTimerService)
+	handler := NewTimerHandler(mockService, validation.New(validation.DefaultRules()), zap.NewNop().Sugar())
+
+	mockService.On("PauseTimer", uint(1), uint(4)).Return(&types.Timer{ID: 1, IsPaused: true, Version: 5}, nil)
+
+	router := chi.NewRouter()
+	router.Put("/timer/{id}/pause", handler.PauseTimer)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("PUT", "/timer/1/pause", nil)
+	r.Header.Set("If-Match", `"4"`)
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"5"`, w.Header().Get("ETag"))
+
+	var response types.TimerResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, uint(5), response.Version)
+
+	// A weak or malformed tag can never match.
+	for _, value := range []string{`W/"4"`, "4", `"abc"`} {
+		w = httptest.NewRecorder()
+		r = httptest.NewRequest("PUT", "/timer/1/pause", nil)
+		r.Header.Set("If-Match", value)
+		router.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code, value)
+	}
+
+	mockService.AssertNumberOfCalls(t, "PauseTimer", 1)
+}
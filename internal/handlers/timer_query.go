@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"timer-microservice/internal/repository"
+	"timer-microservice/internal/types"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
+)
+
+// cursor is the opaque pagination token handed to clients. It records the
+// sort it was made for so it cannot be replayed against another order.
+type cursor struct {
+	repository.TimerCursor
+	Sort repository.TimerSort `json:"sort"`
+}
+
+func encodeCursor(next repository.TimerCursor, sort repository.TimerSort) string {
+	data, _ := json.Marshal(cursor{TimerCursor: next, Sort: sort})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value string, sort repository.TimerSort) (*repository.TimerCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	if c.Sort != sort {
+		return nil, fmt.Errorf("cursor was issued for sort %q", c.Sort)
+	}
+	return &c.TimerCursor, nil
+}
+
+// parseTimerQuery reads the list parameters:
+//
+//	sessionId    only timers of this session
+//	state        comma-separated states, e.g. running,paused
+//	paused       true or false
+//	createdFrom  RFC 3339 time, inclusive
+//	createdTo    RFC 3339 time, exclusive
+//	sort         createdAt, -createdAt (default), id or -id
+//	limit        page size, 1 to 200, default 50
+//	cursor       nextCursor of the previous page
+func parseTimerQuery(values url.Values) (repository.TimerQuery, error) {
+	query := repository.TimerQuery{
+		SessionID: values.Get("sessionId"),
+		Sort:      repository.SortCreatedAtDesc,
+		Limit:     defaultListLimit,
+	}
+
+	if states := values.Get("state"); states != "" {
+		for _, state := range strings.Split(states, ",") {
+			state := types.TimerState(strings.TrimSpace(state))
+			switch state {
+			case types.StateCreated, types.StateRunning, types.StatePaused, types.StateExpired, types.StateStopped:
+				query.States = append(query.States, state)
+			default:
+				return query, fmt.Errorf("unknown state %q", state)
+			}
+		}
+	}
+
+	if paused := values.Get("paused"); paused != "" {
+		value, err := strconv.ParseBool(paused)
+		if err != nil {
+			return query, fmt.Errorf("paused must be true or false")
+		}
+		query.Paused = &value
+	}
+
+	for name, target := range map[string]*time.Time{
+		"createdFrom": &query.CreatedFrom,
+		"createdTo":   &query.CreatedTo,
+	} {
+		if value := values.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return query, fmt.Errorf("%s must be an RFC 3339 time", name)
+			}
+			*target = t
+		}
+	}
+
+	if sort := values.Get("sort"); sort != "" {
+		switch repository.TimerSort(sort) {
+		case repository.SortCreatedAtDesc, repository.SortCreatedAtAsc, repository.SortIDDesc, repository.SortIDAsc:
+			query.Sort = repository.TimerSort(sort)
+		default:
+			return query, fmt.Errorf("unknown sort %q", sort)
+		}
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil || value < 1 || value > maxListLimit {
+			return query, fmt.Errorf("limit must be between 1 and %d", maxListLimit)
+		}
+		query.Limit = value
+	}
+
+	if value := values.Get("cursor"); value != "" {
+		after, err := decodeCursor(value, query.Sort)
+		if err != nil {
+			return query, err
+		}
+		query.After = after
+	}
+
+	return query, nil
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"timer-microservice/internal/auth"
+	"timer-microservice/internal/clock"
+	"timer-microservice/internal/problem"
+	"timer-microservice/internal/types"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+const defaultDisplayTokenTTL = 12 * time.Hour
+
+type TokenHandler struct {
+	signer auth.Signer
+	ttl    time.Duration
+	clock  clock.Clock
+	logger *zap.SugaredLogger
+}
+
+// NewTokenHandler returns a handler minting display tokens valid for ttl,
+// or for 12 hours when ttl is zero.
+func NewTokenHandler(signer auth.Signer, ttl time.Duration, clk clock.Clock, logger *zap.SugaredLogger) *TokenHandler {
+	if ttl <= 0 {
+		ttl = defaultDisplayTokenTTL
+	}
+	return &TokenHandler{signer: signer, ttl: ttl, clock: clk, logger: logger}
+}
+
+// CreateDisplayToken mints a customer token scoped to exactly one session,
+// for a room display to open /ws/customer/{sessionID}.
+func (h *TokenHandler) CreateDisplayToken(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionID")
+
+	claims := auth.Claims{
+		Subject:  "display",
+		Role:     auth.RoleCustomer,
+		Sessions: []string{sessionID},
+	}
+	if issuer, ok := auth.ClaimsFromContext(r.Context()); ok {
+		claims.Venue = issuer.Venue
+	}
+
+	expiresAt := h.clock.Now().Add(h.ttl)
+	token, err := h.signer.Sign(claims, h.ttl)
+	if err != nil {
+		h.logger.Errorw("Failed to sign display token", "error", err, "sessionID", sessionID)
+		problem.Write(w, r, http.StatusInternalServerError, types.ErrCodeInternal, "Failed to create token")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(types.TokenResponse{
+		Token:     token,
+		SessionID: sessionID,
+		ExpiresAt: expiresAt.UTC().Truncate(time.Second),
+	})
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"timer-microservice/internal/auth"
+	"timer-microservice/internal/auth/authtest"
+	"timer-microservice/internal/clock/clocktest"
+	"timer-microservice/internal/types"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestCreateDisplayToken(t *testing.T) {
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	signer := authtest.NewSigner(t, clk)
+
+	handler := NewTokenHandler(signer, time.Hour, clk, zap.NewNop().Sugar())
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/sessions/room-a/display-token", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("sessionID", "room-a")
+	ctx := auth.WithClaims(r.Context(), &auth.Claims{Role: auth.RoleGameMaster, Sessions: []string{"*"}, Venue: "paris"})
+	r = r.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+
+	handler.CreateDisplayToken(w, r)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response types.TokenResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "room-a", response.SessionID)
+	assert.True(t, clk.Now().Add(time.Hour).Equal(response.ExpiresAt))
+
+	claims, err := signer.Verify(response.Token)
+	if assert.NoError(t, err) {
+		assert.Equal(t, auth.RoleCustomer, claims.Role)
+		assert.Equal(t, []string{"room-a"}, claims.Sessions)
+		assert.Equal(t, "paris", claims.Venue)
+		assert.False(t, claims.AllowsSession("room-b"))
+	}
+
+	clk.Advance(time.Hour)
+	_, err = signer.Verify(response.Token)
+	assert.ErrorIs(t, err, auth.ErrExpiredToken)
+}
@@ -0,0 +1,85 @@
+// Package lease elects a single owner for a task among the instances
+// sharing a Redis server.
+package lease
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// DefaultTTL is how long a lease outlives its last renewal. When the
+// holder dies, another instance takes over within this time.
+const DefaultTTL = 3 * time.Second
+
+// renewScript extends the lease only if owner still holds it.
+var renewScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseScript deletes the lease only if owner still holds it.
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// Lease is a Redis key naming its holder. It is taken with SET NX PX and
+// expires unless the holder renews it, so a crashed holder loses it after
+// TTL.
+type Lease struct {
+	redis *redis.Client
+	key   string
+	owner string
+	ttl   time.Duration
+}
+
+// New returns a lease on key for this process. A ttl of zero means
+// DefaultTTL.
+func New(client *redis.Client, key string, ttl time.Duration) *Lease {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Lease{redis: client, key: key, owner: newOwnerID(), ttl: ttl}
+}
+
+// Owner identifies this process as the holder of the lease.
+func (l *Lease) Owner() string {
+	return l.owner
+}
+
+// Hold renews the lease if this process holds it and takes it if nobody
+// does. It reports whether this process holds the lease for another TTL.
+func (l *Lease) Hold(ctx context.Context) (bool, error) {
+	renewed, err := renewScript.Run(ctx, l.redis, []string{l.key}, l.owner, l.ttl.Milliseconds()).Int()
+	if err != nil || renewed == 1 {
+		return renewed == 1, err
+	}
+	return l.redis.SetNX(ctx, l.key, l.owner, l.ttl).Result()
+}
+
+// Release gives the lease up so that another instance can take it at once.
+// It does nothing if this process does not hold the lease.
+func (l *Lease) Release(ctx context.Context) error {
+	return releaseScript.Run(ctx, l.redis, []string{l.key}, l.owner).Err()
+}
+
+// newOwnerID names the process in the lease key, for operators to see
+// which instance holds it.
+func newOwnerID() string {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return host + "-" + hex.EncodeToString(suffix)
+}
@@ -0,0 +1,54 @@
+package lease
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeaseHasOneHolder(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	ctx := context.Background()
+
+	first := New(client, "lease:test", 3*time.Second)
+	second := New(client, "lease:test", 3*time.Second)
+
+	held, err := first.Hold(ctx)
+	assert.NoError(t, err)
+	assert.True(t, held)
+	mr.CheckGet(t, "lease:test", first.Owner())
+
+	held, err = second.Hold(ctx)
+	assert.NoError(t, err)
+	assert.False(t, held)
+
+	// Renewing keeps the lease alive past its original TTL.
+	mr.FastForward(2 * time.Second)
+	held, _ = first.Hold(ctx)
+	assert.True(t, held)
+	mr.FastForward(2 * time.Second)
+	held, _ = second.Hold(ctx)
+	assert.False(t, held)
+
+	// A holder that stops renewing loses the lease.
+	mr.FastForward(3 * time.Second)
+	held, _ = second.Hold(ctx)
+	assert.True(t, held)
+	held, _ = first.Hold(ctx)
+	assert.False(t, held)
+
+	// Only the holder can release it.
+	assert.NoError(t, first.Release(ctx))
+	mr.CheckGet(t, "lease:test", second.Owner())
+	assert.NoError(t, second.Release(ctx))
+	assert.False(t, mr.Exists("lease:test"))
+
+	held, _ = first.Hold(ctx)
+	assert.True(t, held)
+}
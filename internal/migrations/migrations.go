@@ -0,0 +1,326 @@
+// Package migrations versions the database schema with numbered SQL files
+// embedded in the binary. Each dialect has a directory of
+// NNNN_name.up.sql and NNNN_name.down.sql files, and the schema_migrations
+// table records the versions applied to a database.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+//go:embed mysql/*.sql postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// Table records the applied migrations.
+const Table = "schema_migrations"
+
+// baseline is the version of the schema AutoMigrate created before
+// migrations were versioned, and baselineColumns the columns of its timers
+// table.
+const baseline = 1
+
+var baselineColumns = []string{"id", "session_id", "max_time", "current_time", "is_paused"}
+
+// Migration is a numbered change to the schema, with the SQL applying and
+// reverting it.
+type Migration struct {
+	Version uint
+	Name    string
+	up      string
+	down    string
+}
+
+// Status is a migration and when it was applied, if it was.
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// record is a row of Table.
+type record struct {
+	Version   uint
+	Name      string
+	AppliedAt time.Time
+}
+
+func (record) TableName() string {
+	return Table
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Load returns the migrations of dialect, a gorm dialector name, in version
+// order.
+func Load(dialect string) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, dialect)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for %s databases", dialect)
+	}
+
+	byVersion := make(map[uint]*Migration)
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration %s/%s is not named NNNN_name.up.sql or NNNN_name.down.sql", dialect, entry.Name())
+		}
+		version, _ := strconv.ParseUint(match[1], 10, 0)
+		script, err := fs.ReadFile(files, path.Join(dialect, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration := byVersion[uint(version)]
+		if migration == nil {
+			migration = &Migration{Version: uint(version), Name: match[2]}
+			byVersion[uint(version)] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migrations %s/%s and %s share version %d", dialect, migration.Name, match[2], version)
+		}
+		if match[3] == "up" {
+			migration.up = string(script)
+		} else {
+			migration.down = string(script)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.up == "" || migration.down == "" {
+			return nil, fmt.Errorf("migration %s/%04d_%s needs both an up and a down file", dialect, migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Migrator applies and reverts the migrations of a database.
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+	logger     *zap.SugaredLogger
+}
+
+func New(db *gorm.DB, logger *zap.SugaredLogger) (*Migrator, error) {
+	migrations, err := Load(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations, logger: logger}, nil
+}
+
+// Up applies the pending migrations in order and returns them. A database
+// whose timers table AutoMigrate created, before migrations were
+// versioned, is taken to be at the baseline version rather than created
+// again.
+func (m *Migrator) Up() ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	if len(applied) == 0 && m.db.Migrator().HasTable("timers") {
+		if err := m.adopt(); err != nil {
+			return nil, err
+		}
+		applied[baseline] = true
+	}
+
+	var done []Migration
+	for _, migration := range m.migrations {
+		if applied[migration.Version] {
+			continue
+		}
+		if err := m.run(migration, true); err != nil {
+			return done, err
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Down reverts the last steps applied migrations, newest first, and returns
+// them.
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		migration := m.migrations[i]
+		if !applied[migration.Version] {
+			continue
+		}
+		if err := m.run(migration, false); err != nil {
+			return done, err
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Status lists every migration and when it was applied.
+func (m *Migrator) Status() ([]Status, error) {
+	if _, err := m.applied(); err != nil {
+		return nil, err
+	}
+	var records []record
+	if err := m.db.Find(&records).Error; err != nil {
+		return nil, err
+	}
+	appliedAt := make(map[uint]time.Time)
+	for _, r := range records {
+		appliedAt[r.Version] = r.AppliedAt
+	}
+
+	statuses := make([]Status, len(m.migrations))
+	for i, migration := range m.migrations {
+		statuses[i].Migration = migration
+		if at, ok := appliedAt[migration.Version]; ok {
+			statuses[i].AppliedAt = &at
+		}
+	}
+	return statuses, nil
+}
+
+// Version returns the latest applied version, zero for an empty database.
+func (m *Migrator) Version() (uint, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return 0, err
+	}
+	var version uint
+	for v := range applied {
+		if v > version {
+			version = v
+		}
+	}
+	return version, nil
+}
+
+// applied creates Table if needed and returns the applied versions. It
+// refuses a database migrated by a newer build, whose schema this one may
+// not understand.
+func (m *Migrator) applied() (map[uint]bool, error) {
+	err := m.db.Exec("CREATE TABLE IF NOT EXISTS " + Table + " (" +
+		"version BIGINT NOT NULL PRIMARY KEY, " +
+		"name VARCHAR(255) NOT NULL, " +
+		"applied_at TIMESTAMP NOT NULL)").Error
+	if err != nil {
+		return nil, fmt.Errorf("create %s: %w", Table, err)
+	}
+
+	var versions []uint
+	if err := m.db.Model(&record{}).Pluck("version", &versions).Error; err != nil {
+		return nil, err
+	}
+	known := make(map[uint]bool)
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+	}
+	applied := make(map[uint]bool)
+	for _, version := range versions {
+		if !known[version] {
+			return nil, fmt.Errorf("database has migration %d, which this build does not know; use a newer build", version)
+		}
+		applied[version] = true
+	}
+	return applied, nil
+}
+
+// adopt records the baseline migration as applied without running it. It
+// refuses a timers table whose columns are not the baseline's, since the
+// later migrations would fail on it or leave it incomplete.
+func (m *Migrator) adopt() error {
+	migration := m.migrations[0]
+	if migration.Version != baseline {
+		return fmt.Errorf("first migration is %d, not the baseline %d", migration.Version, baseline)
+	}
+
+	columnTypes, err := m.db.Migrator().ColumnTypes("timers")
+	if err != nil {
+		return fmt.Errorf("read the columns of timers: %w", err)
+	}
+	columns := make([]string, 0, len(columnTypes))
+	for _, column := range columnTypes {
+		columns = append(columns, strings.ToLower(column.Name()))
+	}
+	sort.Strings(columns)
+	expected := append([]string(nil), baselineColumns...)
+	sort.Strings(expected)
+	if strings.Join(columns, ",") != strings.Join(expected, ",") {
+		return fmt.Errorf("timers table has columns %v, not the baseline's %v; migrate it by hand", columns, expected)
+	}
+
+	err = m.db.Create(&record{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now().UTC()}).Error
+	if err != nil {
+		return err
+	}
+	m.logger.Infow("Adopted schema created before versioned migrations", "version", migration.Version)
+	return nil
+}
+
+// run applies or reverts migration in a transaction. MySQL commits schema
+// changes implicitly, so a migration failing there may be left half
+// applied and needs fixing by hand.
+func (m *Migrator) run(migration Migration, up bool) error {
+	script, verb := migration.up, "Applied"
+	if !up {
+		script, verb = migration.down, "Reverted"
+	}
+
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range statements(script) {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		if !up {
+			return tx.Delete(&record{}, "version = ?", migration.Version).Error
+		}
+		return tx.Create(&record{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now().UTC()}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migration %04d_%s: %w", migration.Version, migration.Name, err)
+	}
+	m.logger.Infow(verb+" migration", "version", migration.Version, "name", migration.Name)
+	return nil
+}
+
+// statements splits a script into its statements, each ending with a
+// semicolon at the end of a line, and drops comment lines. Drivers run one
+// statement at a time.
+func statements(script string) []string {
+	var result []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			result = append(result, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		result = append(result, rest)
+	}
+	return result
+}
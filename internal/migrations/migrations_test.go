@@ -0,0 +1,189 @@
+package migrations
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"timer-microservice/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openSQLite(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "timers.db")), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+	return db
+}
+
+func newMigrator(t *testing.T, db *gorm.DB) *Migrator {
+	migrator, err := New(db, zap.NewNop().Sugar())
+	require.NoError(t, err)
+	return migrator
+}
+
+func versions(migrations []Migration) []uint {
+	result := []uint{}
+	for _, migration := range migrations {
+		result = append(result, migration.Version)
+	}
+	return result
+}
+
+func TestDialectsHaveTheSameMigrations(t *testing.T) {
+	mysql, err := Load("mysql")
+	require.NoError(t, err)
+	assert.NotEmpty(t, mysql)
+
+	for _, dialect := range []string{"postgres", "sqlite"} {
+		migrations, err := Load(dialect)
+		require.NoError(t, err)
+		require.Len(t, migrations, len(mysql), dialect)
+		for i, migration := range migrations {
+			assert.Equal(t, mysql[i].Version, migration.Version, dialect)
+			assert.Equal(t, mysql[i].Name, migration.Name, dialect)
+			assert.NotEmpty(t, statements(migration.up), dialect)
+			assert.NotEmpty(t, statements(migration.down), dialect)
+		}
+	}
+
+	_, err = Load("sqlserver")
+	assert.Error(t, err)
+}
+
+func TestUpAndDown(t *testing.T) {
+	db := openSQLite(t)
+	migrator := newMigrator(t, db)
+
+	applied, err := migrator.Up()
+	require.NoError(t, err)
+	assert.Equal(t, []uint{1, 2, 3}, versions(applied))
+	assert.True(t, db.Migrator().HasTable("timers"))
+	assert.True(t, db.Migrator().HasTable("hints"))
+	assert.NoError(t, db.Create(&types.Timer{SessionID: "room-a"}).Error)
+
+	version, err := migrator.Version()
+	assert.NoError(t, err)
+	assert.Equal(t, uint(3), version)
+
+	// Nothing is pending any more.
+	applied, err = migrator.Up()
+	assert.NoError(t, err)
+	assert.Empty(t, applied)
+
+	statuses, err := migrator.Status()
+	require.NoError(t, err)
+	if assert.Len(t, statuses, 3) {
+		assert.Equal(t, "create_timers", statuses[0].Name)
+		assert.NotNil(t, statuses[2].AppliedAt)
+	}
+
+	reverted, err := migrator.Down(2)
+	require.NoError(t, err)
+	assert.Equal(t, []uint{3, 2}, versions(reverted))
+	assert.False(t, db.Migrator().HasTable("hints"))
+	assert.False(t, db.Migrator().HasColumn("timers", "state"))
+	version, _ = migrator.Version()
+	assert.Equal(t, uint(1), version)
+
+	reverted, err = migrator.Down(5)
+	require.NoError(t, err)
+	assert.Equal(t, []uint{1}, versions(reverted))
+	assert.False(t, db.Migrator().HasTable("timers"))
+
+	reverted, err = migrator.Down(1)
+	assert.NoError(t, err)
+	assert.Empty(t, reverted)
+}
+
+// baselineTimer is a timer as AutoMigrate stored it before migrations were
+// versioned.
+type baselineTimer struct {
+	ID          uint   `gorm:"primarykey"`
+	SessionID   string `gorm:"index"`
+	MaxTime     int64
+	CurrentTime int64
+	IsPaused    bool
+}
+
+func (baselineTimer) TableName() string {
+	return "timers"
+}
+
+func TestUpAdoptsBaselineSchema(t *testing.T) {
+	db := openSQLite(t)
+	require.NoError(t, db.AutoMigrate(&baselineTimer{}))
+	require.NoError(t, db.Create(&[]baselineTimer{
+		{SessionID: "room-a", MaxTime: 3600, CurrentTime: 600},
+		{SessionID: "room-b", MaxTime: 3600, CurrentTime: 1200, IsPaused: true},
+		{SessionID: "room-c", MaxTime: 3600},
+	}).Error)
+
+	migrator := newMigrator(t, db)
+	applied, err := migrator.Up()
+	require.NoError(t, err)
+	assert.Equal(t, []uint{2, 3}, versions(applied))
+	version, _ := migrator.Version()
+	assert.Equal(t, uint(3), version)
+
+	// The stored timers carry on from where they stood.
+	var timers []types.Timer
+	require.NoError(t, db.Order("id").Find(&timers).Error)
+	require.Len(t, timers, 3)
+	now := time.Now()
+	for _, timer := range timers {
+		assert.Equal(t, types.ModeCountdown, timer.Mode, timer.SessionID)
+		assert.Equal(t, uint(1), timer.Version, timer.SessionID)
+	}
+	assert.Equal(t, types.StateRunning, timers[0].State)
+	assert.InDelta(t, 600, timers[0].Remaining(now).Seconds(), 5)
+	assert.InDelta(t, 3000, timers[0].Elapsed(now).Seconds(), 5)
+	assert.Equal(t, types.StatePaused, timers[1].State)
+	assert.InDelta(t, 1200, timers[1].Remaining(now.Add(time.Hour)).Seconds(), 5)
+	// The tick loop expires the timer that had run out.
+	assert.Equal(t, types.StateRunning, timers[2].State)
+	assert.True(t, timers[2].HasRunOut(now))
+}
+
+func TestUpRefusesUnversionedSchemaBeyondBaseline(t *testing.T) {
+	db := openSQLite(t)
+	require.NoError(t, db.AutoMigrate(&types.Timer{}))
+
+	migrator := newMigrator(t, db)
+	_, err := migrator.Up()
+	assert.ErrorContains(t, err, "not the baseline's")
+	version, _ := migrator.Version()
+	assert.Equal(t, uint(0), version)
+}
+
+func TestUpRefusesNewerSchema(t *testing.T) {
+	db := openSQLite(t)
+	migrator := newMigrator(t, db)
+	_, err := migrator.Up()
+	require.NoError(t, err)
+	require.NoError(t, db.Create(&record{Version: 99, Name: "from_the_future"}).Error)
+
+	_, err = migrator.Up()
+	assert.ErrorContains(t, err, "migration 99")
+}
+
+func TestStatements(t *testing.T) {
+	script := `-- A comment.
+
+CREATE TABLE a (
+	id integer
+);
+CREATE INDEX idx_a ON a (id);
+DROP TABLE b`
+
+	assert.Equal(t, []string{
+		"CREATE TABLE a (\n\tid integer\n);",
+		"CREATE INDEX idx_a ON a (id);",
+		"DROP TABLE b",
+	}, statements(script))
+}
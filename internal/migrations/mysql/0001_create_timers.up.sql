@@ -0,0 +1,11 @@
+-- The schema AutoMigrate created before migrations were versioned.
+
+CREATE TABLE `timers` (
+	`id` bigint unsigned AUTO_INCREMENT,
+	`session_id` varchar(191),
+	`max_time` bigint,
+	`current_time` bigint,
+	`is_paused` boolean,
+	PRIMARY KEY (`id`),
+	INDEX `idx_timers_session_id` (`session_id`)
+);
@@ -0,0 +1,19 @@
+ALTER TABLE `timers`
+	DROP INDEX `idx_timers_state`,
+	DROP INDEX `idx_timers_ends_at`,
+	DROP INDEX `idx_timers_created_at`,
+	DROP COLUMN `state`,
+	DROP COLUMN `mode`,
+	DROP COLUMN `allow_overtime`,
+	DROP COLUMN `overtime`,
+	DROP COLUMN `started_at`,
+	DROP COLUMN `ends_at`,
+	DROP COLUMN `paused_at`,
+	DROP COLUMN `paused_total`,
+	DROP COLUMN `expired_at`,
+	DROP COLUMN `stopped_at`,
+	DROP COLUMN `soft_cap_reached_at`,
+	DROP COLUMN `adjustments`,
+	DROP COLUMN `hint_count`,
+	DROP COLUMN `created_at`,
+	DROP COLUMN `version`;
@@ -0,0 +1,28 @@
+ALTER TABLE `timers`
+	ADD COLUMN `state` varchar(16),
+	ADD COLUMN `mode` varchar(16) DEFAULT 'countdown',
+	ADD COLUMN `allow_overtime` boolean,
+	ADD COLUMN `overtime` bigint,
+	ADD COLUMN `started_at` datetime(3) NULL,
+	ADD COLUMN `ends_at` datetime(3) NULL,
+	ADD COLUMN `paused_at` datetime(3) NULL,
+	ADD COLUMN `paused_total` bigint,
+	ADD COLUMN `expired_at` datetime(3) NULL,
+	ADD COLUMN `stopped_at` datetime(3) NULL,
+	ADD COLUMN `soft_cap_reached_at` datetime(3) NULL,
+	ADD COLUMN `adjustments` text,
+	ADD COLUMN `hint_count` bigint,
+	ADD COLUMN `created_at` datetime(3) NULL,
+	ADD COLUMN `version` bigint unsigned NOT NULL DEFAULT 1,
+	ADD INDEX `idx_timers_state` (`state`),
+	ADD INDEX `idx_timers_ends_at` (`ends_at`),
+	ADD INDEX `idx_timers_created_at` (`created_at`);
+
+-- Baseline timers counted current_time down every second. Place them on
+-- the clock so they carry on from where they stood.
+UPDATE `timers` SET
+	`state` = CASE WHEN `is_paused` THEN 'paused' ELSE 'running' END,
+	`started_at` = UTC_TIMESTAMP(3) - INTERVAL (`max_time` - `current_time`) SECOND,
+	`ends_at` = UTC_TIMESTAMP(3) + INTERVAL `current_time` SECOND,
+	`paused_at` = CASE WHEN `is_paused` THEN UTC_TIMESTAMP(3) END,
+	`created_at` = UTC_TIMESTAMP(3);
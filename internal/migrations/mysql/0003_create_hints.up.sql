@@ -0,0 +1,11 @@
+CREATE TABLE `hints` (
+	`id` bigint unsigned AUTO_INCREMENT,
+	`session_id` varchar(191),
+	`timer_id` bigint unsigned,
+	`text` text,
+	`penalty` bigint,
+	`created_at` datetime(3) NULL,
+	PRIMARY KEY (`id`),
+	INDEX `idx_hints_session_id` (`session_id`),
+	INDEX `idx_hints_timer_id` (`timer_id`)
+);
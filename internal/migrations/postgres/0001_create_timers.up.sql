@@ -0,0 +1,11 @@
+-- The schema AutoMigrate created before migrations were versioned.
+
+CREATE TABLE "timers" (
+	"id" bigserial,
+	"session_id" text,
+	"max_time" bigint,
+	"current_time" bigint,
+	"is_paused" boolean,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_timers_session_id" ON "timers" ("session_id");
@@ -0,0 +1,28 @@
+ALTER TABLE "timers"
+	ADD COLUMN "state" varchar(16),
+	ADD COLUMN "mode" varchar(16) DEFAULT 'countdown',
+	ADD COLUMN "allow_overtime" boolean,
+	ADD COLUMN "overtime" bigint,
+	ADD COLUMN "started_at" timestamptz,
+	ADD COLUMN "ends_at" timestamptz,
+	ADD COLUMN "paused_at" timestamptz,
+	ADD COLUMN "paused_total" bigint,
+	ADD COLUMN "expired_at" timestamptz,
+	ADD COLUMN "stopped_at" timestamptz,
+	ADD COLUMN "soft_cap_reached_at" timestamptz,
+	ADD COLUMN "adjustments" text,
+	ADD COLUMN "hint_count" bigint,
+	ADD COLUMN "created_at" timestamptz,
+	ADD COLUMN "version" bigint NOT NULL DEFAULT 1;
+CREATE INDEX "idx_timers_state" ON "timers" ("state");
+CREATE INDEX "idx_timers_ends_at" ON "timers" ("ends_at");
+CREATE INDEX "idx_timers_created_at" ON "timers" ("created_at");
+
+-- Baseline timers counted current_time down every second. Place them on
+-- the clock so they carry on from where they stood.
+UPDATE "timers" SET
+	"state" = CASE WHEN "is_paused" THEN 'paused' ELSE 'running' END,
+	"started_at" = now() - ("max_time" - "current_time") * interval '1 second',
+	"ends_at" = now() + "current_time" * interval '1 second',
+	"paused_at" = CASE WHEN "is_paused" THEN now() END,
+	"created_at" = now();
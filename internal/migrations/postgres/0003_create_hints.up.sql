@@ -0,0 +1,11 @@
+CREATE TABLE "hints" (
+	"id" bigserial,
+	"session_id" text,
+	"timer_id" bigint,
+	"text" text,
+	"penalty" bigint,
+	"created_at" timestamptz,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_hints_session_id" ON "hints" ("session_id");
+CREATE INDEX "idx_hints_timer_id" ON "hints" ("timer_id");
@@ -0,0 +1,10 @@
+-- The schema AutoMigrate created before migrations were versioned.
+
+CREATE TABLE `timers` (
+	`id` integer PRIMARY KEY AUTOINCREMENT,
+	`session_id` text,
+	`max_time` integer,
+	`current_time` integer,
+	`is_paused` numeric
+);
+CREATE INDEX `idx_timers_session_id` ON `timers` (`session_id`);
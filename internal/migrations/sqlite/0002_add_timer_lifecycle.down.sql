@@ -0,0 +1,18 @@
+DROP INDEX `idx_timers_state`;
+DROP INDEX `idx_timers_ends_at`;
+DROP INDEX `idx_timers_created_at`;
+ALTER TABLE `timers` DROP COLUMN `state`;
+ALTER TABLE `timers` DROP COLUMN `mode`;
+ALTER TABLE `timers` DROP COLUMN `allow_overtime`;
+ALTER TABLE `timers` DROP COLUMN `overtime`;
+ALTER TABLE `timers` DROP COLUMN `started_at`;
+ALTER TABLE `timers` DROP COLUMN `ends_at`;
+ALTER TABLE `timers` DROP COLUMN `paused_at`;
+ALTER TABLE `timers` DROP COLUMN `paused_total`;
+ALTER TABLE `timers` DROP COLUMN `expired_at`;
+ALTER TABLE `timers` DROP COLUMN `stopped_at`;
+ALTER TABLE `timers` DROP COLUMN `soft_cap_reached_at`;
+ALTER TABLE `timers` DROP COLUMN `adjustments`;
+ALTER TABLE `timers` DROP COLUMN `hint_count`;
+ALTER TABLE `timers` DROP COLUMN `created_at`;
+ALTER TABLE `timers` DROP COLUMN `version`;
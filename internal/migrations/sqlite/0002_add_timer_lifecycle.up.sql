@@ -0,0 +1,27 @@
+ALTER TABLE `timers` ADD COLUMN `state` varchar(16);
+ALTER TABLE `timers` ADD COLUMN `mode` varchar(16) DEFAULT 'countdown';
+ALTER TABLE `timers` ADD COLUMN `allow_overtime` numeric;
+ALTER TABLE `timers` ADD COLUMN `overtime` integer;
+ALTER TABLE `timers` ADD COLUMN `started_at` datetime;
+ALTER TABLE `timers` ADD COLUMN `ends_at` datetime;
+ALTER TABLE `timers` ADD COLUMN `paused_at` datetime;
+ALTER TABLE `timers` ADD COLUMN `paused_total` integer;
+ALTER TABLE `timers` ADD COLUMN `expired_at` datetime;
+ALTER TABLE `timers` ADD COLUMN `stopped_at` datetime;
+ALTER TABLE `timers` ADD COLUMN `soft_cap_reached_at` datetime;
+ALTER TABLE `timers` ADD COLUMN `adjustments` text;
+ALTER TABLE `timers` ADD COLUMN `hint_count` integer;
+ALTER TABLE `timers` ADD COLUMN `created_at` datetime;
+ALTER TABLE `timers` ADD COLUMN `version` integer NOT NULL DEFAULT 1;
+CREATE INDEX `idx_timers_state` ON `timers` (`state`);
+CREATE INDEX `idx_timers_ends_at` ON `timers` (`ends_at`);
+CREATE INDEX `idx_timers_created_at` ON `timers` (`created_at`);
+
+-- Baseline timers counted current_time down every second. Place them on
+-- the clock so they carry on from where they stood.
+UPDATE `timers` SET
+	`state` = CASE WHEN `is_paused` THEN 'paused' ELSE 'running' END,
+	`started_at` = strftime('%Y-%m-%d %H:%M:%f', 'now', '-' || (`max_time` - `current_time`) || ' seconds'),
+	`ends_at` = strftime('%Y-%m-%d %H:%M:%f', 'now', '+' || `current_time` || ' seconds'),
+	`paused_at` = CASE WHEN `is_paused` THEN strftime('%Y-%m-%d %H:%M:%f', 'now') END,
+	`created_at` = strftime('%Y-%m-%d %H:%M:%f', 'now');
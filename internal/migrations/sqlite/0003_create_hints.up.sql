@@ -0,0 +1,10 @@
+CREATE TABLE `hints` (
+	`id` integer PRIMARY KEY AUTOINCREMENT,
+	`session_id` text,
+	`timer_id` integer,
+	`text` text,
+	`penalty` integer,
+	`created_at` datetime
+);
+CREATE INDEX `idx_hints_session_id` ON `hints` (`session_id`);
+CREATE INDEX `idx_hints_timer_id` ON `hints` (`timer_id`);
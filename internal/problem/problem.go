@@ -0,0 +1,29 @@
+// Package problem writes RFC 7807 problem details, the body of every failed
+// HTTP request, so that the REST handlers, the auth middleware and the
+// WebSocket upgrade report errors alike.
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"timer-microservice/internal/types"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Write sends a problem details body with status.
+func Write(w http.ResponseWriter, r *http.Request, status int, code types.ErrorCode, detail string, fields ...types.FieldError) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(types.Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: middleware.GetReqID(r.Context()),
+		Errors:    fields,
+	})
+}
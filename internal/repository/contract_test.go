@@ -0,0 +1,280 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"timer-microservice/internal/migrations"
+	"timer-microservice/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type repositories struct {
+	timers TimerRepository
+	hints  HintRepository
+}
+
+// backends returns, for every storage backend, a function opening empty
+// repositories. MySQL and PostgreSQL are only checked when TEST_MYSQL_DSN
+// and TEST_POSTGRES_DSN name scratch databases, whose tables are truncated.
+func backends() map[string]func(t *testing.T) repositories {
+	openDB := func(t *testing.T, dialector gorm.Dialector) *gorm.DB {
+		db, err := gorm.Open(dialector, &gorm.Config{Logger: logger.Discard})
+		require.NoError(t, err)
+		migrator, err := migrations.New(db, zap.NewNop().Sugar())
+		require.NoError(t, err)
+		_, err = migrator.Up()
+		require.NoError(t, err)
+		return db
+	}
+
+	backends := map[string]func(t *testing.T) repositories{
+		"memory": func(t *testing.T) repositories {
+			return repositories{NewMemoryTimerRepository(), NewMemoryHintRepository()}
+		},
+		"sqlite": func(t *testing.T) repositories {
+			db := openDB(t, sqlite.Open(filepath.Join(t.TempDir(), "timers.db")))
+			return repositories{NewTimerRepository(db), NewHintRepository(db)}
+		},
+	}
+	if dsn := os.Getenv("TEST_MYSQL_DSN"); dsn != "" {
+		backends["mysql"] = func(t *testing.T) repositories {
+			db := openDB(t, mysql.Open(dsn))
+			require.NoError(t, db.Exec("TRUNCATE TABLE timers").Error)
+			require.NoError(t, db.Exec("TRUNCATE TABLE hints").Error)
+			return repositories{NewTimerRepository(db), NewHintRepository(db)}
+		}
+	}
+	if dsn := os.Getenv("TEST_POSTGRES_DSN"); dsn != "" {
+		backends["postgres"] = func(t *testing.T) repositories {
+			db := openDB(t, postgres.Open(dsn))
+			require.NoError(t, db.Exec("TRUNCATE TABLE timers, hints RESTART IDENTITY").Error)
+			return repositories{NewTimerRepository(db), NewHintRepository(db)}
+		}
+	}
+	return backends
+}
+
+func TestRepositoryContract(t *testing.T) {
+	tests := map[string]func(t *testing.T, repos repositories){
+		"create and find":       testCreateAndFind,
+		"update checks version": testUpdateChecksVersion,
+		"listings":              testListings,
+		"find timers pages":     testFindTimersPages,
+		"hints":                 testHints,
+	}
+	for backend, open := range backends() {
+		t.Run(backend, func(t *testing.T) {
+			for name, test := range tests {
+				t.Run(name, func(t *testing.T) {
+					test(t, open(t))
+				})
+			}
+		})
+	}
+}
+
+var contractEpoch = time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+
+func testCreateAndFind(t *testing.T, repos repositories) {
+	timer := &types.Timer{
+		SessionID:   "room-a",
+		MaxTime:     3600,
+		State:       types.StateRunning,
+		StartedAt:   contractEpoch,
+		EndsAt:      contractEpoch.Add(time.Hour),
+		Adjustments: []types.TimerAdjustment{{Delta: -60, Reason: "hint", At: contractEpoch}},
+	}
+	require.NoError(t, repos.timers.Create(timer))
+	assert.NotZero(t, timer.ID)
+
+	found, err := repos.timers.FindByID(timer.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "room-a", found.SessionID)
+	assert.Equal(t, types.StateRunning, found.State)
+	assert.Equal(t, types.ModeCountdown, found.Mode)
+	assert.Equal(t, uint(1), found.Version)
+	assert.True(t, found.EndsAt.Equal(contractEpoch.Add(time.Hour)))
+	assert.Nil(t, found.PausedAt)
+	if assert.Len(t, found.Adjustments, 1) {
+		assert.Equal(t, "hint", found.Adjustments[0].Reason)
+	}
+
+	// The caller's copy is not the stored one.
+	found.MaxTime = 60
+	again, _ := repos.timers.FindByID(timer.ID)
+	assert.Equal(t, int64(3600), again.MaxTime)
+
+	require.NoError(t, repos.timers.Delete(timer.ID))
+	_, err = repos.timers.FindByID(timer.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.NoError(t, repos.timers.Delete(timer.ID))
+}
+
+func testUpdateChecksVersion(t *testing.T, repos repositories) {
+	timer := &types.Timer{SessionID: "room-a", MaxTime: 3600, State: types.StateRunning, Version: 1}
+	require.NoError(t, repos.timers.Create(timer))
+
+	first, _ := repos.timers.FindByID(timer.ID)
+	second, _ := repos.timers.FindByID(timer.ID)
+
+	first.State = types.StatePaused
+	first.IsPaused = true
+	pausedAt := contractEpoch
+	first.PausedAt = &pausedAt
+	require.NoError(t, repos.timers.Update(first))
+	assert.Equal(t, uint(2), first.Version)
+
+	// The second writer loaded version 1 and loses.
+	second.MaxTime = 60
+	assert.ErrorIs(t, repos.timers.Update(second), ErrVersionConflict)
+	assert.Equal(t, uint(1), second.Version)
+
+	stored, _ := repos.timers.FindByID(timer.ID)
+	assert.Equal(t, types.StatePaused, stored.State)
+	assert.Equal(t, int64(3600), stored.MaxTime)
+	assert.Equal(t, uint(2), stored.Version)
+	if assert.NotNil(t, stored.PausedAt) {
+		assert.True(t, stored.PausedAt.Equal(pausedAt))
+	}
+
+	// Zero values are saved too.
+	stored.IsPaused = false
+	stored.PausedAt = nil
+	require.NoError(t, repos.timers.Update(stored))
+	stored, _ = repos.timers.FindByID(timer.ID)
+	assert.False(t, stored.IsPaused)
+	assert.Nil(t, stored.PausedAt)
+	assert.Equal(t, uint(3), stored.Version)
+
+	// A deleted timer cannot be updated.
+	require.NoError(t, repos.timers.Delete(timer.ID))
+	assert.ErrorIs(t, repos.timers.Update(stored), ErrVersionConflict)
+}
+
+func testListings(t *testing.T, repos repositories) {
+	states := []types.TimerState{types.StateRunning, types.StatePaused, types.StateRunning, types.StateStopped}
+	for i, state := range states {
+		sessionID := "room-a"
+		if i == 2 {
+			sessionID = "room-b"
+		}
+		require.NoError(t, repos.timers.Create(&types.Timer{SessionID: sessionID, State: state, Version: 1}))
+	}
+
+	all, err := repos.timers.FindAll()
+	require.NoError(t, err)
+	assert.Len(t, all, 4)
+
+	session, err := repos.timers.FindBySessionID("room-a")
+	require.NoError(t, err)
+	assert.Equal(t, []uint{4, 2, 1}, timerIDs(session))
+
+	active, err := repos.timers.GetActiveTimers()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uint{1, 3}, timerIDs(active))
+
+	none, err := repos.timers.FindBySessionID("room-c")
+	assert.NoError(t, err)
+	assert.Empty(t, none)
+}
+
+func testFindTimersPages(t *testing.T, repos repositories) {
+	// Timers 2 and 3 were created at the same instant.
+	offsets := []time.Duration{0, time.Minute, time.Minute, 2 * time.Minute, 3 * time.Minute}
+	for i, offset := range offsets {
+		timer := &types.Timer{
+			SessionID: "room-a",
+			State:     types.StateRunning,
+			IsPaused:  i == 4,
+			CreatedAt: contractEpoch.Add(offset),
+			Version:   1,
+		}
+		if i == 4 {
+			timer.State = types.StatePaused
+		}
+		require.NoError(t, repos.timers.Create(timer))
+	}
+	require.NoError(t, repos.timers.Create(&types.Timer{SessionID: "room-b", State: types.StateRunning, CreatedAt: contractEpoch, Version: 1}))
+
+	pages := func(query TimerQuery) [][]uint {
+		var pages [][]uint
+		for {
+			page, err := repos.timers.FindTimers(query)
+			require.NoError(t, err)
+			if len(page) == 0 {
+				return pages
+			}
+			pages = append(pages, timerIDs(page))
+			cursor := CursorFor(&page[len(page)-1])
+			query.After = &cursor
+		}
+	}
+
+	query := TimerQuery{SessionID: "room-a", Limit: 2}
+	assert.Equal(t, [][]uint{{5, 4}, {3, 2}, {1}}, pages(query))
+	query.Sort = SortCreatedAtAsc
+	assert.Equal(t, [][]uint{{1, 2}, {3, 4}, {5}}, pages(query))
+	query.Sort = SortIDDesc
+	assert.Equal(t, [][]uint{{5, 4}, {3, 2}, {1}}, pages(query))
+	query.Sort = SortIDAsc
+	assert.Equal(t, [][]uint{{1, 2}, {3, 4}, {5}}, pages(query))
+
+	paused := false
+	filtered := TimerQuery{
+		States:      []types.TimerState{types.StateRunning, types.StatePaused},
+		Paused:      &paused,
+		CreatedFrom: contractEpoch.Add(time.Minute),
+		CreatedTo:   contractEpoch.Add(3 * time.Minute),
+		Sort:        SortIDAsc,
+	}
+	assert.Equal(t, [][]uint{{2, 3, 4}}, pages(filtered))
+
+	filtered = TimerQuery{States: []types.TimerState{types.StatePaused}}
+	assert.Equal(t, [][]uint{{5}}, pages(filtered))
+}
+
+func testHints(t *testing.T, repos repositories) {
+	for i, text := range []string{"look up", "try the desk", "elsewhere"} {
+		sessionID := "room-a"
+		if i == 2 {
+			sessionID = "room-b"
+		}
+		hint := &types.Hint{SessionID: sessionID, TimerID: 1, Text: text, CreatedAt: contractEpoch.Add(time.Duration(i) * time.Minute)}
+		require.NoError(t, repos.hints.Create(hint))
+		assert.NotZero(t, hint.ID)
+	}
+
+	hints, err := repos.hints.FindBySessionID("room-a")
+	require.NoError(t, err)
+	if assert.Len(t, hints, 2) {
+		assert.Equal(t, "look up", hints[0].Text)
+		assert.Equal(t, "try the desk", hints[1].Text)
+	}
+
+	require.NoError(t, repos.hints.Delete(hints[0].ID))
+	hints, err = repos.hints.FindBySessionID("room-a")
+	require.NoError(t, err)
+	if assert.Len(t, hints, 1) {
+		assert.Equal(t, "try the desk", hints[0].Text)
+	}
+	assert.NoError(t, repos.hints.Delete(hints[0].ID+100))
+}
+
+func timerIDs(timers []types.Timer) []uint {
+	ids := []uint{}
+	for _, timer := range timers {
+		ids = append(ids, timer.ID)
+	}
+	return ids
+}
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"timer-microservice/internal/types"
+
+	"gorm.io/gorm"
+)
+
+type HintRepository interface {
+	Create(hint *types.Hint) error
+	Delete(id uint) error
+	FindBySessionID(sessionID string) ([]types.Hint, error)
+}
+
+type hintRepository struct {
+	db *gorm.DB
+}
+
+func NewHintRepository(db *gorm.DB) HintRepository {
+	return &hintRepository{db: db}
+}
+
+func (r *hintRepository) Create(hint *types.Hint) error {
+	return r.db.Create(hint).Error
+}
+
+func (r *hintRepository) Delete(id uint) error {
+	return r.db.Delete(&types.Hint{}, id).Error
+}
+
+// FindBySessionID returns the hints sent to a session, oldest first.
+func (r *hintRepository) FindBySessionID(sessionID string) ([]types.Hint, error) {
+	var hints []types.Hint
+	err := r.db.Where("session_id = ?", sessionID).Order("created_at, id").Find(&hints).Error
+	return hints, err
+}
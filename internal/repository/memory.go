@@ -0,0 +1,231 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"timer-microservice/internal/types"
+
+	"gorm.io/gorm"
+)
+
+// memoryTimerRepository keeps timers in process memory. It follows the
+// semantics of the database repository, including versions and
+// gorm.ErrRecordNotFound, so the service cannot tell them apart; everything
+// is lost when the process exits.
+type memoryTimerRepository struct {
+	mu     sync.Mutex
+	timers map[uint]types.Timer
+	lastID uint
+}
+
+func NewMemoryTimerRepository() TimerRepository {
+	return &memoryTimerRepository{timers: make(map[uint]types.Timer)}
+}
+
+// cloneTimer copies timer so that callers never share memory with the
+// stored one.
+func cloneTimer(timer types.Timer) types.Timer {
+	if timer.Adjustments != nil {
+		timer.Adjustments = append([]types.TimerAdjustment(nil), timer.Adjustments...)
+	}
+	return timer
+}
+
+func (r *memoryTimerRepository) Create(timer *types.Timer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if timer.ID == 0 {
+		timer.ID = r.lastID + 1
+	} else if _, ok := r.timers[timer.ID]; ok {
+		return fmt.Errorf("timer %d already exists", timer.ID)
+	}
+	if timer.ID > r.lastID {
+		r.lastID = timer.ID
+	}
+
+	// Apply the column defaults of the timers table.
+	if timer.CreatedAt.IsZero() {
+		timer.CreatedAt = time.Now()
+	}
+	if timer.Mode == "" {
+		timer.Mode = types.ModeCountdown
+	}
+	if timer.Version == 0 {
+		timer.Version = 1
+	}
+
+	r.timers[timer.ID] = cloneTimer(*timer)
+	return nil
+}
+
+// Update saves timer if the stored one is still at timer's version, and
+// increments the version. It returns ErrVersionConflict when another
+// writer updated or deleted the timer since it was loaded.
+func (r *memoryTimerRepository) Update(timer *types.Timer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.timers[timer.ID]
+	if !ok || stored.Version != timer.Version {
+		return ErrVersionConflict
+	}
+	timer.Version++
+	r.timers[timer.ID] = cloneTimer(*timer)
+	return nil
+}
+
+func (r *memoryTimerRepository) FindByID(id uint) (*types.Timer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.timers[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	timer := cloneTimer(stored)
+	return &timer, nil
+}
+
+func (r *memoryTimerRepository) Delete(id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.timers, id)
+	return nil
+}
+
+func (r *memoryTimerRepository) FindAll() ([]types.Timer, error) {
+	return r.FindTimers(TimerQuery{Sort: SortIDAsc})
+}
+
+// FindBySessionID returns the timers of a session, newest first.
+func (r *memoryTimerRepository) FindBySessionID(sessionID string) ([]types.Timer, error) {
+	return r.FindTimers(TimerQuery{SessionID: sessionID, Sort: SortIDDesc})
+}
+
+// FindTimers returns the timers matching query in the order and pages of
+// the database repository.
+func (r *memoryTimerRepository) FindTimers(query TimerQuery) ([]types.Timer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	timers := []types.Timer{}
+	for _, timer := range r.timers {
+		if matches(query, &timer) {
+			timers = append(timers, cloneTimer(timer))
+		}
+	}
+	sort.Slice(timers, func(i, j int) bool {
+		return precedes(query.Sort, CursorFor(&timers[i]), CursorFor(&timers[j]))
+	})
+
+	if query.Limit > 0 && len(timers) > query.Limit {
+		timers = timers[:query.Limit]
+	}
+	return timers, nil
+}
+
+// GetActiveTimers returns the running timers, including ones whose deadline
+// has passed but which have not been expired yet.
+func (r *memoryTimerRepository) GetActiveTimers() ([]types.Timer, error) {
+	return r.FindTimers(TimerQuery{States: []types.TimerState{types.StateRunning}, Sort: SortIDAsc})
+}
+
+// matches reports whether timer passes the filters of query and comes
+// after its cursor.
+func matches(query TimerQuery, timer *types.Timer) bool {
+	if query.SessionID != "" && timer.SessionID != query.SessionID {
+		return false
+	}
+	if len(query.States) > 0 {
+		found := false
+		for _, state := range query.States {
+			found = found || timer.State == state
+		}
+		if !found {
+			return false
+		}
+	}
+	if query.Paused != nil && timer.IsPaused != *query.Paused {
+		return false
+	}
+	if !query.CreatedFrom.IsZero() && timer.CreatedAt.Before(query.CreatedFrom) {
+		return false
+	}
+	if !query.CreatedTo.IsZero() && !timer.CreatedAt.Before(query.CreatedTo) {
+		return false
+	}
+	return query.After == nil || precedes(query.Sort, *query.After, CursorFor(timer))
+}
+
+// precedes reports whether a sorts before b in order.
+func precedes(order TimerSort, a, b TimerCursor) bool {
+	switch order {
+	case SortCreatedAtAsc:
+		return a.CreatedAt.Before(b.CreatedAt) || a.CreatedAt.Equal(b.CreatedAt) && a.ID < b.ID
+	case SortIDDesc:
+		return a.ID > b.ID
+	case SortIDAsc:
+		return a.ID < b.ID
+	default:
+		return a.CreatedAt.After(b.CreatedAt) || a.CreatedAt.Equal(b.CreatedAt) && a.ID > b.ID
+	}
+}
+
+// memoryHintRepository keeps hints in process memory.
+type memoryHintRepository struct {
+	mu     sync.Mutex
+	hints  []types.Hint
+	lastID uint
+}
+
+func NewMemoryHintRepository() HintRepository {
+	return &memoryHintRepository{}
+}
+
+func (r *memoryHintRepository) Create(hint *types.Hint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastID++
+	hint.ID = r.lastID
+	if hint.CreatedAt.IsZero() {
+		hint.CreatedAt = time.Now()
+	}
+	r.hints = append(r.hints, *hint)
+	return nil
+}
+
+func (r *memoryHintRepository) Delete(id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, hint := range r.hints {
+		if hint.ID == id {
+			r.hints = append(r.hints[:i], r.hints[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// FindBySessionID returns the hints sent to a session, oldest first.
+func (r *memoryHintRepository) FindBySessionID(sessionID string) ([]types.Hint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	hints := []types.Hint{}
+	for _, hint := range r.hints {
+		if hint.SessionID == sessionID {
+			hints = append(hints, hint)
+		}
+	}
+	sort.SliceStable(hints, func(i, j int) bool {
+		return hints[i].CreatedAt.Before(hints[j].CreatedAt)
+	})
+	return hints, nil
+}
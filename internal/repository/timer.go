@@ -3,20 +3,63 @@
 package repository
 
 import (
+	"errors"
+	"time"
+
 	"timer-microservice/internal/types"
 
 	"gorm.io/gorm"
 )
 
+// ErrVersionConflict is returned by Update when the timer was changed since
+// it was loaded.
+var ErrVersionConflict = errors.New("timer was modified concurrently")
+
 type TimerRepository interface {
 	Create(timer *types.Timer) error
 	Update(timer *types.Timer) error
 	FindByID(id uint) (*types.Timer, error)
 	Delete(id uint) error
 	FindAll() ([]types.Timer, error)
+	FindBySessionID(sessionID string) ([]types.Timer, error)
+	FindTimers(query TimerQuery) ([]types.Timer, error)
 	GetActiveTimers() ([]types.Timer, error)
 }
 
+// TimerSort orders timer listings. A leading "-" sorts descending; ties are
+// broken by ID in the same direction.
+type TimerSort string
+
+const (
+	SortCreatedAtDesc TimerSort = "-createdAt"
+	SortCreatedAtAsc  TimerSort = "createdAt"
+	SortIDDesc        TimerSort = "-id"
+	SortIDAsc         TimerSort = "id"
+)
+
+// TimerCursor marks the last timer of a page; the next page starts after it.
+type TimerCursor struct {
+	CreatedAt time.Time `json:"createdAt"`
+	ID        uint      `json:"id"`
+}
+
+func CursorFor(timer *types.Timer) TimerCursor {
+	return TimerCursor{CreatedAt: timer.CreatedAt, ID: timer.ID}
+}
+
+// TimerQuery filters and pages timer listings. Zero fields do not filter.
+// CreatedFrom is inclusive and CreatedTo exclusive.
+type TimerQuery struct {
+	SessionID   string
+	States      []types.TimerState
+	Paused      *bool
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	Sort        TimerSort
+	After       *TimerCursor
+	Limit       int
+}
+
 type timerRepository struct {
 	db *gorm.DB
 }
@@ -29,8 +72,21 @@ func (r *timerRepository) Create(timer *types.Timer) error {
 	return r.db.Create(timer).Error
 }
 
+// Update saves timer if the stored row is still at timer's version, and
+// increments the version. It returns ErrVersionConflict when another
+// writer updated the timer since it was loaded.
 func (r *timerRepository) Update(timer *types.Timer) error {
-	return r.db.Save(timer).Error
+	version := timer.Version
+	timer.Version++
+
+	result := r.db.Model(timer).Where("version = ?", version).Select("*").Updates(timer)
+	if result.Error == nil && result.RowsAffected == 0 {
+		result.Error = ErrVersionConflict
+	}
+	if result.Error != nil {
+		timer.Version = version
+	}
+	return result.Error
 }
 
 func (r *timerRepository) FindByID(id uint) (*types.Timer, error) {
@@ -52,13 +108,70 @@ func (r *timerRepository) FindAll() ([]types.Timer, error) {
 	return timers, err
 }
 
-func (r *timerRepository) GetActiveTimers() ([]types.Timer, error) {
+// FindBySessionID returns the timers of a session, newest first.
+func (r *timerRepository) FindBySessionID(sessionID string) ([]types.Timer, error) {
+	var timers []types.Timer
+	err := r.db.Where("session_id = ?", sessionID).Order("id DESC").Find(&timers).Error
+	return timers, err
+}
+
+// FindTimers returns the timers matching query, using keyset pagination so
+// a page costs the same however deep it is.
+func (r *timerRepository) FindTimers(query TimerQuery) ([]types.Timer, error) {
+	db := r.db.Model(&types.Timer{})
+	if query.SessionID != "" {
+		db = db.Where("session_id = ?", query.SessionID)
+	}
+	if len(query.States) > 0 {
+		db = db.Where("state IN ?", query.States)
+	}
+	if query.Paused != nil {
+		db = db.Where("is_paused = ?", *query.Paused)
+	}
+	if !query.CreatedFrom.IsZero() {
+		db = db.Where("created_at >= ?", query.CreatedFrom)
+	}
+	if !query.CreatedTo.IsZero() {
+		db = db.Where("created_at < ?", query.CreatedTo)
+	}
+
+	after := query.After
+	switch query.Sort {
+	case SortCreatedAtAsc:
+		if after != nil {
+			db = db.Where("created_at > ? OR (created_at = ? AND id > ?)", after.CreatedAt, after.CreatedAt, after.ID)
+		}
+		db = db.Order("created_at ASC, id ASC")
+	case SortIDDesc:
+		if after != nil {
+			db = db.Where("id < ?", after.ID)
+		}
+		db = db.Order("id DESC")
+	case SortIDAsc:
+		if after != nil {
+			db = db.Where("id > ?", after.ID)
+		}
+		db = db.Order("id ASC")
+	default:
+		if after != nil {
+			db = db.Where("created_at < ? OR (created_at = ? AND id < ?)", after.CreatedAt, after.CreatedAt, after.ID)
+		}
+		db = db.Order("created_at DESC, id DESC")
+	}
+
+	if query.Limit > 0 {
+		db = db.Limit(query.Limit)
+	}
+
 	var timers []types.Timer
-	err := r.db.Where("current_time > ? AND is_paused = ?", 0, false).Find(&timers).Error
+	err := db.Find(&timers).Error
 	return timers, err
 }
 
-// Migrate performs the database migration for the Timer model
-func Migrate(db *gorm.DB) error {
-	return db.AutoMigrate(&types.Timer{})
+// GetActiveTimers returns the running timers, including ones whose deadline
+// has passed but which have not been expired yet.
+func (r *timerRepository) GetActiveTimers() ([]types.Timer, error) {
+	var timers []types.Timer
+	err := r.db.Where("state = ?", types.StateRunning).Find(&timers).Error
+	return timers, err
 }
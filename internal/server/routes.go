@@ -1,16 +1,35 @@
 package server
 
 import (
+	"expvar"
+
+	"timer-microservice/internal/auth"
 	"timer-microservice/internal/handlers"
 	"timer-microservice/internal/websocket"
+
+	"github.com/go-chi/chi/v5"
 )
 
-func (s *Server) SetupRoutes(th *handlers.TimerHandler, wsh *websocket.Handler) {
-	s.router.Post("/timer", th.CreateTimer)
-	s.router.Put("/timer/{id}/pause", th.PauseTimer)
-	s.router.Put("/timer/{id}/resume", th.ResumeTimer)
-	s.router.Put("/timer/{id}/stop", th.StopTimer)
-	s.router.Put("/timer/{id}/modify", th.ModifyTimer)
+func (s *Server) SetupRoutes(th *handlers.TimerHandler, hh *handlers.HintHandler, tkh *handlers.TokenHandler, wsh *websocket.Handler, verifier auth.Verifier) {
+	s.router.Group(func(r chi.Router) {
+		r.Use(auth.RequireRole(verifier, auth.RoleGameMaster))
+		r.Post("/timer", th.CreateTimer)
+		r.Get("/timer/{id}", th.GetTimer)
+		r.Get("/timers", th.ListTimers)
+		r.Put("/timer/{id}/pause", th.PauseTimer)
+		r.Put("/timer/{id}/resume", th.ResumeTimer)
+		r.Put("/timer/{id}/stop", th.StopTimer)
+		r.Put("/timer/{id}/modify", th.ModifyTimer)
+		r.Put("/timer/{id}/adjust", th.AdjustTimer)
+		r.With(auth.RequireSession("sessionID")).Post("/sessions/{sessionID}/display-token", tkh.CreateDisplayToken)
+		r.Handle("/debug/vars", expvar.Handler())
+	})
+	s.router.Group(func(r chi.Router) {
+		r.Use(auth.RequireRole(verifier, auth.RoleGameMaster, auth.RoleCustomer))
+		r.Use(auth.RequireSession("sessionID"))
+		r.Get("/sessions/{sessionID}/hints", hh.ListHints)
+	})
+	// WebSocket routes verify their token at upgrade.
 	s.router.Get("/ws/customer/{sessionID}", wsh.HandleCustomerWebSocket)
 	s.router.Get("/ws/gamemaster/{sessionID}", wsh.HandleGameMasterWebSocket)
 }
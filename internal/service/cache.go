@@ -0,0 +1,405 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"timer-microservice/internal/repository"
+	"timer-microservice/internal/types"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+// TimerCacheTTL is how long a live timer stays in Redis without being
+// written. Every change refreshes it.
+const TimerCacheTTL = 24 * time.Hour
+
+// liveStates are the states of the timers kept in Redis. Expired and
+// stopped timers are only kept in the database.
+var liveStates = []types.TimerState{types.StateCreated, types.StateRunning, types.StatePaused}
+
+func isLive(timer *types.Timer) bool {
+	for _, state := range liveStates {
+		if timer.State == state {
+			return true
+		}
+	}
+	return false
+}
+
+// Redis keeps each live timer in a hash under timerKey, holding its version
+// and its JSON, the IDs of a session's live timers in a set under
+// sessionTimersKey and the IDs of every live timer in a set under
+// liveTimersKey.
+const (
+	timerKeyPrefix = "timer:"
+	liveTimersKey  = "timers:live"
+)
+
+func timerKey(id uint) string {
+	return timerKeyPrefix + strconv.FormatUint(uint64(id), 10)
+}
+
+func sessionTimersKey(sessionID string) string {
+	return "session:" + sessionID + ":timers"
+}
+
+// putTimerScript stores a timer record unless Redis already holds a newer
+// version of it, so writers finishing out of order cannot roll the record
+// back. ARGV[5] set to 1 stores it regardless. Records of the old format,
+// plain strings, are replaced.
+var putTimerScript = redis.NewScript(`
+if redis.call('TYPE', KEYS[1]).ok == 'string' then
+	redis.call('DEL', KEYS[1])
+end
+local current = redis.call('HGET', KEYS[1], 'version')
+if ARGV[5] ~= '1' and current and tonumber(current) > tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'version', ARGV[1], 'timer', ARGV[2])
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+redis.call('SADD', KEYS[2], ARGV[3])
+redis.call('PEXPIRE', KEYS[2], ARGV[4])
+redis.call('SADD', KEYS[3], ARGV[3])
+redis.call('PEXPIRE', KEYS[3], ARGV[4])
+return 1
+`)
+
+// timerCache is the Redis copy of the live timers, which serves reads of
+// them. The database stays the source of truth: the cache is written after
+// every successful update, and reads it cannot answer go to the database.
+type timerCache struct {
+	redis *redis.Client
+}
+
+// put stores timer if it is live and evicts it otherwise.
+func (c *timerCache) put(ctx context.Context, timer *types.Timer) error {
+	return c.store(ctx, timer, false)
+}
+
+// replace is put for a record that must win even over a newer version,
+// such as a row restored from a record whose version the database could
+// not take.
+func (c *timerCache) replace(ctx context.Context, timer *types.Timer) error {
+	return c.store(ctx, timer, true)
+}
+
+func (c *timerCache) store(ctx context.Context, timer *types.Timer, force bool) error {
+	if !isLive(timer) {
+		return c.evict(ctx, timer)
+	}
+
+	data, err := json.Marshal(timer)
+	if err != nil {
+		return err
+	}
+	forced := 0
+	if force {
+		forced = 1
+	}
+	keys := []string{timerKey(timer.ID), sessionTimersKey(timer.SessionID), liveTimersKey}
+	return putTimerScript.Run(ctx, c.redis, keys, timer.Version, data, timer.ID, TimerCacheTTL.Milliseconds(), forced).Err()
+}
+
+func (c *timerCache) evict(ctx context.Context, timer *types.Timer) error {
+	_, err := c.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, timerKey(timer.ID))
+		pipe.SRem(ctx, sessionTimersKey(timer.SessionID), timer.ID)
+		pipe.SRem(ctx, liveTimersKey, timer.ID)
+		return nil
+	})
+	return err
+}
+
+// errUnreadableRecord marks a record that cannot be trusted, such as one
+// written before timers were versioned.
+var errUnreadableRecord = errors.New("unreadable timer record")
+
+// get returns the timer stored under key, or nil if there is none.
+func (c *timerCache) get(ctx context.Context, key string) (*types.Timer, error) {
+	fields, err := c.redis.HGetAll(ctx, key).Result()
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "WRONGTYPE") {
+			return nil, fmt.Errorf("%s is not versioned: %w", key, errUnreadableRecord)
+		}
+		return nil, err
+	}
+	return decodeRecord(key, fields)
+}
+
+// decodeRecord decodes the fields of the hash stored under key, or returns
+// nil when there are none.
+func decodeRecord(key string, fields map[string]string) (*types.Timer, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	var timer types.Timer
+	if err := json.Unmarshal([]byte(fields["timer"]), &timer); err != nil {
+		return nil, fmt.Errorf("decode %s: %v: %w", key, err, errUnreadableRecord)
+	}
+	version, err := strconv.ParseUint(fields["version"], 10, 0)
+	if err != nil || uint(version) != timer.Version || key != timerKey(timer.ID) {
+		return nil, fmt.Errorf("%s does not match its key and version: %w", key, errUnreadableRecord)
+	}
+	return &timer, nil
+}
+
+// live returns the timers indexed under indexKey, a session's set or
+// liveTimersKey, newest first. It returns nil when the index is empty or
+// names a timer Redis no longer holds, since the database may then know
+// timers Redis lost.
+func (c *timerCache) live(ctx context.Context, indexKey string) ([]types.Timer, error) {
+	members, err := c.redis.SMembers(ctx, indexKey).Result()
+	if err != nil || len(members) == 0 {
+		return nil, err
+	}
+
+	pipe := c.redis.Pipeline()
+	records := make([]*redis.StringStringMapCmd, len(members))
+	for i, member := range members {
+		records[i] = pipe.HGetAll(ctx, timerKeyPrefix+member)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	timers := make([]types.Timer, 0, len(members))
+	for i, member := range members {
+		timer, err := decodeRecord(timerKeyPrefix+member, records[i].Val())
+		if err != nil || timer == nil {
+			return nil, err
+		}
+		timers = append(timers, *timer)
+	}
+	sort.Slice(timers, func(i, j int) bool {
+		a, b := timers[i], timers[j]
+		return a.CreatedAt.After(b.CreatedAt) || a.CreatedAt.Equal(b.CreatedAt) && a.ID > b.ID
+	})
+	return timers, nil
+}
+
+// scan calls fn with every key matching pattern. It uses SCAN rather than
+// KEYS so that Redis keeps serving other clients while a large keyspace is
+// walked.
+func (c *timerCache) scan(ctx context.Context, pattern string, fn func(key string) error) error {
+	iter := c.redis.Scan(ctx, 0, pattern, 100).Iterator()
+	for iter.Next(ctx) {
+		if err := fn(iter.Val()); err != nil {
+			return err
+		}
+	}
+	return iter.Err()
+}
+
+// RestoreReport counts what RestoreTimers found in Redis and did about it.
+type RestoreReport struct {
+	Scanned     int // versioned timer records found in Redis
+	InSync      int // records at the database version
+	Restored    int // records newer than the database, written back to it
+	Refreshed   int // records older than the database, replaced by it
+	Evicted     int // records of finished or deleted timers
+	Discarded   int // unreadable or unversioned records
+	Repopulated int // live timers missing from Redis
+	Unindexed   int // session index entries without a live timer
+	Failed      int // records left alone after an error
+}
+
+// RestoreTimers reconciles Redis with the database at startup and logs a
+// report. A record only overwrites the database when it is newer than the
+// stored timer, e.g. after the database was restored from a backup; older
+// records are refreshed from the database instead. Without Redis there is
+// nothing to reconcile.
+func (s *TimerService) RestoreTimers() error {
+	if s.cache == nil {
+		return nil
+	}
+	report, err := s.reconcile(context.Background())
+	if err != nil {
+		return err
+	}
+	s.logger.Infow("Reconciled timer cache",
+		"scanned", report.Scanned,
+		"inSync", report.InSync,
+		"restored", report.Restored,
+		"refreshed", report.Refreshed,
+		"evicted", report.Evicted,
+		"discarded", report.Discarded,
+		"repopulated", report.Repopulated,
+		"unindexed", report.Unindexed,
+		"failed", report.Failed)
+	return nil
+}
+
+func (s *TimerService) reconcile(ctx context.Context) (RestoreReport, error) {
+	var report RestoreReport
+	cached := make(map[uint]bool)
+
+	err := s.cache.scan(ctx, timerKeyPrefix+"*", func(key string) error {
+		record, err := s.cache.get(ctx, key)
+		if errors.Is(err, errUnreadableRecord) {
+			s.logger.Warnw("Discarded timer record", "key", key, "error", err)
+			report.Discarded++
+			return s.redis.Del(ctx, key).Err()
+		}
+		if err != nil || record == nil {
+			return err
+		}
+		report.Scanned++
+
+		if s.reconcileRecord(ctx, record, &report) {
+			cached[record.ID] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	live, err := s.repo.FindTimers(repository.TimerQuery{States: liveStates, Sort: repository.SortIDAsc})
+	if err != nil {
+		return report, err
+	}
+	for i := range live {
+		timer := &live[i]
+		if cached[timer.ID] {
+			continue
+		}
+		if err := s.cache.put(ctx, timer); err != nil {
+			return report, err
+		}
+		cached[timer.ID] = true
+		report.Repopulated++
+	}
+
+	unindex := func(key string) error {
+		members, err := s.redis.SMembers(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		for _, member := range members {
+			id, err := strconv.ParseUint(member, 10, 0)
+			if err == nil && cached[uint(id)] {
+				continue
+			}
+			if err := s.redis.SRem(ctx, key, member).Err(); err != nil {
+				return err
+			}
+			report.Unindexed++
+		}
+		return nil
+	}
+	if err := s.cache.scan(ctx, sessionTimersKey("*"), unindex); err != nil {
+		return report, err
+	}
+	return report, unindex(liveTimersKey)
+}
+
+// reconcileRecord brings a cached timer and its database row in line and
+// reports whether the timer is still cached.
+func (s *TimerService) reconcileRecord(ctx context.Context, record *types.Timer, report *RestoreReport) bool {
+	row, err := s.repo.FindByID(record.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		report.Evicted++
+		s.evictTimer(ctx, record)
+		return false
+	}
+	if err != nil {
+		s.logger.Errorw("Failed to load cached timer", "error", err, "timerID", record.ID)
+		report.Failed++
+		return true
+	}
+
+	switch {
+	case record.Version > row.Version:
+		// Write the newer record over the row it was made from. The row
+		// only moves one version on, possibly behind the record when
+		// several writes never reached the database, so the record is
+		// replaced with the row's new version to bring the two level.
+		cachedVersion := record.Version
+		record.Version = row.Version
+		if err := s.repo.Update(record); err != nil {
+			s.logger.Errorw("Failed to restore timer", "error", err, "timerID", record.ID, "version", cachedVersion)
+			report.Failed++
+			return true
+		}
+		s.logger.Infow("Restored timer from Redis", "timerID", record.ID, "databaseVersion", row.Version, "cachedVersion", cachedVersion)
+		report.Restored++
+		if err := s.cache.replace(ctx, record); err != nil {
+			s.logger.Errorw("Failed to persist timer to Redis", "error", err, "timerID", record.ID)
+		}
+		return true
+	case !isLive(row):
+		report.Evicted++
+		s.evictTimer(ctx, row)
+		return false
+	case record.Version == row.Version:
+		report.InSync++
+		return true
+	default:
+		report.Refreshed++
+		s.persistTimer(row)
+		return true
+	}
+}
+
+func (s *TimerService) evictTimer(ctx context.Context, timer *types.Timer) {
+	if s.cache == nil {
+		return
+	}
+	if err := s.cache.evict(ctx, timer); err != nil {
+		s.logger.Errorw("Failed to evict timer from Redis", "error", err, "timerID", timer.ID)
+	}
+}
+
+// cachedTimer returns timer id from Redis, or nil when Redis does not hold
+// it, as for a finished timer, or cannot be read.
+func (s *TimerService) cachedTimer(id uint) *types.Timer {
+	if s.cache == nil {
+		return nil
+	}
+	timer, err := s.cache.get(context.Background(), timerKey(id))
+	if err != nil {
+		s.logger.Warnw("Failed to read timer from Redis", "error", err, "timerID", id)
+		return nil
+	}
+	return timer
+}
+
+// cachedLiveTimers returns the live timers of a session, or of every
+// session when sessionID is empty, from Redis, or nil when Redis cannot
+// answer for them.
+func (s *TimerService) cachedLiveTimers(sessionID string) []types.Timer {
+	if s.cache == nil {
+		return nil
+	}
+	indexKey := liveTimersKey
+	if sessionID != "" {
+		indexKey = sessionTimersKey(sessionID)
+	}
+	timers, err := s.cache.live(context.Background(), indexKey)
+	if err != nil {
+		s.logger.Warnw("Failed to read live timers from Redis", "error", err, "sessionID", sessionID)
+		return nil
+	}
+	return timers
+}
+
+// persistTimer writes a changed timer through to Redis. The database has
+// already been updated, so a failure only leaves Redis behind until the
+// next change or restart. Without Redis there is nothing to write.
+func (s *TimerService) persistTimer(timer *types.Timer) {
+	if s.cache == nil {
+		return
+	}
+	if err := s.cache.put(context.Background(), timer); err != nil {
+		s.logger.Errorw("Failed to persist timer to Redis", "error", err, "timerID", timer.ID)
+	}
+}
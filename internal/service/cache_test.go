@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"timer-microservice/internal/repository"
+	"timer-microservice/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func cachedTimer(id uint, state types.TimerState, version uint) *types.Timer {
+	return &types.Timer{ID: id, SessionID: "room-a", MaxTime: 3600, State: state, Version: version}
+}
+
+func TestPersistTimerKeysByID(t *testing.T) {
+	redisClient, mr := newTestRedis(t)
+	service, _, _ := newTestService(t, redisClient)
+
+	service.persistTimer(cachedTimer(7, types.StateRunning, 2))
+
+	assert.Equal(t, "2", mr.HGet("timer:7", "version"))
+	members, _ := mr.Members("session:room-a:timers")
+	assert.Equal(t, []string{"7"}, members)
+	assert.True(t, mr.TTL("timer:7") > 0)
+
+	// A writer finishing late cannot roll the record back.
+	service.persistTimer(cachedTimer(7, types.StatePaused, 1))
+	assert.Equal(t, "2", mr.HGet("timer:7", "version"))
+
+	// Finished timers leave Redis.
+	service.persistTimer(cachedTimer(7, types.StateStopped, 3))
+	assert.False(t, mr.Exists("timer:7"))
+	assert.False(t, mr.Exists("session:room-a:timers"))
+}
+
+func TestRestoreTimersReconciles(t *testing.T) {
+	redisClient, mr := newTestRedis(t)
+	service, mockRepo, _ := newTestService(t, redisClient)
+
+	service.persistTimer(cachedTimer(1, types.StateRunning, 2))
+	service.persistTimer(cachedTimer(2, types.StatePaused, 3))
+	service.persistTimer(cachedTimer(3, types.StateRunning, 1))
+	service.persistTimer(cachedTimer(4, types.StateRunning, 1))
+	service.persistTimer(cachedTimer(5, types.StateRunning, 1))
+	mr.Set("timer:room-b", `{"SessionID":"room-b","CurrentTime":60}`)
+	mr.SAdd("session:room-a:timers", "99")
+
+	// In sync.
+	mockRepo.On("FindByID", uint(1)).Return(cachedTimer(1, types.StateRunning, 2), nil)
+	// The database lost the pause; the record is written back over the
+	// row it was made from.
+	mockRepo.On("FindByID", uint(2)).Return(cachedTimer(2, types.StateRunning, 2), nil)
+	mockRepo.On("Update", mock.MatchedBy(func(timer *types.Timer) bool {
+		return timer.ID == 2 && timer.Version == 2 && timer.State == types.StatePaused
+	})).Run(func(args mock.Arguments) {
+		args.Get(0).(*types.Timer).Version++
+	}).Return(nil).Once()
+	// The database is ahead of Redis.
+	mockRepo.On("FindByID", uint(3)).Return(cachedTimer(3, types.StatePaused, 4), nil)
+	// Deleted, and stopped.
+	mockRepo.On("FindByID", uint(4)).Return((*types.Timer)(nil), gorm.ErrRecordNotFound)
+	mockRepo.On("FindByID", uint(5)).Return(cachedTimer(5, types.StateStopped, 2), nil)
+	// Live but not in Redis.
+	mockRepo.On("FindTimers", mock.MatchedBy(func(query repository.TimerQuery) bool {
+		return len(query.States) == len(liveStates) && query.Limit == 0
+	})).Return([]types.Timer{
+		*cachedTimer(1, types.StateRunning, 2),
+		*cachedTimer(2, types.StatePaused, 3),
+		*cachedTimer(3, types.StatePaused, 4),
+		*cachedTimer(6, types.StateCreated, 1),
+	}, nil)
+
+	report, err := service.reconcile(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, RestoreReport{
+		Scanned:     5,
+		InSync:      1,
+		Restored:    1,
+		Refreshed:   1,
+		Evicted:     2,
+		Discarded:   1,
+		Repopulated: 1,
+		Unindexed:   1,
+	}, report)
+
+	assert.Equal(t, "3", mr.HGet("timer:2", "version"))
+	assert.Equal(t, "4", mr.HGet("timer:3", "version"))
+	assert.Equal(t, "1", mr.HGet("timer:6", "version"))
+	assert.False(t, mr.Exists("timer:4"))
+	assert.False(t, mr.Exists("timer:5"))
+	assert.False(t, mr.Exists("timer:room-b"))
+	members, _ := mr.Members("session:room-a:timers")
+	assert.ElementsMatch(t, []string{"1", "2", "3", "6"}, members)
+	members, _ = mr.Members("timers:live")
+	assert.ElementsMatch(t, []string{"1", "2", "3", "6"}, members)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLiveTimersAreReadFromRedis(t *testing.T) {
+	redisClient, mr := newTestRedis(t)
+	service, mockRepo, clk := newTestService(t, redisClient)
+
+	for i, sessionID := range []string{"room-a", "room-b", "room-a"} {
+		timer := cachedTimer(uint(i+1), types.StateRunning, 1)
+		timer.SessionID = sessionID
+		timer.CreatedAt = clk.Now().Add(time.Duration(i) * time.Minute)
+		timer.EndsAt = clk.Now().Add(time.Hour)
+		service.persistTimer(timer)
+	}
+
+	// The repository mock has no expectations yet, so any database read
+	// would fail the test.
+	timers, err := service.GetLiveTimers("room-a")
+	assert.NoError(t, err)
+	assert.Equal(t, []uint{3, 1}, timerIDs(timers))
+	assert.Equal(t, int64(3600), timers[0].CurrentTime)
+
+	timers, err = service.GetLiveTimers("")
+	assert.NoError(t, err)
+	assert.Equal(t, []uint{3, 2, 1}, timerIDs(timers))
+
+	timer, err := service.GetTimer(2)
+	assert.NoError(t, err)
+	assert.Equal(t, "room-b", timer.SessionID)
+
+	// Finished timers, sessions without live timers and indexes naming a
+	// lost record are answered by the database.
+	mockRepo.On("FindByID", uint(9)).Return(cachedTimer(9, types.StateStopped, 3), nil).Once()
+	timer, err = service.GetTimer(9)
+	assert.NoError(t, err)
+	assert.Equal(t, types.StateStopped, timer.State)
+
+	mockRepo.On("FindTimers", repository.TimerQuery{SessionID: "room-c", States: liveStates}).Return([]types.Timer{}, nil).Once()
+	timers, err = service.GetLiveTimers("room-c")
+	assert.NoError(t, err)
+	assert.Empty(t, timers)
+
+	mr.Del("timer:1")
+	mockRepo.On("FindTimers", repository.TimerQuery{SessionID: "room-a", States: liveStates}).Return([]types.Timer{*cachedTimer(1, types.StateRunning, 1)}, nil).Once()
+	timers, err = service.GetLiveTimers("room-a")
+	assert.NoError(t, err)
+	assert.Equal(t, []uint{1}, timerIDs(timers))
+
+	mockRepo.AssertExpectations(t)
+}
+
+func timerIDs(timers []types.Timer) []uint {
+	ids := []uint{}
+	for _, timer := range timers {
+		ids = append(ids, timer.ID)
+	}
+	return ids
+}
+
+func TestRestoreTimerBehindByMoreThanOneVersion(t *testing.T) {
+	redisClient, mr := newTestRedis(t)
+	service, mockRepo, _ := newTestService(t, redisClient)
+
+	// Three writes reached Redis but not the database.
+	service.persistTimer(cachedTimer(1, types.StatePaused, 5))
+	mockRepo.On("FindByID", uint(1)).Return(cachedTimer(1, types.StateRunning, 2), nil).Once()
+	mockRepo.On("Update", mock.MatchedBy(func(timer *types.Timer) bool {
+		return timer.ID == 1 && timer.Version == 2 && timer.State == types.StatePaused
+	})).Run(func(args mock.Arguments) {
+		args.Get(0).(*types.Timer).Version++
+	}).Return(nil).Once()
+	mockRepo.On("FindTimers", mock.Anything).Return([]types.Timer{}, nil)
+
+	report, err := service.reconcile(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Restored)
+	assert.Equal(t, "3", mr.HGet("timer:1", "version"))
+
+	// The next start finds the two in sync.
+	mockRepo.On("FindByID", uint(1)).Return(cachedTimer(1, types.StatePaused, 3), nil).Once()
+	report, err = service.reconcile(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.InSync)
+	assert.Zero(t, report.Restored)
+
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"timer-microservice/internal/types"
+	"timer-microservice/internal/validation"
+)
+
+// Kind classifies the errors returned by the services so that every
+// transport reports them the same way.
+type Kind int
+
+const (
+	// KindInternal is a failure of the service itself, e.g. a database
+	// error. Its details are not shown to clients.
+	KindInternal Kind = iota
+	KindNotFound
+	KindInvalidArgument
+	KindConflict
+	KindInvalidState
+	KindPreconditionFailed
+)
+
+// Error is a failure caused by the request rather than by the service.
+type Error struct {
+	Kind    Kind
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// ErrorCode reports the error to WebSocket clients.
+func (e *Error) ErrorCode() types.ErrorCode {
+	switch e.Kind {
+	case KindNotFound:
+		return types.ErrCodeNotFound
+	case KindInvalidArgument:
+		return types.ErrCodeInvalidArgument
+	case KindConflict:
+		return types.ErrCodeConflict
+	case KindInvalidState:
+		return types.ErrCodeInvalidState
+	case KindPreconditionFailed:
+		return types.ErrCodePreconditionFailed
+	default:
+		return types.ErrCodeInternal
+	}
+}
+
+func notFound(format string, args ...interface{}) error {
+	return &Error{Kind: KindNotFound, Message: fmt.Sprintf(format, args...)}
+}
+
+func invalidArgument(format string, args ...interface{}) error {
+	return &Error{Kind: KindInvalidArgument, Message: fmt.Sprintf(format, args...)}
+}
+
+func conflict(format string, args ...interface{}) error {
+	return &Error{Kind: KindConflict, Message: fmt.Sprintf(format, args...)}
+}
+
+func invalidState(format string, args ...interface{}) error {
+	return &Error{Kind: KindInvalidState, Message: fmt.Sprintf(format, args...)}
+}
+
+func preconditionFailed(format string, args ...interface{}) error {
+	return &Error{Kind: KindPreconditionFailed, Message: fmt.Sprintf(format, args...)}
+}
+
+// KindOf returns the kind of err. Rejected input is an invalid argument;
+// errors the services did not classify are internal.
+func KindOf(err error) Kind {
+	var serviceErr *Error
+	var stateErr *InvalidStateError
+	var validationErr *validation.Error
+	switch {
+	case errors.As(err, &serviceErr):
+		return serviceErr.Kind
+	case errors.As(err, &stateErr):
+		return KindInvalidState
+	case errors.As(err, &validationErr):
+		return KindInvalidArgument
+	default:
+		return KindInternal
+	}
+}
+
+// InvalidStateError is returned when an operation is not allowed in the
+// timer's current state, e.g. pausing a timer that has already expired.
+type InvalidStateError struct {
+	TimerID uint
+	State   types.TimerState
+	Action  string
+}
+
+func (e *InvalidStateError) Error() string {
+	return fmt.Sprintf("cannot %s timer %d: timer is %s", e.Action, e.TimerID, e.State)
+}
+
+// ErrorCode reports the error to WebSocket clients as invalid_state.
+func (e *InvalidStateError) ErrorCode() types.ErrorCode {
+	return types.ErrCodeInvalidState
+}
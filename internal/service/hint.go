@@ -0,0 +1,124 @@
+package service
+
+import (
+	"errors"
+
+	"timer-microservice/internal/clock"
+	"timer-microservice/internal/repository"
+	"timer-microservice/internal/types"
+	"timer-microservice/internal/websocket"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type HintService struct {
+	repo      repository.HintRepository
+	timerRepo repository.TimerRepository
+	timers    TimerServiceInterface
+	logger    *zap.SugaredLogger
+	wsHandler websocket.HandlerInterface
+	clock     clock.Clock
+	penalty   int64
+}
+
+type HintServiceInterface interface {
+	SendHint(sessionID string, req types.HintRequest) (*types.Hint, error)
+	GetHints(sessionID string) ([]types.Hint, error)
+}
+
+// NewHintService returns a HintService charging penalty seconds per hint
+// unless a request overrides it.
+func NewHintService(repo repository.HintRepository, timerRepo repository.TimerRepository, timers TimerServiceInterface, logger *zap.SugaredLogger, wsHandler websocket.HandlerInterface, clk clock.Clock, penalty int64) HintServiceInterface {
+	return &HintService{
+		repo:      repo,
+		timerRepo: timerRepo,
+		timers:    timers,
+		logger:    logger,
+		wsHandler: wsHandler,
+		clock:     clk,
+		penalty:   penalty,
+	}
+}
+
+// SendHint records a hint for the session, counts it on the timer, applies
+// the penalty and pushes the hint to the session's customer screen.
+func (s *HintService) SendHint(sessionID string, req types.HintRequest) (*types.Hint, error) {
+	if req.Text == "" {
+		return nil, invalidArgument("hint text is required")
+	}
+
+	timerID, err := s.resolveTimer(sessionID, req.TimerID)
+	if err != nil {
+		s.logger.Errorw("Failed to find timer for hint", "error", err, "sessionID", sessionID)
+		return nil, err
+	}
+
+	penalty := s.penalty
+	if req.Penalty != nil {
+		penalty = *req.Penalty
+	}
+	if penalty < 0 {
+		return nil, invalidArgument("hint penalty must not be negative, got %d", penalty)
+	}
+
+	// The hint is saved before the penalty is charged, and deleted again if
+	// the timer refuses it, so a failure never charges for a lost hint.
+	hint := &types.Hint{
+		SessionID: sessionID,
+		TimerID:   timerID,
+		Text:      req.Text,
+		Penalty:   penalty,
+		CreatedAt: s.clock.Now(),
+	}
+	if err := s.repo.Create(hint); err != nil {
+		s.logger.Errorw("Failed to save hint", "error", err, "sessionID", sessionID)
+		return nil, err
+	}
+
+	if _, err := s.timers.RecordHint(timerID, penalty); err != nil {
+		if deleteErr := s.repo.Delete(hint.ID); deleteErr != nil {
+			s.logger.Errorw("Failed to delete hint the timer refused", "error", deleteErr, "hintID", hint.ID, "sessionID", sessionID)
+		}
+		return nil, err
+	}
+
+	s.logger.Infow("Hint sent", "sessionID", sessionID, "timerID", timerID, "penalty", penalty)
+	s.wsHandler.SendHint(hint)
+
+	return hint, nil
+}
+
+func (s *HintService) GetHints(sessionID string) ([]types.Hint, error) {
+	return s.repo.FindBySessionID(sessionID)
+}
+
+// resolveTimer checks that timerID belongs to the session, or picks the
+// session's newest running or paused timer when it is zero.
+func (s *HintService) resolveTimer(sessionID string, timerID uint) (uint, error) {
+	if timerID != 0 {
+		timer, err := s.timerRepo.FindByID(timerID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, notFound("timer %d not found", timerID)
+		}
+		if err != nil {
+			return 0, err
+		}
+		if timer.SessionID != sessionID {
+			return 0, invalidArgument("timer %d does not belong to session %s", timerID, sessionID)
+		}
+		return timer.ID, nil
+	}
+
+	timers, err := s.timerRepo.FindBySessionID(sessionID)
+	if err != nil {
+		return 0, err
+	}
+	// The timers come newest first.
+	for _, timer := range timers {
+		if timer.State == types.StateRunning || timer.State == types.StatePaused {
+			return timer.ID, nil
+		}
+	}
+	return 0, invalidState("no running or paused timer for session %s", sessionID)
+}
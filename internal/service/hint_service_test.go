@@ -0,0 +1,258 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"timer-microservice/internal/clock/clocktest"
+	"timer-microservice/internal/types"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+// MockHintRepository is a mock of HintRepository
+type MockHintRepository struct {
+	mock.Mock
+}
+
+func (m *MockHintRepository) Create(hint *types.Hint) error {
+	args := m.Called(hint)
+	return args.Error(0)
+}
+
+func (m *MockHintRepository) Delete(id uint) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockHintRepository) FindBySessionID(sessionID string) ([]types.Hint, error) {
+	args := m.Called(sessionID)
+	return args.Get(0).([]types.Hint), args.Error(1)
+}
+
+func TestSendHintAppliesConfiguredPenalty(t *testing.T) {
+	mockRepo := new(MockTimerRepository)
+	mockHintRepo := new(MockHintRepository)
+	mockRedis := redis.NewClient(&redis.Options{})
+	mockWS := new(MockWebSocketHandler)
+	logger, _ := zap.NewDevelopment()
+	sugar := logger.Sugar()
+
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	timerService := NewTimerService(mockRepo, sugar, mockRedis, mockWS, clk)
+	hintService := NewHintService(mockHintRepo, mockRepo, timerService, sugar, mockWS, clk, 120)
+
+	runningTimer := &types.Timer{
+		ID:        1,
+		SessionID: "session1",
+		MaxTime:   3600,
+		State:     types.StateRunning,
+		StartedAt: clk.Now().Add(-40 * time.Minute),
+		EndsAt:    clk.Now().Add(20 * time.Minute),
+	}
+
+	// No timer ID: the session's current timer is used.
+	mockRepo.On("FindBySessionID", "session1").Return([]types.Timer{*runningTimer}, nil)
+	mockRepo.On("FindByID", uint(1)).Return(runningTimer, nil)
+	mockRepo.On("Update", mock.MatchedBy(func(timer *types.Timer) bool {
+		return timer.HintCount == 1 && timer.CurrentTime == 1080
+	})).Return(nil).Once()
+	mockWS.On("BroadcastTimerAdjusted", mock.AnythingOfType("*types.Timer"), mock.MatchedBy(func(adjustment types.TimerAdjustment) bool {
+		return adjustment.Delta == -120 && adjustment.Reason == "hint"
+	})).Return().Once()
+	mockHintRepo.On("Create", mock.MatchedBy(func(hint *types.Hint) bool {
+		return hint.SessionID == "session1" && hint.TimerID == 1 && hint.Penalty == 120
+	})).Return(nil).Once()
+	mockWS.On("SendHint", mock.MatchedBy(func(hint *types.Hint) bool {
+		return hint.Text == "Look under the rug"
+	})).Return().Once()
+
+	hint, err := hintService.SendHint("session1", types.HintRequest{Text: "Look under the rug"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, clk.Now(), hint.CreatedAt)
+
+	mockRepo.AssertExpectations(t)
+	mockHintRepo.AssertExpectations(t)
+	mockWS.AssertExpectations(t)
+}
+
+func TestSendHintWithoutPenalty(t *testing.T) {
+	mockRepo := new(MockTimerRepository)
+	mockHintRepo := new(MockHintRepository)
+	mockRedis := redis.NewClient(&redis.Options{})
+	mockWS := new(MockWebSocketHandler)
+	logger, _ := zap.NewDevelopment()
+	sugar := logger.Sugar()
+
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	timerService := NewTimerService(mockRepo, sugar, mockRedis, mockWS, clk)
+	hintService := NewHintService(mockHintRepo, mockRepo, timerService, sugar, mockWS, clk, 120)
+
+	runningTimer := &types.Timer{
+		ID:        1,
+		SessionID: "session1",
+		MaxTime:   3600,
+		State:     types.StateRunning,
+		EndsAt:    clk.Now().Add(20 * time.Minute),
+	}
+
+	noPenalty := int64(0)
+	mockRepo.On("FindByID", uint(1)).Return(runningTimer, nil)
+	mockRepo.On("Update", mock.MatchedBy(func(timer *types.Timer) bool {
+		return timer.HintCount == 1 && timer.CurrentTime == 1200 && len(timer.Adjustments) == 0
+	})).Return(nil).Once()
+	mockWS.On("BroadcastTimerUpdate", mock.AnythingOfType("*types.Timer")).Return().Once()
+	mockHintRepo.On("Create", mock.AnythingOfType("*types.Hint")).Return(nil).Once()
+	mockWS.On("SendHint", mock.AnythingOfType("*types.Hint")).Return().Once()
+
+	_, err := hintService.SendHint("session1", types.HintRequest{TimerID: 1, Text: "Free hint", Penalty: &noPenalty})
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+	mockWS.AssertExpectations(t)
+}
+
+func TestSendHintRejectsTimerFromOtherSession(t *testing.T) {
+	mockRepo := new(MockTimerRepository)
+	mockHintRepo := new(MockHintRepository)
+	mockRedis := redis.NewClient(&redis.Options{})
+	mockWS := new(MockWebSocketHandler)
+	logger, _ := zap.NewDevelopment()
+	sugar := logger.Sugar()
+
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	timerService := NewTimerService(mockRepo, sugar, mockRedis, mockWS, clk)
+	hintService := NewHintService(mockHintRepo, mockRepo, timerService, sugar, mockWS, clk, 0)
+
+	mockRepo.On("FindByID", uint(2)).Return(&types.Timer{ID: 2, SessionID: "session2", State: types.StateRunning}, nil)
+
+	_, err := hintService.SendHint("session1", types.HintRequest{TimerID: 2, Text: "Wrong room"})
+
+	assert.Error(t, err)
+	mockHintRepo.AssertNotCalled(t, "Create", mock.Anything)
+	mockWS.AssertNotCalled(t, "SendHint", mock.Anything)
+}
+
+func TestSendHintPicksNewestRunningOrPausedTimer(t *testing.T) {
+	mockRepo := new(MockTimerRepository)
+	mockHintRepo := new(MockHintRepository)
+	mockRedis := redis.NewClient(&redis.Options{})
+	mockWS := new(MockWebSocketHandler)
+	logger, _ := zap.NewDevelopment()
+	sugar := logger.Sugar()
+
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	timerService := NewTimerService(mockRepo, sugar, mockRedis, mockWS, clk)
+	hintService := NewHintService(mockHintRepo, mockRepo, timerService, sugar, mockWS, clk, 0)
+
+	pausedAt := clk.Now().Add(-time.Minute)
+	pausedTimer := &types.Timer{
+		ID:        2,
+		SessionID: "session1",
+		MaxTime:   3600,
+		IsPaused:  true,
+		State:     types.StatePaused,
+		EndsAt:    pausedAt.Add(20 * time.Minute),
+		PausedAt:  &pausedAt,
+	}
+
+	// The expired and created timers are newer, but cannot take a hint.
+	mockRepo.On("FindBySessionID", "session1").Return([]types.Timer{
+		{ID: 4, SessionID: "session1", State: types.StateExpired},
+		{ID: 3, SessionID: "session1", State: types.StateCreated},
+		*pausedTimer,
+		{ID: 1, SessionID: "session1", State: types.StateRunning},
+	}, nil)
+	mockRepo.On("FindByID", uint(2)).Return(pausedTimer, nil)
+	mockRepo.On("Update", mock.AnythingOfType("*types.Timer")).Return(nil).Once()
+	mockWS.On("BroadcastTimerUpdate", mock.AnythingOfType("*types.Timer")).Return().Once()
+	mockHintRepo.On("Create", mock.MatchedBy(func(hint *types.Hint) bool {
+		return hint.TimerID == 2
+	})).Return(nil).Once()
+	mockWS.On("SendHint", mock.AnythingOfType("*types.Hint")).Return().Once()
+
+	_, err := hintService.SendHint("session1", types.HintRequest{Text: "Try the bookshelf"})
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+	mockHintRepo.AssertExpectations(t)
+}
+
+func TestSendHintWithoutLiveTimer(t *testing.T) {
+	mockRepo := new(MockTimerRepository)
+	mockHintRepo := new(MockHintRepository)
+	mockRedis := redis.NewClient(&redis.Options{})
+	mockWS := new(MockWebSocketHandler)
+	logger, _ := zap.NewDevelopment()
+	sugar := logger.Sugar()
+
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	timerService := NewTimerService(mockRepo, sugar, mockRedis, mockWS, clk)
+	hintService := NewHintService(mockHintRepo, mockRepo, timerService, sugar, mockWS, clk, 0)
+
+	mockRepo.On("FindBySessionID", "session1").Return([]types.Timer{
+		{ID: 2, SessionID: "session1", State: types.StateExpired},
+		{ID: 1, SessionID: "session1", State: types.StateStopped},
+	}, nil)
+
+	_, err := hintService.SendHint("session1", types.HintRequest{Text: "Too late"})
+
+	assert.Equal(t, KindInvalidState, KindOf(err))
+	mockHintRepo.AssertNotCalled(t, "Create", mock.Anything)
+	mockWS.AssertNotCalled(t, "SendHint", mock.Anything)
+}
+
+func TestSendHintDoesNotChargeWhenSaveFails(t *testing.T) {
+	mockRepo := new(MockTimerRepository)
+	mockHintRepo := new(MockHintRepository)
+	mockRedis := redis.NewClient(&redis.Options{})
+	mockWS := new(MockWebSocketHandler)
+	logger, _ := zap.NewDevelopment()
+	sugar := logger.Sugar()
+
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	timerService := NewTimerService(mockRepo, sugar, mockRedis, mockWS, clk)
+	hintService := NewHintService(mockHintRepo, mockRepo, timerService, sugar, mockWS, clk, 120)
+
+	mockRepo.On("FindByID", uint(1)).Return(&types.Timer{ID: 1, SessionID: "session1", MaxTime: 3600, State: types.StateRunning, EndsAt: clk.Now().Add(20 * time.Minute)}, nil)
+	mockHintRepo.On("Create", mock.AnythingOfType("*types.Hint")).Return(errors.New("disk full")).Once()
+
+	_, err := hintService.SendHint("session1", types.HintRequest{TimerID: 1, Text: "Look under the rug"})
+
+	assert.EqualError(t, err, "disk full")
+	mockRepo.AssertNotCalled(t, "Update", mock.Anything)
+	mockWS.AssertNotCalled(t, "BroadcastTimerAdjusted", mock.Anything, mock.Anything)
+	mockWS.AssertNotCalled(t, "SendHint", mock.Anything)
+}
+
+func TestSendHintDeletesHintTheTimerRefuses(t *testing.T) {
+	mockRepo := new(MockTimerRepository)
+	mockHintRepo := new(MockHintRepository)
+	mockRedis := redis.NewClient(&redis.Options{})
+	mockWS := new(MockWebSocketHandler)
+	logger, _ := zap.NewDevelopment()
+	sugar := logger.Sugar()
+
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	timerService := NewTimerService(mockRepo, sugar, mockRedis, mockWS, clk)
+	hintService := NewHintService(mockHintRepo, mockRepo, timerService, sugar, mockWS, clk, 120)
+
+	// The timer ran out between choosing it and charging the penalty.
+	mockRepo.On("FindByID", uint(1)).Return(&types.Timer{ID: 1, SessionID: "session1", State: types.StateExpired}, nil)
+	mockHintRepo.On("Create", mock.AnythingOfType("*types.Hint")).Run(func(args mock.Arguments) {
+		args.Get(0).(*types.Hint).ID = 5
+	}).Return(nil).Once()
+	mockHintRepo.On("Delete", uint(5)).Return(nil).Once()
+
+	_, err := hintService.SendHint("session1", types.HintRequest{TimerID: 1, Text: "Too late"})
+
+	var stateErr *InvalidStateError
+	assert.ErrorAs(t, err, &stateErr)
+	mockHintRepo.AssertExpectations(t)
+	mockWS.AssertNotCalled(t, "SendHint", mock.Anything)
+}
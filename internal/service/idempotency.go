@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"timer-microservice/internal/clock"
+	"timer-microservice/internal/types"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// IdempotencyTTL is how long an idempotency key is remembered.
+const IdempotencyTTL = 24 * time.Hour
+
+// idempotencyPendingTTL bounds how long a key is held by a request in
+// flight, so a crash between the claim and the final record only blocks
+// retries briefly.
+const idempotencyPendingTTL = time.Minute
+
+// idempotencyRecordAttempts is how many times the final record is written
+// before the error is returned.
+const idempotencyRecordAttempts = 3
+
+// idempotencyRecord is stored under an idempotency key. Timer is
+// nil while the first request with the key is still being processed.
+type idempotencyRecord struct {
+	Fingerprint string       `json:"fingerprint"`
+	Timer       *types.Timer `json:"timer,omitempty"`
+}
+
+func idempotencyKey(sessionID, key string) string {
+	return "idempotency:timer:" + sessionID + ":" + key
+}
+
+// fingerprint identifies the content of a create request, so that a key
+// reused for another request can be told apart from a retry.
+func fingerprint(req types.TimerRequest) string {
+	if req.Mode == "" {
+		req.Mode = types.ModeCountdown
+	}
+	data, _ := json.Marshal(req)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// idempotencyStore remembers idempotency records for IdempotencyTTL.
+type idempotencyStore interface {
+	// claim stores record under key for idempotencyPendingTTL unless the
+	// key is taken, and reports whether it did.
+	claim(ctx context.Context, key string, record []byte) (bool, error)
+	set(ctx context.Context, key string, record []byte) error
+	// get returns the record under key, or nil if there is none.
+	get(ctx context.Context, key string) ([]byte, error)
+	release(ctx context.Context, key string) error
+}
+
+// redisIdempotencyStore shares idempotency keys between the instances
+// using the same Redis.
+type redisIdempotencyStore struct {
+	redis *redis.Client
+}
+
+func (r *redisIdempotencyStore) claim(ctx context.Context, key string, record []byte) (bool, error) {
+	return r.redis.SetNX(ctx, key, record, idempotencyPendingTTL).Result()
+}
+
+func (r *redisIdempotencyStore) set(ctx context.Context, key string, record []byte) error {
+	return r.redis.Set(ctx, key, record, IdempotencyTTL).Err()
+}
+
+func (r *redisIdempotencyStore) get(ctx context.Context, key string) ([]byte, error) {
+	data, err := r.redis.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (r *redisIdempotencyStore) release(ctx context.Context, key string) error {
+	return r.redis.Del(ctx, key).Err()
+}
+
+// memoryIdempotencyStore keeps idempotency keys in process memory, for a
+// single instance running without Redis.
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	clock   clock.Clock
+	records map[string]memoryIdempotencyRecord
+}
+
+type memoryIdempotencyRecord struct {
+	data    []byte
+	expires time.Time
+}
+
+func newMemoryIdempotencyStore(clk clock.Clock) *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{clock: clk, records: make(map[string]memoryIdempotencyRecord)}
+}
+
+// claim also forgets the expired keys, so the store only grows with the
+// keys used within IdempotencyTTL.
+func (m *memoryIdempotencyStore) claim(ctx context.Context, key string, record []byte) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.clock.Now()
+	for k, r := range m.records {
+		if !now.Before(r.expires) {
+			delete(m.records, k)
+		}
+	}
+	if _, ok := m.records[key]; ok {
+		return false, nil
+	}
+	m.records[key] = memoryIdempotencyRecord{data: record, expires: now.Add(idempotencyPendingTTL)}
+	return true, nil
+}
+
+func (m *memoryIdempotencyStore) set(ctx context.Context, key string, record []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.records[key] = memoryIdempotencyRecord{data: record, expires: m.clock.Now().Add(IdempotencyTTL)}
+	return nil
+}
+
+func (m *memoryIdempotencyStore) get(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record, ok := m.records[key]
+	if !ok || !m.clock.Now().Before(record.expires) {
+		return nil, nil
+	}
+	return record.data, nil
+}
+
+func (m *memoryIdempotencyStore) release(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.records, key)
+	return nil
+}
+
+// CreateTimerIdempotent creates a timer at most once per key and session.
+// Retrying with the same key and request returns the timer as it was
+// created, with replayed set; reusing the key for another request, or while
+// the first one is in flight, is a conflict. Keys are kept for
+// IdempotencyTTL; a key whose request never finished is freed after
+// idempotencyPendingTTL.
+func (s *TimerService) CreateTimerIdempotent(key string, req types.TimerRequest) (*types.Timer, bool, error) {
+	ctx := context.Background()
+	storeKey := idempotencyKey(req.SessionID, key)
+	digest := fingerprint(req)
+
+	pending, err := json.Marshal(idempotencyRecord{Fingerprint: digest})
+	if err != nil {
+		return nil, false, err
+	}
+	claimed, err := s.idempotency.claim(ctx, storeKey, pending)
+	if err != nil {
+		s.logger.Errorw("Failed to claim idempotency key", "error", err, "sessionID", req.SessionID)
+		return nil, false, err
+	}
+	if !claimed {
+		timer, err := s.replay(ctx, storeKey, key, digest)
+		if err != nil {
+			return nil, false, err
+		}
+		s.logger.Infow("Replayed timer creation", "timerID", timer.ID, "sessionID", req.SessionID)
+		return timer, true, nil
+	}
+
+	timer, err := s.CreateTimer(req)
+	if err != nil {
+		// Nothing was created, so the client may retry with the same key.
+		s.idempotency.release(ctx, storeKey)
+		return nil, false, err
+	}
+
+	done, err := json.Marshal(idempotencyRecord{Fingerprint: digest, Timer: timer})
+	if err != nil {
+		return nil, false, err
+	}
+	for attempt := 1; attempt <= idempotencyRecordAttempts; attempt++ {
+		if err = s.idempotency.set(ctx, storeKey, done); err == nil {
+			return timer, false, nil
+		}
+	}
+	// The timer exists, but a retry could not be replayed; the pending
+	// claim expires after idempotencyPendingTTL.
+	s.logger.Errorw("Failed to record idempotency key", "error", err, "timerID", timer.ID)
+	return nil, false, err
+}
+
+func (s *TimerService) replay(ctx context.Context, storeKey, key, digest string) (*types.Timer, error) {
+	data, err := s.idempotency.get(ctx, storeKey)
+	if err != nil {
+		s.logger.Errorw("Failed to read idempotency key", "error", err)
+		return nil, err
+	}
+	if data == nil {
+		// The first request failed and released the key in the meantime.
+		return nil, conflict("request with idempotency key %q is still in progress", key)
+	}
+
+	var record idempotencyRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		s.logger.Errorw("Failed to unmarshal idempotency record", "error", err)
+		return nil, err
+	}
+	switch {
+	case record.Fingerprint != digest:
+		return nil, conflict("idempotency key %q was already used for a different request", key)
+	case record.Timer == nil:
+		return nil, conflict("request with idempotency key %q is still in progress", key)
+	}
+	return record.Timer, nil
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"timer-microservice/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCreateTimerIdempotentReplays(t *testing.T) {
+	redisClient, mr := newTestRedis(t)
+	service, mockRepo, _ := newTestService(t, redisClient)
+
+	mockRepo.On("Create", mock.AnythingOfType("*types.Timer")).Run(func(args mock.Arguments) {
+		args.Get(0).(*types.Timer).ID = 7
+	}).Return(nil).Once()
+
+	req := types.TimerRequest{SessionID: "room-a", MaxTime: 3600}
+	timer, replayed, err := service.CreateTimerIdempotent("booking-42", req)
+	assert.NoError(t, err)
+	assert.False(t, replayed)
+	assert.Equal(t, uint(7), timer.ID)
+
+	// The retry gets the original timer back without creating another.
+	again, replayed, err := service.CreateTimerIdempotent("booking-42", req)
+	assert.NoError(t, err)
+	assert.True(t, replayed)
+	assert.Equal(t, uint(7), again.ID)
+	assert.Equal(t, timer.EndsAt, again.EndsAt)
+
+	// An explicit countdown is the same request as the default mode.
+	_, replayed, err = service.CreateTimerIdempotent("booking-42", types.TimerRequest{SessionID: "room-a", MaxTime: 3600, Mode: types.ModeCountdown})
+	assert.NoError(t, err)
+	assert.True(t, replayed)
+
+	ttl := mr.TTL(idempotencyKey("room-a", "booking-42"))
+	assert.True(t, ttl > 0 && ttl <= IdempotencyTTL, ttl)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateTimerIdempotentConflicts(t *testing.T) {
+	redisClient, mr := newTestRedis(t)
+	service, mockRepo, _ := newTestService(t, redisClient)
+
+	mockRepo.On("Create", mock.AnythingOfType("*types.Timer")).Return(nil).Once()
+
+	_, _, err := service.CreateTimerIdempotent("booking-42", types.TimerRequest{SessionID: "room-a", MaxTime: 3600})
+	assert.NoError(t, err)
+
+	_, _, err = service.CreateTimerIdempotent("booking-42", types.TimerRequest{SessionID: "room-a", MaxTime: 1800})
+	assert.Equal(t, KindConflict, KindOf(err))
+
+	// A key still held by a request in flight.
+	pending := `{"fingerprint":"` + fingerprint(types.TimerRequest{SessionID: "room-b", MaxTime: 60}) + `"}`
+	assert.NoError(t, mr.Set(idempotencyKey("room-b", "booking-43"), pending))
+	_, _, err = service.CreateTimerIdempotent("booking-43", types.TimerRequest{SessionID: "room-b", MaxTime: 60})
+	assert.Equal(t, KindConflict, KindOf(err))
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateTimerIdempotentReleasesKeyOnFailure(t *testing.T) {
+	redisClient, mr := newTestRedis(t)
+	service, mockRepo, _ := newTestService(t, redisClient)
+
+	mockRepo.On("Create", mock.AnythingOfType("*types.Timer")).Return(errors.New("connection refused")).Once()
+	mockRepo.On("Create", mock.AnythingOfType("*types.Timer")).Return(nil).Once()
+
+	req := types.TimerRequest{SessionID: "room-a", MaxTime: 3600}
+	_, _, err := service.CreateTimerIdempotent("booking-42", req)
+	assert.Error(t, err)
+	assert.False(t, mr.Exists(idempotencyKey("room-a", "booking-42")))
+
+	_, replayed, err := service.CreateTimerIdempotent("booking-42", req)
+	assert.NoError(t, err)
+	assert.False(t, replayed)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateTimerIdempotentWithoutRedis(t *testing.T) {
+	service, mockRepo, clk := newTestService(t, nil)
+
+	mockRepo.On("Create", mock.AnythingOfType("*types.Timer")).Run(func(args mock.Arguments) {
+		args.Get(0).(*types.Timer).ID = 7
+	}).Return(nil).Once()
+
+	req := types.TimerRequest{SessionID: "room-a", MaxTime: 3600}
+	_, replayed, err := service.CreateTimerIdempotent("booking-42", req)
+	assert.NoError(t, err)
+	assert.False(t, replayed)
+
+	again, replayed, err := service.CreateTimerIdempotent("booking-42", req)
+	assert.NoError(t, err)
+	assert.True(t, replayed)
+	assert.Equal(t, uint(7), again.ID)
+
+	_, _, err = service.CreateTimerIdempotent("booking-42", types.TimerRequest{SessionID: "room-a", MaxTime: 1800})
+	assert.Equal(t, KindConflict, KindOf(err))
+
+	// Keys are forgotten after IdempotencyTTL.
+	mockRepo.On("Create", mock.AnythingOfType("*types.Timer")).Return(nil).Once()
+	clk.Advance(IdempotencyTTL)
+	_, replayed, err = service.CreateTimerIdempotent("booking-42", req)
+	assert.NoError(t, err)
+	assert.False(t, replayed)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateTimerIdempotentPendingKeyExpires(t *testing.T) {
+	redisClient, mr := newTestRedis(t)
+	service, mockRepo, _ := newTestService(t, redisClient)
+
+	storeKey := idempotencyKey("room-a", "booking-42")
+	mockRepo.On("Create", mock.AnythingOfType("*types.Timer")).Run(func(args mock.Arguments) {
+		// While the timer is being created the key is only held briefly.
+		ttl := mr.TTL(storeKey)
+		assert.True(t, ttl > 0 && ttl <= idempotencyPendingTTL, ttl)
+	}).Return(nil).Once()
+
+	_, _, err := service.CreateTimerIdempotent("booking-42", types.TimerRequest{SessionID: "room-a", MaxTime: 3600})
+	assert.NoError(t, err)
+	assert.True(t, mr.TTL(storeKey) > idempotencyPendingTTL)
+
+	// A claim left behind by a crashed request frees the key.
+	pending := `{"fingerprint":"` + fingerprint(types.TimerRequest{SessionID: "room-b", MaxTime: 60}) + `"}`
+	assert.NoError(t, mr.Set(idempotencyKey("room-b", "booking-43"), pending))
+	mr.SetTTL(idempotencyKey("room-b", "booking-43"), idempotencyPendingTTL)
+	mr.FastForward(idempotencyPendingTTL)
+
+	mockRepo.On("Create", mock.AnythingOfType("*types.Timer")).Return(nil).Once()
+	_, replayed, err := service.CreateTimerIdempotent("booking-43", types.TimerRequest{SessionID: "room-b", MaxTime: 60})
+	assert.NoError(t, err)
+	assert.False(t, replayed)
+
+	mockRepo.AssertExpectations(t)
+}
+
+// flakyIdempotencyStore fails the first n writes of the final record,
+// where n is failures.
+type flakyIdempotencyStore struct {
+	idempotencyStore
+	failures int
+	sets     int
+}
+
+func (f *flakyIdempotencyStore) set(ctx context.Context, key string, record []byte) error {
+	f.sets++
+	if f.sets <= f.failures {
+		return errors.New("connection reset")
+	}
+	return f.idempotencyStore.set(ctx, key, record)
+}
+
+func TestCreateTimerIdempotentRetriesRecord(t *testing.T) {
+	service, mockRepo, _ := newTestService(t, nil)
+	store := &flakyIdempotencyStore{idempotencyStore: service.idempotency, failures: idempotencyRecordAttempts - 1}
+	service.idempotency = store
+
+	mockRepo.On("Create", mock.AnythingOfType("*types.Timer")).Return(nil).Once()
+
+	req := types.TimerRequest{SessionID: "room-a", MaxTime: 3600}
+	_, _, err := service.CreateTimerIdempotent("booking-42", req)
+	assert.NoError(t, err)
+	assert.Equal(t, idempotencyRecordAttempts, store.sets)
+
+	_, replayed, err := service.CreateTimerIdempotent("booking-42", req)
+	assert.NoError(t, err)
+	assert.True(t, replayed)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateTimerIdempotentReturnsRecordError(t *testing.T) {
+	service, mockRepo, _ := newTestService(t, nil)
+	service.idempotency = &flakyIdempotencyStore{idempotencyStore: service.idempotency, failures: idempotencyRecordAttempts}
+
+	mockRepo.On("Create", mock.AnythingOfType("*types.Timer")).Return(nil).Once()
+
+	timer, _, err := service.CreateTimerIdempotent("booking-42", types.TimerRequest{SessionID: "room-a", MaxTime: 3600})
+	assert.Error(t, err)
+	assert.Nil(t, timer)
+
+	mockRepo.AssertExpectations(t)
+}
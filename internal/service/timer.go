@@ -2,15 +2,18 @@ package service
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
+	"errors"
 	"time"
+
+	"timer-microservice/internal/clock"
+	"timer-microservice/internal/lease"
 	"timer-microservice/internal/repository"
 	"timer-microservice/internal/types"
 	"timer-microservice/internal/websocket"
 
 	"github.com/go-redis/redis/v8"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 type TimerService struct {
@@ -19,45 +22,122 @@ type TimerService struct {
 	redis     *redis.Client
 	stopChan  chan struct{}
 	wsHandler websocket.HandlerInterface
+	clock     clock.Clock
+
+	// cache is nil when the service runs without Redis; idempotency keys
+	// are then kept in memory.
+	cache       *timerCache
+	idempotency idempotencyStore
+
+	// tickLease elects the instance that runs the tick loop; leading is
+	// whether this one held it on the last tick. Without a lease every
+	// instance ticks.
+	tickLease *lease.Lease
+	leading   bool
 }
 
 type TimerServiceInterface interface {
 	StartTimerUpdates()
 	StopTimerUpdates()
-	CreateTimer(sessionID string, maxTime int64) (*types.Timer, error)
-	PauseTimer(id uint) (*types.Timer, error)
-	ResumeTimer(id uint) (*types.Timer, error)
-	StopTimer(id uint) error
-	ModifyTimer(id uint, newMaxTime int64) (*types.Timer, error)
+	CreateTimer(req types.TimerRequest) (*types.Timer, error)
+	CreateTimerIdempotent(key string, req types.TimerRequest) (*types.Timer, bool, error)
+	PauseTimer(id, ifMatch uint) (*types.Timer, error)
+	ResumeTimer(id, ifMatch uint) (*types.Timer, error)
+	StopTimer(id, ifMatch uint) (*types.Timer, error)
+	ModifyTimer(id, ifMatch uint, newMaxTime int64) (*types.Timer, error)
+	AdjustTimer(id, ifMatch uint, delta int64, reason string) (*types.Timer, error)
+	RecordHint(id uint, penalty int64) (*types.Timer, error)
+	GetTimer(id uint) (*types.Timer, error)
+	ListTimers(query repository.TimerQuery) ([]types.Timer, *repository.TimerCursor, error)
 	GetAllTimers() ([]types.Timer, error)
+	GetLiveTimers(sessionID string) ([]types.Timer, error)
 	RestoreTimers() error
+	SetTickLease(tickLease *lease.Lease)
 }
 
-func NewTimerService(repo repository.TimerRepository, logger *zap.SugaredLogger, redisClient *redis.Client, wsHandler websocket.HandlerInterface) TimerServiceInterface {
-	return &TimerService{
-		repo:      repo,
-		logger:    logger,
-		redis:     redisClient,
-		stopChan:  make(chan struct{}),
-		wsHandler: wsHandler,
+// NewTimerService returns a timer service storing timers in repo. redisClient
+// may be nil for a single instance without Redis.
+func NewTimerService(repo repository.TimerRepository, logger *zap.SugaredLogger, redisClient *redis.Client, wsHandler websocket.HandlerInterface, clk clock.Clock) TimerServiceInterface {
+	s := &TimerService{
+		repo:        repo,
+		logger:      logger,
+		redis:       redisClient,
+		stopChan:    make(chan struct{}),
+		wsHandler:   wsHandler,
+		clock:       clk,
+		idempotency: newMemoryIdempotencyStore(clk),
+	}
+	if redisClient != nil {
+		s.cache = &timerCache{redis: redisClient}
+		s.idempotency = &redisIdempotencyStore{redis: redisClient}
 	}
+	return s
+}
+
+// SetTickLease makes the instances sharing tickLease take turns running
+// the tick loop: only the holder advances and expires timers.
+func (s *TimerService) SetTickLease(tickLease *lease.Lease) {
+	s.tickLease = tickLease
 }
 
 func (s *TimerService) StartTimerUpdates() {
-	ticker := time.NewTicker(1 * time.Second)
+	ticker := s.clock.NewTicker(1 * time.Second)
 	defer ticker.Stop()
+	defer s.releaseTicks()
 
 	for {
 		select {
-		case <-ticker.C:
-			s.updateTimers()
+		case now := <-ticker.C():
+			if s.leadsTicks() {
+				s.updateTimers(now)
+			}
 		case <-s.stopChan:
 			return
 		}
 	}
 }
 
-func (s *TimerService) updateTimers() {
+// leadsTicks takes or renews the tick lease and reports whether this
+// instance holds it. An instance that cannot reach Redis stops ticking:
+// another one may hold the lease.
+func (s *TimerService) leadsTicks() bool {
+	if s.tickLease == nil {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	held, err := s.tickLease.Hold(ctx)
+	if err != nil {
+		s.logger.Errorw("Failed to hold tick lease", "error", err, "owner", s.tickLease.Owner())
+	}
+
+	if held && !s.leading {
+		s.logger.Infow("Took over the tick loop", "owner", s.tickLease.Owner())
+	} else if !held && s.leading {
+		s.logger.Warnw("Lost the tick loop to another instance", "owner", s.tickLease.Owner())
+	}
+	s.leading = held
+	return held
+}
+
+// releaseTicks hands the tick loop over when this instance stops, instead
+// of leaving the others waiting for the lease to expire.
+func (s *TimerService) releaseTicks() {
+	if s.tickLease == nil || !s.leading {
+		return
+	}
+	s.leading = false
+	if err := s.tickLease.Release(context.Background()); err != nil {
+		s.logger.Errorw("Failed to release tick lease", "error", err, "owner", s.tickLease.Owner())
+	}
+}
+
+// updateTimers broadcasts the remaining time of every running timer and
+// expires the ones that ran out. The ticker only drives broadcasts:
+// remaining time is derived from EndsAt, so a late or skipped tick never
+// makes a timer run long.
+func (s *TimerService) updateTimers(now time.Time) {
 	timers, err := s.repo.GetActiveTimers()
 	if err != nil {
 		s.logger.Errorw("Failed to get active timers", "error", err)
@@ -65,50 +145,175 @@ func (s *TimerService) updateTimers() {
 	}
 
 	for _, timer := range timers {
-		if !timer.IsPaused && timer.CurrentTime > 0 {
-			timer.CurrentTime--
-			if err := s.repo.Update(&timer); err != nil {
-				s.logger.Errorw("Failed to update timer", "error", err, "timerID", timer.ID)
-				continue
+		timer.Sync(now)
+		if timer.HasRunOut(now) && !timer.AllowOvertime {
+			if err := s.expireTimer(&timer); err != nil && !s.lostRace(err, timer.ID) {
+				s.logger.Errorw("Failed to expire timer", "error", err, "timerID", timer.ID)
+			}
+			continue
+		}
+
+		if timer.HasRunOut(now) && timer.ExpiredAt == nil {
+			if err := s.enterOvertime(&timer); err != nil {
+				if s.lostRace(err, timer.ID) {
+					continue
+				}
+				s.logger.Errorw("Failed to start timer overtime", "error", err, "timerID", timer.ID)
+			}
+		}
+
+		if timer.SoftCapDue(now) {
+			if err := s.reachSoftCap(&timer); err != nil {
+				if s.lostRace(err, timer.ID) {
+					continue
+				}
+				s.logger.Errorw("Failed to record timer soft cap", "error", err, "timerID", timer.ID)
 			}
-			s.broadcastTimerUpdate(&timer)
 		}
+		s.wsHandler.BroadcastTimerUpdate(&timer)
 	}
 }
 
-func (s *TimerService) broadcastTimerUpdate(timer *types.Timer) {
-	// Log the update
-	s.logger.Infow("Timer updated", "timerID", timer.ID, "currentTime", timer.CurrentTime)
+// lostRace reports whether the tick loop's write failed because the timer
+// was changed since it was loaded, e.g. paused by a game master. The tick
+// then leaves the timer alone: its copy is stale and the next tick reloads
+// it.
+func (s *TimerService) lostRace(err error, timerID uint) bool {
+	if !errors.Is(err, repository.ErrVersionConflict) {
+		return false
+	}
+	s.logger.Debugw("Skipped timer changed during tick", "timerID", timerID)
+	return true
+}
+
+// enterOvertime records that a countdown allowing overtime ran out. Clients
+// get the usual TIMER_EXPIRED event but the timer stays running and counts
+// below zero.
+func (s *TimerService) enterOvertime(timer *types.Timer) error {
+	expiredAt := timer.EndsAt
+	timer.ExpiredAt = &expiredAt
+
+	if err := s.repo.Update(timer); err != nil {
+		return err
+	}
+
+	s.persistTimer(timer)
+	s.logger.Infow("Timer entered overtime", "timerID", timer.ID, "sessionID", timer.SessionID)
+
+	s.wsHandler.BroadcastTimerExpired(timer)
+	return nil
+}
+
+// reachSoftCap records that a stopwatch passed its soft cap and tells every
+// client about it. The timer keeps running.
+func (s *TimerService) reachSoftCap(timer *types.Timer) error {
+	reachedAt := timer.StartedAt.Add(timer.PausedTotal + time.Duration(timer.MaxTime)*time.Second)
+	timer.SoftCapReachedAt = &reachedAt
+
+	if err := s.repo.Update(timer); err != nil {
+		return err
+	}
+
+	s.persistTimer(timer)
+	s.logger.Infow("Timer soft cap reached", "timerID", timer.ID, "sessionID", timer.SessionID)
+
+	s.wsHandler.BroadcastTimerSoftCapReached(timer)
+	return nil
+}
+
+// expireTimer moves a running timer whose deadline has passed to the expired
+// state and tells every client about it.
+func (s *TimerService) expireTimer(timer *types.Timer) error {
+	expiredAt := timer.EndsAt
+	timer.State = types.StateExpired
+	timer.ExpiredAt = &expiredAt
+	timer.CurrentTime = 0
+
+	if err := s.repo.Update(timer); err != nil {
+		return err
+	}
+
+	s.persistTimer(timer)
+	s.logger.Infow("Timer expired", "timerID", timer.ID, "sessionID", timer.SessionID)
 
-	// Convert the models.Timer to types.Timer if necessary
-	// This step might be needed if your WebSocket handler expects a different type
-	wsTimer := &types.Timer{
-		ID:          timer.ID,
-		SessionID:   timer.SessionID,
-		CurrentTime: timer.CurrentTime,
-		MaxTime:     timer.MaxTime,
-		IsPaused:    timer.IsPaused,
+	s.broadcastTimerUpdate(timer)
+	s.wsHandler.BroadcastTimerExpired(timer)
+	return nil
+}
+
+// expireIfDue expires a running timer whose deadline has passed but which
+// the tick loop has not caught yet.
+func (s *TimerService) expireIfDue(timer *types.Timer, now time.Time) error {
+	if timer.State == types.StateRunning && timer.HasRunOut(now) && !timer.AllowOvertime {
+		return s.expireTimer(timer)
+	}
+	return nil
+}
+
+// checkTransition returns an InvalidStateError when timer may not move to
+// next. A timer that has run out is expired first.
+func (s *TimerService) checkTransition(timer *types.Timer, next types.TimerState, action string, now time.Time) error {
+	if err := s.expireIfDue(timer, now); err != nil {
+		return err
 	}
 
-	// Use the WebSocket handler to broadcast the update
-	s.wsHandler.BroadcastTimerUpdate(wsTimer)
+	if !timer.State.CanTransitionTo(next) {
+		return &InvalidStateError{TimerID: timer.ID, State: timer.State, Action: action}
+	}
+	return nil
+}
+
+// broadcastTimerUpdate sends the clients a copy of timer, which the caller
+// may go on changing.
+func (s *TimerService) broadcastTimerUpdate(timer *types.Timer) {
+	s.logger.Infow("Timer updated", "timerID", timer.ID, "currentTime", timer.CurrentTime)
+
+	update := *timer
+	s.wsHandler.BroadcastTimerUpdate(&update)
 }
 
 func (s *TimerService) StopTimerUpdates() {
 	close(s.stopChan)
 }
 
-func (s *TimerService) CreateTimer(sessionID string, maxTime int64) (*types.Timer, error) {
+// CreateTimer starts a timer for a session. Countdowns run from MaxTime to
+// zero; count-up timers start at zero and treat MaxTime as an optional soft
+// cap.
+func (s *TimerService) CreateTimer(req types.TimerRequest) (*types.Timer, error) {
+	now := s.clock.Now()
+	mode := req.Mode
+	if mode == "" {
+		mode = types.ModeCountdown
+	}
+	switch {
+	case req.SessionID == "":
+		return nil, invalidArgument("sessionId is required")
+	case req.MaxTime < 0:
+		return nil, invalidArgument("maxTime must not be negative, got %d", req.MaxTime)
+	case mode != types.ModeCountdown && mode != types.ModeCountUp:
+		return nil, invalidArgument("unknown timer mode %q", mode)
+	}
+
 	timer := &types.Timer{
-		SessionID:   sessionID,
-		MaxTime:     maxTime,
-		CurrentTime: maxTime,
-		IsPaused:    false,
+		SessionID:     req.SessionID,
+		MaxTime:       req.MaxTime,
+		IsPaused:      false,
+		State:         types.StateRunning,
+		Mode:          mode,
+		AllowOvertime: req.AllowOvertime && mode == types.ModeCountdown,
+		StartedAt:     now,
+		EndsAt:        now,
+		CreatedAt:     now,
+		Version:       1,
+	}
+	if !timer.IsCountUp() {
+		timer.EndsAt = now.Add(time.Duration(req.MaxTime) * time.Second)
 	}
+	timer.Sync(now)
 
 	err := s.repo.Create(timer)
 	if err != nil {
-		s.logger.Errorw("Failed to create timer", "error", err, "sessionID", sessionID)
+		s.logger.Errorw("Failed to create timer", "error", err, "sessionID", req.SessionID)
 		return nil, err
 	}
 
@@ -117,14 +322,77 @@ func (s *TimerService) CreateTimer(sessionID string, maxTime int64) (*types.Time
 	return timer, nil
 }
 
-func (s *TimerService) PauseTimer(id uint) (*types.Timer, error) {
+// findTimer loads a timer, reporting a missing one as a not-found error.
+func (s *TimerService) findTimer(id uint) (*types.Timer, error) {
 	timer, err := s.repo.FindByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, notFound("timer %d not found", id)
+	}
+	return timer, err
+}
+
+// loadTimer loads a timer to change it. When ifMatch is not zero the timer
+// must be at that version, so a client cannot overwrite a change it has
+// not seen.
+func (s *TimerService) loadTimer(id, ifMatch uint) (*types.Timer, error) {
+	timer, err := s.findTimer(id)
+	if err != nil {
+		return nil, err
+	}
+	if ifMatch != 0 && timer.Version != ifMatch {
+		return nil, preconditionFailed("timer %d is at version %d, not %d", id, timer.Version, ifMatch)
+	}
+	return timer, nil
+}
+
+// maxUpdateAttempts bounds how often a change is retried when another
+// writer, such as the tick loop, saved the timer after it was loaded.
+const maxUpdateAttempts = 3
+
+// retryOnConflict runs change until it does not lose a race with another
+// writer. Each attempt reloads the timer, so the change is applied to the
+// latest state or rejected by it.
+func (s *TimerService) retryOnConflict(id uint, action string, change func() (*types.Timer, error)) (*types.Timer, error) {
+	for attempt := 1; ; attempt++ {
+		timer, err := change()
+		if !errors.Is(err, repository.ErrVersionConflict) {
+			return timer, err
+		}
+		if attempt == maxUpdateAttempts {
+			s.logger.Warnw("Gave up timer update after concurrent modifications", "id", id, "action", action)
+			return nil, conflict("timer %d was modified concurrently, %s it again", id, action)
+		}
+		s.logger.Infow("Retrying timer update after concurrent modification", "id", id, "action", action, "attempt", attempt)
+	}
+}
+
+// PauseTimer freezes a running timer. When ifMatch is not zero the timer
+// must still be at that version.
+func (s *TimerService) PauseTimer(id, ifMatch uint) (*types.Timer, error) {
+	return s.retryOnConflict(id, "pause", func() (*types.Timer, error) {
+		return s.pauseTimer(id, ifMatch)
+	})
+}
+
+func (s *TimerService) pauseTimer(id, ifMatch uint) (*types.Timer, error) {
+	timer, err := s.loadTimer(id, ifMatch)
 	if err != nil {
 		s.logger.Errorw("Failed to find timer", "error", err, "id", id)
 		return nil, err
 	}
 
-	timer.IsPaused = true
+	now := s.clock.Now()
+	if timer.State != types.StatePaused {
+		if err := s.checkTransition(timer, types.StatePaused, "pause", now); err != nil {
+			s.logger.Warnw("Rejected timer pause", "error", err, "id", id)
+			return nil, err
+		}
+		timer.State = types.StatePaused
+		timer.IsPaused = true
+		timer.PausedAt = &now
+	}
+	timer.Sync(now)
+
 	err = s.repo.Update(timer)
 	if err != nil {
 		s.logger.Errorw("Failed to pause timer", "error", err, "id", id)
@@ -136,14 +404,40 @@ func (s *TimerService) PauseTimer(id uint) (*types.Timer, error) {
 	return timer, nil
 }
 
-func (s *TimerService) ResumeTimer(id uint) (*types.Timer, error) {
-	timer, err := s.repo.FindByID(id)
+// ResumeTimer restarts a paused timer. When ifMatch is not zero the timer
+// must still be at that version.
+func (s *TimerService) ResumeTimer(id, ifMatch uint) (*types.Timer, error) {
+	return s.retryOnConflict(id, "resume", func() (*types.Timer, error) {
+		return s.resumeTimer(id, ifMatch)
+	})
+}
+
+func (s *TimerService) resumeTimer(id, ifMatch uint) (*types.Timer, error) {
+	timer, err := s.loadTimer(id, ifMatch)
 	if err != nil {
 		s.logger.Errorw("Failed to find timer", "error", err, "id", id)
 		return nil, err
 	}
 
-	timer.IsPaused = false
+	now := s.clock.Now()
+	if timer.State != types.StateRunning {
+		if err := s.checkTransition(timer, types.StateRunning, "resume", now); err != nil {
+			s.logger.Warnw("Rejected timer resume", "error", err, "id", id)
+			return nil, err
+		}
+		// Push the deadline back by however long the timer sat paused so
+		// the remaining time is exactly what it was when it was paused.
+		if timer.PausedAt != nil {
+			paused := now.Sub(*timer.PausedAt)
+			timer.EndsAt = timer.EndsAt.Add(paused)
+			timer.PausedTotal += paused
+		}
+		timer.State = types.StateRunning
+		timer.IsPaused = false
+		timer.PausedAt = nil
+	}
+	timer.Sync(now)
+
 	err = s.repo.Update(timer)
 	if err != nil {
 		s.logger.Errorw("Failed to resume timer", "error", err, "id", id)
@@ -155,27 +449,98 @@ func (s *TimerService) ResumeTimer(id uint) (*types.Timer, error) {
 	return timer, nil
 }
 
-func (s *TimerService) StopTimer(id uint) error {
-	err := s.repo.Delete(id)
+// StopTimer moves the timer to its final stopped state and returns it. The
+// row is kept so finished sessions remain available for reporting. When
+// ifMatch is not zero the timer must still be at that version.
+func (s *TimerService) StopTimer(id, ifMatch uint) (*types.Timer, error) {
+	return s.retryOnConflict(id, "stop", func() (*types.Timer, error) {
+		return s.stopTimer(id, ifMatch)
+	})
+}
+
+func (s *TimerService) stopTimer(id, ifMatch uint) (*types.Timer, error) {
+	timer, err := s.loadTimer(id, ifMatch)
+	if err != nil {
+		s.logger.Errorw("Failed to find timer", "error", err, "id", id)
+		return nil, err
+	}
+
+	now := s.clock.Now()
+	if err := s.checkTransition(timer, types.StateStopped, "stop", now); err != nil {
+		s.logger.Warnw("Rejected timer stop", "error", err, "id", id)
+		return nil, err
+	}
+	timer.Sync(now)
+	timer.State = types.StateStopped
+	timer.StoppedAt = &now
+
+	err = s.repo.Update(timer)
 	if err != nil {
 		s.logger.Errorw("Failed to stop timer", "error", err, "id", id)
-		return err
+		return nil, err
 	}
 
-	s.redis.Del(context.Background(), "timer:"+fmt.Sprint(id))
+	s.persistTimer(timer)
 
-	return nil
+	return timer, nil
 }
 
-func (s *TimerService) ModifyTimer(id uint, newMaxTime int64) (*types.Timer, error) {
-	timer, err := s.repo.FindByID(id)
+// ModifyTimer sets a new maximum. A countdown restarts from it; a stopwatch
+// keeps its elapsed time and only moves its soft cap. When ifMatch is not
+// zero the timer must still be at that version.
+func (s *TimerService) ModifyTimer(id, ifMatch uint, newMaxTime int64) (*types.Timer, error) {
+	if newMaxTime < 0 {
+		return nil, invalidArgument("maxTime must not be negative, got %d", newMaxTime)
+	}
+
+	return s.retryOnConflict(id, "modify", func() (*types.Timer, error) {
+		return s.modifyTimer(id, ifMatch, newMaxTime)
+	})
+}
+
+func (s *TimerService) modifyTimer(id, ifMatch uint, newMaxTime int64) (*types.Timer, error) {
+	timer, err := s.loadTimer(id, ifMatch)
 	if err != nil {
 		s.logger.Errorw("Failed to find timer", "error", err, "id", id)
 		return nil, err
 	}
+	// Only a stopwatch can do without a maximum.
+	if newMaxTime == 0 && !timer.IsCountUp() {
+		return nil, invalidArgument("maxTime of countdown timer %d must not be 0", id)
+	}
+
+	now := s.clock.Now()
+	if err := s.expireIfDue(timer, now); err != nil {
+		s.logger.Errorw("Failed to expire timer", "error", err, "id", id)
+		return nil, err
+	}
+	if timer.State == types.StateExpired || timer.State == types.StateStopped {
+		err := &InvalidStateError{TimerID: timer.ID, State: timer.State, Action: "modify"}
+		s.logger.Warnw("Rejected timer modification", "error", err, "id", id)
+		return nil, err
+	}
 
 	timer.MaxTime = newMaxTime
-	timer.CurrentTime = newMaxTime
+	if timer.IsCountUp() {
+		// A stopwatch keeps its elapsed time; only the soft cap moves, and
+		// raising it above the elapsed time, or removing it, re-arms the
+		// event.
+		if newMaxTime == 0 || timer.Elapsed(now) < time.Duration(newMaxTime)*time.Second {
+			timer.SoftCapReachedAt = nil
+		}
+	} else {
+		// The countdown restarts from the new maximum, measured from the
+		// pause instant when paused so resuming later still yields the full
+		// duration.
+		ref := now
+		if timer.IsPaused && timer.PausedAt != nil {
+			ref = *timer.PausedAt
+		}
+		timer.EndsAt = ref.Add(time.Duration(newMaxTime) * time.Second)
+		timer.ExpiredAt = nil
+	}
+	timer.Sync(now)
+
 	err = s.repo.Update(timer)
 	if err != nil {
 		s.logger.Errorw("Failed to modify timer", "error", err, "id", id)
@@ -187,49 +552,180 @@ func (s *TimerService) ModifyTimer(id uint, newMaxTime int64) (*types.Timer, err
 	return timer, nil
 }
 
-func (s *TimerService) GetAllTimers() ([]types.Timer, error) {
-	return s.repo.FindAll()
+// AdjustTimer adds delta seconds to the timer without restarting it, e.g. a
+// negative delta as a penalty for a hint. Every client of the session is
+// told about the adjustment. When ifMatch is not zero the timer must still
+// be at that version.
+func (s *TimerService) AdjustTimer(id, ifMatch uint, delta int64, reason string) (*types.Timer, error) {
+	return s.retryOnConflict(id, "adjust", func() (*types.Timer, error) {
+		return s.adjustTimer(id, ifMatch, delta, reason)
+	})
 }
 
-func (s *TimerService) persistTimer(timer *types.Timer) {
-	timerJSON, err := json.Marshal(timer)
+func (s *TimerService) adjustTimer(id, ifMatch uint, delta int64, reason string) (*types.Timer, error) {
+	timer, err := s.loadTimer(id, ifMatch)
 	if err != nil {
-		s.logger.Errorw("Failed to marshal timer", "error", err)
-		return
+		s.logger.Errorw("Failed to find timer", "error", err, "id", id)
+		return nil, err
 	}
 
-	err = s.redis.Set(context.Background(), "timer:"+timer.SessionID, timerJSON, 24*time.Hour).Err()
+	now := s.clock.Now()
+	if err := s.expireIfDue(timer, now); err != nil {
+		s.logger.Errorw("Failed to expire timer", "error", err, "id", id)
+		return nil, err
+	}
+	if timer.State == types.StateExpired || timer.State == types.StateStopped {
+		err := &InvalidStateError{TimerID: timer.ID, State: timer.State, Action: "adjust"}
+		s.logger.Warnw("Rejected timer adjustment", "error", err, "id", id)
+		return nil, err
+	}
+
+	adjustment := timer.Adjust(delta, reason, now)
+
+	err = s.repo.Update(timer)
 	if err != nil {
-		s.logger.Errorw("Failed to persist timer to Redis", "error", err)
+		s.logger.Errorw("Failed to adjust timer", "error", err, "id", id)
+		return nil, err
 	}
+
+	s.persistTimer(timer)
+	s.logger.Infow("Timer adjusted", "timerID", timer.ID, "delta", delta, "reason", reason)
+
+	s.wsHandler.BroadcastTimerAdjusted(timer, adjustment)
+
+	return timer, nil
 }
 
-func (s *TimerService) RestoreTimers() error {
-	keys, err := s.redis.Keys(context.Background(), "timer:*").Result()
+// RecordHint counts a hint against the timer and, when penalty is positive,
+// charges that many seconds: a countdown loses them and a stopwatch gains
+// them.
+func (s *TimerService) RecordHint(id uint, penalty int64) (*types.Timer, error) {
+	return s.retryOnConflict(id, "record hint on", func() (*types.Timer, error) {
+		return s.recordHint(id, penalty)
+	})
+}
+
+func (s *TimerService) recordHint(id uint, penalty int64) (*types.Timer, error) {
+	timer, err := s.findTimer(id)
 	if err != nil {
-		return err
+		s.logger.Errorw("Failed to find timer", "error", err, "id", id)
+		return nil, err
 	}
 
-	for _, key := range keys {
-		timerJSON, err := s.redis.Get(context.Background(), key).Result()
-		if err != nil {
-			s.logger.Errorw("Failed to get timer from Redis", "error", err)
-			continue
+	now := s.clock.Now()
+	if err := s.expireIfDue(timer, now); err != nil {
+		s.logger.Errorw("Failed to expire timer", "error", err, "id", id)
+		return nil, err
+	}
+	if timer.State == types.StateExpired || timer.State == types.StateStopped {
+		err := &InvalidStateError{TimerID: timer.ID, State: timer.State, Action: "record hint on"}
+		s.logger.Warnw("Rejected hint", "error", err, "id", id)
+		return nil, err
+	}
+
+	timer.HintCount++
+	var adjustment *types.TimerAdjustment
+	if penalty > 0 {
+		delta := -penalty
+		if timer.IsCountUp() {
+			delta = penalty
 		}
+		applied := timer.Adjust(delta, "hint", now)
+		adjustment = &applied
+	} else {
+		timer.Sync(now)
+	}
 
-		var timer types.Timer
-		err = json.Unmarshal([]byte(timerJSON), &timer)
-		if err != nil {
-			s.logger.Errorw("Failed to unmarshal timer", "error", err)
-			continue
+	err = s.repo.Update(timer)
+	if err != nil {
+		s.logger.Errorw("Failed to record hint", "error", err, "id", id)
+		return nil, err
+	}
+
+	s.persistTimer(timer)
+
+	if adjustment != nil {
+		s.wsHandler.BroadcastTimerAdjusted(timer, *adjustment)
+	} else {
+		s.broadcastTimerUpdate(timer)
+	}
+
+	return timer, nil
+}
+
+// GetTimer returns a timer with its current time. Redis answers for live
+// timers, the database for finished ones and when Redis cannot.
+func (s *TimerService) GetTimer(id uint) (*types.Timer, error) {
+	timer := s.cachedTimer(id)
+	if timer == nil {
+		var err error
+		if timer, err = s.findTimer(id); err != nil {
+			return nil, err
 		}
+	}
 
-		err = s.repo.Update(&timer)
+	timer.Sync(s.clock.Now())
+	return timer, nil
+}
+
+// ListTimers returns a page of the timers matching query, and the cursor of
+// the next page if there is one.
+func (s *TimerService) ListTimers(query repository.TimerQuery) ([]types.Timer, *repository.TimerCursor, error) {
+	limit := query.Limit
+	if limit > 0 {
+		// One more than asked tells whether another page follows.
+		query.Limit = limit + 1
+	}
+
+	timers, err := s.repo.FindTimers(query)
+	if err != nil {
+		s.logger.Errorw("Failed to list timers", "error", err)
+		return nil, nil, err
+	}
+
+	var next *repository.TimerCursor
+	if limit > 0 && len(timers) > limit {
+		timers = timers[:limit]
+		cursor := repository.CursorFor(&timers[limit-1])
+		next = &cursor
+	}
+
+	now := s.clock.Now()
+	for i := range timers {
+		timers[i].Sync(now)
+	}
+	return timers, next, nil
+}
+
+func (s *TimerService) GetAllTimers() ([]types.Timer, error) {
+	timers, err := s.repo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.clock.Now()
+	for i := range timers {
+		timers[i].Sync(now)
+	}
+	return timers, nil
+}
+
+// GetLiveTimers returns the created, running and paused timers of a
+// session, or of every session when sessionID is empty, newest first. They
+// are read from Redis, or from the database when Redis cannot answer.
+func (s *TimerService) GetLiveTimers(sessionID string) ([]types.Timer, error) {
+	timers := s.cachedLiveTimers(sessionID)
+	if timers == nil {
+		var err error
+		timers, err = s.repo.FindTimers(repository.TimerQuery{SessionID: sessionID, States: liveStates})
 		if err != nil {
-			s.logger.Errorw("Failed to restore timer", "error", err)
-			continue
+			return nil, err
 		}
 	}
 
-	return nil
+	now := s.clock.Now()
+	for i := range timers {
+		timers[i].Sync(now)
+	}
+	return timers, nil
 }
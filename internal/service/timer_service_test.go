@@ -4,13 +4,18 @@ import (
 	"testing"
 	"time"
 
+	"timer-microservice/internal/clock/clocktest"
+	"timer-microservice/internal/lease"
+	"timer-microservice/internal/repository"
 	"timer-microservice/internal/types"
 	"timer-microservice/internal/websocket"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/go-redis/redis/v8"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 // MockTimerRepository is a mock of TimerRepository
@@ -43,6 +48,16 @@ func (m *MockTimerRepository) FindAll() ([]types.Timer, error) {
 	return args.Get(0).([]types.Timer), args.Error(1)
 }
 
+func (m *MockTimerRepository) FindBySessionID(sessionID string) ([]types.Timer, error) {
+	args := m.Called(sessionID)
+	return args.Get(0).([]types.Timer), args.Error(1)
+}
+
+func (m *MockTimerRepository) FindTimers(query repository.TimerQuery) ([]types.Timer, error) {
+	args := m.Called(query)
+	return args.Get(0).([]types.Timer), args.Error(1)
+}
+
 func (m *MockTimerRepository) GetActiveTimers() ([]types.Timer, error) {
 	args := m.Called()
 	return args.Get(0).([]types.Timer), args.Error(1)
@@ -57,10 +72,43 @@ func (m *MockWebSocketHandler) BroadcastTimerUpdate(timer *types.Timer) {
 	m.Called(timer)
 }
 
+func (m *MockWebSocketHandler) BroadcastTimerExpired(timer *types.Timer) {
+	m.Called(timer)
+}
+
+func (m *MockWebSocketHandler) BroadcastTimerSoftCapReached(timer *types.Timer) {
+	m.Called(timer)
+}
+
+func (m *MockWebSocketHandler) BroadcastTimerAdjusted(timer *types.Timer, adjustment types.TimerAdjustment) {
+	m.Called(timer, adjustment)
+}
+
+func (m *MockWebSocketHandler) SendHint(hint *types.Hint) {
+	m.Called(hint)
+}
+
 func (m *MockWebSocketHandler) SetService(service websocket.TimerServiceInterface) {
 	m.Called(service)
 }
 
+// newTestRedis starts a miniredis server and returns a client of it.
+func newTestRedis(t *testing.T) (*redis.Client, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { redisClient.Close() })
+	return redisClient, mr
+}
+
+// newTestService returns a TimerService on a mock repository and a fake
+// clock, caching in redisClient unless it is nil.
+func newTestService(t *testing.T, redisClient *redis.Client) (*TimerService, *MockTimerRepository, *clocktest.Fake) {
+	mockRepo := new(MockTimerRepository)
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	service := NewTimerService(mockRepo, zap.NewNop().Sugar(), redisClient, new(MockWebSocketHandler), clk)
+	return service.(*TimerService), mockRepo, clk
+}
+
 func TestCreateTimer(t *testing.T) {
 	mockRepo := new(MockTimerRepository)
 	mockRedis := redis.NewClient(&redis.Options{})
@@ -68,7 +116,7 @@ func TestCreateTimer(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
 	sugar := logger.Sugar()
 
-	service := NewTimerService(mockRepo, sugar, mockRedis, mockWS)
+	service := NewTimerService(mockRepo, sugar, mockRedis, mockWS, clocktest.NewFake(time.Now()))
 
 	sessionID := "test-session"
 	maxTime := int64(60)
@@ -81,21 +129,94 @@ func TestCreateTimer(t *testing.T) {
 	}
 
 	mockRepo.On("Create", mock.AnythingOfType("*types.Timer")).Return(nil)
-	mockWS.On("SetService", mock.AnythingOfType("*TimerService")).Return()
 
-	timer, err := service.CreateTimer(sessionID, maxTime)
+	timer, err := service.CreateTimer(types.TimerRequest{SessionID: sessionID, MaxTime: maxTime})
 
 	assert.NoError(t, err)
 	assert.Equal(t, expectedTimer.SessionID, timer.SessionID)
 	assert.Equal(t, expectedTimer.MaxTime, timer.MaxTime)
 	assert.Equal(t, expectedTimer.CurrentTime, timer.CurrentTime)
 	assert.Equal(t, expectedTimer.IsPaused, timer.IsPaused)
+	assert.Equal(t, types.StateRunning, timer.State)
+	assert.Equal(t, types.ModeCountdown, timer.Mode)
+	assert.Equal(t, time.Duration(maxTime)*time.Second, timer.EndsAt.Sub(timer.StartedAt))
 
 	mockRepo.AssertExpectations(t)
 	mockWS.AssertExpectations(t)
 }
 
-// ... (keep the other test functions)
+func TestResumeTimerPreservesRemaining(t *testing.T) {
+	mockRepo := new(MockTimerRepository)
+	mockRedis := redis.NewClient(&redis.Options{})
+	mockWS := new(MockWebSocketHandler)
+	logger, _ := zap.NewDevelopment()
+	sugar := logger.Sugar()
+
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	service := NewTimerService(mockRepo, sugar, mockRedis, mockWS, clk)
+
+	// Paused ten minutes ago with exactly half an hour left.
+	pausedAt := clk.Now().Add(-10 * time.Minute)
+	pausedTimer := &types.Timer{
+		ID:        1,
+		SessionID: "session1",
+		MaxTime:   3600,
+		IsPaused:  true,
+		State:     types.StatePaused,
+		StartedAt: pausedAt.Add(-30 * time.Minute),
+		EndsAt:    pausedAt.Add(30 * time.Minute),
+		PausedAt:  &pausedAt,
+	}
+
+	mockRepo.On("FindByID", uint(1)).Return(pausedTimer, nil)
+	mockRepo.On("Update", mock.AnythingOfType("*types.Timer")).Return(nil)
+
+	timer, err := service.ResumeTimer(1, 0)
+
+	assert.NoError(t, err)
+	assert.False(t, timer.IsPaused)
+	assert.Equal(t, types.StateRunning, timer.State)
+	assert.Nil(t, timer.PausedAt)
+	assert.Equal(t, int64(1800), timer.CurrentTime)
+	assert.Equal(t, 10*time.Minute, timer.PausedTotal)
+	assert.Equal(t, clk.Now().Add(30*time.Minute), timer.EndsAt)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestModifyPausedTimer(t *testing.T) {
+	mockRepo := new(MockTimerRepository)
+	mockRedis := redis.NewClient(&redis.Options{})
+	mockWS := new(MockWebSocketHandler)
+	logger, _ := zap.NewDevelopment()
+	sugar := logger.Sugar()
+
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	service := NewTimerService(mockRepo, sugar, mockRedis, mockWS, clk)
+
+	pausedAt := clk.Now().Add(-5 * time.Minute)
+	pausedTimer := &types.Timer{
+		ID:        1,
+		SessionID: "session1",
+		MaxTime:   3600,
+		IsPaused:  true,
+		State:     types.StatePaused,
+		EndsAt:    pausedAt.Add(time.Minute),
+		PausedAt:  &pausedAt,
+	}
+
+	mockRepo.On("FindByID", uint(1)).Return(pausedTimer, nil)
+	mockRepo.On("Update", mock.AnythingOfType("*types.Timer")).Return(nil)
+
+	timer, err := service.ModifyTimer(1, 0, 900)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(900), timer.MaxTime)
+	assert.Equal(t, int64(900), timer.CurrentTime)
+	assert.True(t, timer.IsPaused)
+
+	mockRepo.AssertExpectations(t)
+}
 
 func TestUpdateTimers(t *testing.T) {
 	mockRepo := new(MockTimerRepository)
@@ -104,24 +225,712 @@ func TestUpdateTimers(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
 	sugar := logger.Sugar()
 
-	service := NewTimerService(mockRepo, sugar, mockRedis, mockWS)
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	service := NewTimerService(mockRepo, sugar, mockRedis, mockWS, clk)
 
+	now := clk.Now()
 	activeTimers := []types.Timer{
-		{ID: 1, SessionID: "session1", MaxTime: 60, CurrentTime: 30, IsPaused: false},
-		{ID: 2, SessionID: "session2", MaxTime: 120, CurrentTime: 90, IsPaused: false},
+		{ID: 1, SessionID: "session1", MaxTime: 60, State: types.StateRunning, EndsAt: now.Add(30 * time.Second)},
+		{ID: 2, SessionID: "session2", MaxTime: 120, State: types.StateRunning, EndsAt: now.Add(90 * time.Second)},
 	}
 
+	mockRepo.On("GetActiveTimers").Return(activeTimers, nil).Twice()
+	mockWS.On("BroadcastTimerUpdate", mock.MatchedBy(func(timer *types.Timer) bool {
+		return timer.ID == 1 && timer.CurrentTime == 29
+	})).Return().Once()
+	mockWS.On("BroadcastTimerUpdate", mock.MatchedBy(func(timer *types.Timer) bool {
+		return timer.ID == 2 && timer.CurrentTime == 89
+	})).Return().Once()
+	mockWS.On("BroadcastTimerUpdate", mock.MatchedBy(func(timer *types.Timer) bool {
+		return timer.ID == 1 && timer.CurrentTime == 28
+	})).Return().Once()
+	mockWS.On("BroadcastTimerUpdate", mock.MatchedBy(func(timer *types.Timer) bool {
+		return timer.ID == 2 && timer.CurrentTime == 88
+	})).Return().Once()
+
+	done := runTimerUpdates(service, clk)
+	clk.Advance(2 * time.Second)
+	service.StopTimerUpdates()
+	<-done
+
+	mockRepo.AssertExpectations(t)
+	mockWS.AssertExpectations(t)
+}
+
+func TestHourLongGameHasNoDrift(t *testing.T) {
+	mockRepo := new(MockTimerRepository)
+	mockRedis := redis.NewClient(&redis.Options{})
+	mockWS := new(MockWebSocketHandler)
+
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	service := NewTimerService(mockRepo, zap.NewNop().Sugar(), mockRedis, mockWS, clk)
+
+	start := clk.Now()
+	activeTimers := []types.Timer{
+		{ID: 1, SessionID: "session1", MaxTime: 3600, State: types.StateRunning, StartedAt: start, EndsAt: start.Add(time.Hour)},
+	}
+
+	var broadcasts []int64
+	mockRepo.On("GetActiveTimers").Return(activeTimers, nil)
+	mockRepo.On("Update", mock.AnythingOfType("*types.Timer")).Return(nil).Once()
+	record := func(args mock.Arguments) {
+		broadcasts = append(broadcasts, args.Get(0).(*types.Timer).CurrentTime)
+	}
+	mockWS.On("BroadcastTimerUpdate", mock.AnythingOfType("*types.Timer")).Run(record).Return()
+	mockWS.On("BroadcastTimerExpired", mock.AnythingOfType("*types.Timer")).Return().Once()
+
+	done := runTimerUpdates(service, clk)
+	clk.Advance(time.Hour)
+	service.StopTimerUpdates()
+	<-done
+
+	assert.Len(t, broadcasts, 3600)
+	assert.Equal(t, int64(3599), broadcasts[0])
+	assert.Equal(t, int64(1800), broadcasts[1799])
+	assert.Equal(t, int64(0), broadcasts[3599])
+	mockWS.AssertExpectations(t)
+}
+
+func TestCountUpTimerSoftCap(t *testing.T) {
+	mockRepo := new(MockTimerRepository)
+	mockRedis := redis.NewClient(&redis.Options{})
+	mockWS := new(MockWebSocketHandler)
+
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	service := NewTimerService(mockRepo, zap.NewNop().Sugar(), mockRedis, mockWS, clk)
+
+	mockRepo.On("Create", mock.AnythingOfType("*types.Timer")).Return(nil)
+	stopwatch, err := service.CreateTimer(types.TimerRequest{SessionID: "session1", MaxTime: 10, Mode: types.ModeCountUp})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), stopwatch.CurrentTime)
+
+	// Updates are written back to the slice the tick loop reads, as the
+	// repository would.
+	activeTimers := []types.Timer{*stopwatch}
 	mockRepo.On("GetActiveTimers").Return(activeTimers, nil)
+	mockRepo.On("Update", mock.AnythingOfType("*types.Timer")).Run(func(args mock.Arguments) {
+		activeTimers[0] = *args.Get(0).(*types.Timer)
+	}).Return(nil).Once()
+
+	var broadcasts []int64
+	mockWS.On("BroadcastTimerUpdate", mock.AnythingOfType("*types.Timer")).Run(func(args mock.Arguments) {
+		broadcasts = append(broadcasts, args.Get(0).(*types.Timer).CurrentTime)
+	}).Return()
+	mockWS.On("BroadcastTimerSoftCapReached", mock.MatchedBy(func(timer *types.Timer) bool {
+		return timer.ElapsedTime == 10
+	})).Return().Once()
+
+	done := runTimerUpdates(service, clk)
+	clk.Advance(15 * time.Second)
+	service.StopTimerUpdates()
+	<-done
+
+	// The stopwatch keeps counting past its soft cap and never expires.
+	assert.Len(t, broadcasts, 15)
+	assert.Equal(t, int64(15), broadcasts[14])
+	assert.Equal(t, types.StateRunning, activeTimers[0].State)
+	assert.Equal(t, clk.Now().Add(-5*time.Second), *activeTimers[0].SoftCapReachedAt)
+
+	mockRepo.AssertExpectations(t)
+	mockWS.AssertExpectations(t)
+	mockWS.AssertNotCalled(t, "BroadcastTimerExpired", mock.Anything)
+}
+
+func TestOvertimeTimerKeepsRunning(t *testing.T) {
+	mockRepo := new(MockTimerRepository)
+	mockRedis := redis.NewClient(&redis.Options{})
+	mockWS := new(MockWebSocketHandler)
+
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	service := NewTimerService(mockRepo, zap.NewNop().Sugar(), mockRedis, mockWS, clk)
+
+	mockRepo.On("Create", mock.AnythingOfType("*types.Timer")).Return(nil)
+	timer, err := service.CreateTimer(types.TimerRequest{SessionID: "session1", MaxTime: 60, AllowOvertime: true})
+	assert.NoError(t, err)
+	assert.True(t, timer.AllowOvertime)
+
+	activeTimers := []types.Timer{*timer}
+	mockRepo.On("GetActiveTimers").Return(activeTimers, nil)
+	mockRepo.On("Update", mock.AnythingOfType("*types.Timer")).Run(func(args mock.Arguments) {
+		activeTimers[0] = *args.Get(0).(*types.Timer)
+	}).Return(nil).Once()
+
+	var broadcasts []int64
+	mockWS.On("BroadcastTimerUpdate", mock.AnythingOfType("*types.Timer")).Run(func(args mock.Arguments) {
+		broadcasts = append(broadcasts, args.Get(0).(*types.Timer).CurrentTime)
+	}).Return()
+	mockWS.On("BroadcastTimerExpired", mock.AnythingOfType("*types.Timer")).Return().Once()
+
+	done := runTimerUpdates(service, clk)
+	clk.Advance(65 * time.Second)
+	service.StopTimerUpdates()
+	<-done
+
+	assert.Len(t, broadcasts, 65)
+	assert.Equal(t, int64(0), broadcasts[59])
+	assert.Equal(t, int64(-5), broadcasts[64])
+	assert.Equal(t, types.StateRunning, activeTimers[0].State)
+
+	// Stopping records the final overrun.
+	mockRepo.On("FindByID", timer.ID).Return(&activeTimers[0], nil)
+	mockRepo.On("Update", mock.MatchedBy(func(timer *types.Timer) bool {
+		return timer.State == types.StateStopped && timer.Overtime == 5
+	})).Return(nil).Once()
+
+	stopped, err := service.StopTimer(timer.ID, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, types.StateStopped, stopped.State)
+
+	mockRepo.AssertExpectations(t)
+	mockWS.AssertExpectations(t)
+}
+
+func TestModifyCountUpTimerKeepsElapsed(t *testing.T) {
+	mockRepo := new(MockTimerRepository)
+	mockRedis := redis.NewClient(&redis.Options{})
+	mockWS := new(MockWebSocketHandler)
+	logger, _ := zap.NewDevelopment()
+	sugar := logger.Sugar()
+
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	service := NewTimerService(mockRepo, sugar, mockRedis, mockWS, clk)
+
+	reachedAt := clk.Now().Add(-5 * time.Minute)
+	stopwatch := &types.Timer{
+		ID:               1,
+		SessionID:        "session1",
+		MaxTime:          600,
+		State:            types.StateRunning,
+		Mode:             types.ModeCountUp,
+		StartedAt:        clk.Now().Add(-15 * time.Minute),
+		EndsAt:           clk.Now().Add(-15 * time.Minute),
+		SoftCapReachedAt: &reachedAt,
+	}
+
+	mockRepo.On("FindByID", uint(1)).Return(stopwatch, nil)
 	mockRepo.On("Update", mock.AnythingOfType("*types.Timer")).Return(nil)
-	mockWS.On("BroadcastTimerUpdate", mock.AnythingOfType("*types.Timer")).Return()
-	mockWS.On("SetService", mock.AnythingOfType("*TimerService")).Return()
 
-	go service.StartTimerUpdates()
-	time.Sleep(2 * time.Second) // Allow time for the goroutine to run
+	timer, err := service.ModifyTimer(1, 0, 1800)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(900), timer.CurrentTime)
+	assert.Equal(t, int64(900), timer.ElapsedTime)
+	assert.Equal(t, int64(1800), timer.MaxTime)
+	assert.Nil(t, timer.SoftCapReachedAt)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestModifyTimerToNoMaximum(t *testing.T) {
+	mockRepo := new(MockTimerRepository)
+	mockRedis := redis.NewClient(&redis.Options{})
+	mockWS := new(MockWebSocketHandler)
+	logger, _ := zap.NewDevelopment()
+	sugar := logger.Sugar()
+
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	service := NewTimerService(mockRepo, sugar, mockRedis, mockWS, clk)
+
+	reachedAt := clk.Now().Add(-5 * time.Minute)
+	mockRepo.On("FindByID", uint(1)).Return(&types.Timer{
+		ID:               1,
+		SessionID:        "session1",
+		MaxTime:          600,
+		State:            types.StateRunning,
+		Mode:             types.ModeCountUp,
+		StartedAt:        clk.Now().Add(-15 * time.Minute),
+		SoftCapReachedAt: &reachedAt,
+	}, nil)
+	mockRepo.On("FindByID", uint(2)).Return(&types.Timer{
+		ID:        2,
+		SessionID: "session1",
+		MaxTime:   600,
+		State:     types.StateRunning,
+		EndsAt:    clk.Now().Add(5 * time.Minute),
+	}, nil)
+	mockRepo.On("Update", mock.AnythingOfType("*types.Timer")).Return(nil).Once()
+
+	// A stopwatch loses its soft cap.
+	timer, err := service.ModifyTimer(1, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), timer.MaxTime)
+	assert.Nil(t, timer.SoftCapReachedAt)
+	assert.False(t, timer.SoftCapDue(clk.Now().Add(time.Hour)))
+
+	// A countdown cannot do without one.
+	_, err = service.ModifyTimer(2, 0, 0)
+	assert.Equal(t, KindInvalidArgument, KindOf(err))
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAdjustTimerAppliesPenalty(t *testing.T) {
+	mockRepo := new(MockTimerRepository)
+	mockRedis := redis.NewClient(&redis.Options{})
+	mockWS := new(MockWebSocketHandler)
+	logger, _ := zap.NewDevelopment()
+	sugar := logger.Sugar()
+
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	service := NewTimerService(mockRepo, sugar, mockRedis, mockWS, clk)
+
+	runningTimer := &types.Timer{
+		ID:        1,
+		SessionID: "session1",
+		MaxTime:   3600,
+		State:     types.StateRunning,
+		StartedAt: clk.Now().Add(-40 * time.Minute),
+		EndsAt:    clk.Now().Add(20 * time.Minute),
+	}
+
+	mockRepo.On("FindByID", uint(1)).Return(runningTimer, nil)
+	mockRepo.On("Update", mock.AnythingOfType("*types.Timer")).Return(nil).Once()
+	mockWS.On("BroadcastTimerAdjusted", mock.AnythingOfType("*types.Timer"), types.TimerAdjustment{
+		Delta:  -120,
+		Reason: "hint",
+		At:     clk.Now(),
+	}).Return().Once()
+
+	timer, err := service.AdjustTimer(1, 0, -120, "hint")
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1080), timer.CurrentTime)
+	assert.Equal(t, int64(3600), timer.MaxTime)
+	assert.Len(t, timer.Adjustments, 1)
+
+	mockRepo.AssertExpectations(t)
+	mockWS.AssertExpectations(t)
+}
+
+func TestAdjustStoppedTimerRejected(t *testing.T) {
+	mockRepo := new(MockTimerRepository)
+	mockRedis := redis.NewClient(&redis.Options{})
+	mockWS := new(MockWebSocketHandler)
+	logger, _ := zap.NewDevelopment()
+	sugar := logger.Sugar()
+
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	service := NewTimerService(mockRepo, sugar, mockRedis, mockWS, clk)
+
+	stoppedAt := clk.Now().Add(-time.Minute)
+	stoppedTimer := &types.Timer{
+		ID:        1,
+		SessionID: "session1",
+		MaxTime:   3600,
+		State:     types.StateStopped,
+		EndsAt:    clk.Now().Add(20 * time.Minute),
+		StoppedAt: &stoppedAt,
+	}
+
+	mockRepo.On("FindByID", uint(1)).Return(stoppedTimer, nil)
+
+	_, err := service.AdjustTimer(1, 0, 60, "bonus")
+
+	var stateErr *InvalidStateError
+	assert.ErrorAs(t, err, &stateErr)
+	mockWS.AssertNotCalled(t, "BroadcastTimerAdjusted", mock.Anything, mock.Anything)
+}
+
+func TestTimerExpires(t *testing.T) {
+	mockRepo := new(MockTimerRepository)
+	mockRedis := redis.NewClient(&redis.Options{})
+	mockWS := new(MockWebSocketHandler)
+	logger, _ := zap.NewDevelopment()
+	sugar := logger.Sugar()
+
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	service := NewTimerService(mockRepo, sugar, mockRedis, mockWS, clk)
+
+	endsAt := clk.Now().Add(500 * time.Millisecond)
+	activeTimers := []types.Timer{
+		{ID: 1, SessionID: "session1", MaxTime: 60, State: types.StateRunning, EndsAt: endsAt},
+	}
+
+	mockRepo.On("GetActiveTimers").Return(activeTimers, nil).Once()
+	mockRepo.On("Update", mock.MatchedBy(func(timer *types.Timer) bool {
+		return timer.State == types.StateExpired && timer.ExpiredAt != nil && timer.ExpiredAt.Equal(endsAt)
+	})).Return(nil).Once()
+	mockWS.On("BroadcastTimerUpdate", mock.MatchedBy(func(timer *types.Timer) bool {
+		return timer.CurrentTime == 0 && timer.State == types.StateExpired
+	})).Return().Once()
+	mockWS.On("BroadcastTimerExpired", mock.MatchedBy(func(timer *types.Timer) bool {
+		return timer.ID == 1
+	})).Return().Once()
+
+	done := runTimerUpdates(service, clk)
+	clk.Advance(time.Second)
 	service.StopTimerUpdates()
+	<-done
 
 	mockRepo.AssertExpectations(t)
 	mockWS.AssertExpectations(t)
 }
 
-// Add more tests as needed
+func TestPauseExpiredTimerRejected(t *testing.T) {
+	mockRepo := new(MockTimerRepository)
+	mockRedis := redis.NewClient(&redis.Options{})
+	mockWS := new(MockWebSocketHandler)
+	logger, _ := zap.NewDevelopment()
+	sugar := logger.Sugar()
+
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	service := NewTimerService(mockRepo, sugar, mockRedis, mockWS, clk)
+
+	expiredAt := clk.Now().Add(-time.Minute)
+	expiredTimer := &types.Timer{
+		ID:        1,
+		SessionID: "session1",
+		MaxTime:   60,
+		State:     types.StateExpired,
+		EndsAt:    expiredAt,
+		ExpiredAt: &expiredAt,
+	}
+
+	mockRepo.On("FindByID", uint(1)).Return(expiredTimer, nil)
+
+	_, err := service.PauseTimer(1, 0)
+
+	var stateErr *InvalidStateError
+	assert.ErrorAs(t, err, &stateErr)
+	assert.Equal(t, types.StateExpired, stateErr.State)
+
+	_, err = service.ResumeTimer(1, 0)
+	assert.ErrorAs(t, err, &stateErr)
+
+	mockRepo.AssertNotCalled(t, "Update", mock.Anything)
+}
+
+func TestPauseOverdueTimerExpiresIt(t *testing.T) {
+	mockRepo := new(MockTimerRepository)
+	mockRedis := redis.NewClient(&redis.Options{})
+	mockWS := new(MockWebSocketHandler)
+	logger, _ := zap.NewDevelopment()
+	sugar := logger.Sugar()
+
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	service := NewTimerService(mockRepo, sugar, mockRedis, mockWS, clk)
+
+	// Ran out before the tick loop noticed.
+	overdueTimer := &types.Timer{
+		ID:        1,
+		SessionID: "session1",
+		MaxTime:   60,
+		State:     types.StateRunning,
+		EndsAt:    clk.Now().Add(-100 * time.Millisecond),
+	}
+
+	mockRepo.On("FindByID", uint(1)).Return(overdueTimer, nil)
+	mockRepo.On("Update", mock.AnythingOfType("*types.Timer")).Return(nil).Once()
+	mockWS.On("BroadcastTimerUpdate", mock.AnythingOfType("*types.Timer")).Return().Once()
+	mockWS.On("BroadcastTimerExpired", mock.AnythingOfType("*types.Timer")).Return().Once()
+
+	_, err := service.PauseTimer(1, 0)
+
+	var stateErr *InvalidStateError
+	assert.ErrorAs(t, err, &stateErr)
+	assert.Equal(t, types.StateExpired, overdueTimer.State)
+
+	mockRepo.AssertExpectations(t)
+	mockWS.AssertExpectations(t)
+}
+
+func TestStopTimerKeepsFinalState(t *testing.T) {
+	mockRepo := new(MockTimerRepository)
+	mockRedis := redis.NewClient(&redis.Options{})
+	mockWS := new(MockWebSocketHandler)
+	logger, _ := zap.NewDevelopment()
+	sugar := logger.Sugar()
+
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	service := NewTimerService(mockRepo, sugar, mockRedis, mockWS, clk)
+
+	runningTimer := &types.Timer{
+		ID:        1,
+		SessionID: "session1",
+		MaxTime:   60,
+		State:     types.StateRunning,
+		EndsAt:    clk.Now().Add(20 * time.Second),
+	}
+
+	mockRepo.On("FindByID", uint(1)).Return(runningTimer, nil)
+	mockRepo.On("Update", mock.MatchedBy(func(timer *types.Timer) bool {
+		return timer.State == types.StateStopped && timer.StoppedAt != nil && timer.CurrentTime == 20
+	})).Return(nil).Once()
+
+	stopped, err := service.StopTimer(1, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "session1", stopped.SessionID)
+
+	_, err = service.StopTimer(1, 0)
+	var stateErr *InvalidStateError
+	assert.ErrorAs(t, err, &stateErr)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetLiveTimersSyncsClock(t *testing.T) {
+	mockRepo := new(MockTimerRepository)
+	mockRedis := redis.NewClient(&redis.Options{})
+	mockWS := new(MockWebSocketHandler)
+
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	service := NewTimerService(mockRepo, zap.NewNop().Sugar(), mockRedis, mockWS, clk)
+
+	mockRepo.On("FindTimers", repository.TimerQuery{
+		SessionID: "session1",
+		States:    []types.TimerState{types.StateCreated, types.StateRunning, types.StatePaused},
+	}).Return([]types.Timer{{
+		ID:        1,
+		SessionID: "session1",
+		MaxTime:   60,
+		State:     types.StateRunning,
+		StartedAt: clk.Now().Add(-15 * time.Second),
+		EndsAt:    clk.Now().Add(45 * time.Second),
+	}}, nil)
+
+	timers, err := service.GetLiveTimers("session1")
+	assert.NoError(t, err)
+	if assert.Len(t, timers, 1) {
+		assert.Equal(t, int64(45), timers[0].CurrentTime)
+		assert.Equal(t, int64(15), timers[0].ElapsedTime)
+	}
+}
+
+func TestListTimersReturnsNextCursor(t *testing.T) {
+	mockRepo := new(MockTimerRepository)
+	mockRedis := redis.NewClient(&redis.Options{})
+	mockWS := new(MockWebSocketHandler)
+
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	service := NewTimerService(mockRepo, zap.NewNop().Sugar(), mockRedis, mockWS, clk)
+
+	timers := []types.Timer{
+		{ID: 3, SessionID: "session1", State: types.StateCreated, CreatedAt: clk.Now()},
+		{ID: 2, SessionID: "session1", State: types.StateCreated, CreatedAt: clk.Now().Add(-time.Minute)},
+		{ID: 1, SessionID: "session1", State: types.StateCreated, CreatedAt: clk.Now().Add(-2 * time.Minute)},
+	}
+	mockRepo.On("FindTimers", mock.MatchedBy(func(query repository.TimerQuery) bool {
+		return query.Limit == 3 && query.SessionID == "session1"
+	})).Return(timers, nil).Once()
+
+	page, next, err := service.ListTimers(repository.TimerQuery{SessionID: "session1", Limit: 2})
+	assert.NoError(t, err)
+	assert.Len(t, page, 2)
+	if assert.NotNil(t, next) {
+		assert.Equal(t, repository.CursorFor(&timers[1]), *next)
+	}
+
+	mockRepo.On("FindTimers", mock.MatchedBy(func(query repository.TimerQuery) bool {
+		return query.Limit == 4
+	})).Return(timers, nil).Once()
+
+	page, next, err = service.ListTimers(repository.TimerQuery{SessionID: "session1", Limit: 3})
+	assert.NoError(t, err)
+	assert.Len(t, page, 3)
+	assert.Nil(t, next)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateTimerRejectsInvalidRequest(t *testing.T) {
+	mockRepo := new(MockTimerRepository)
+	mockRedis := redis.NewClient(&redis.Options{})
+	mockWS := new(MockWebSocketHandler)
+
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	service := NewTimerService(mockRepo, zap.NewNop().Sugar(), mockRedis, mockWS, clk)
+
+	for _, req := range []types.TimerRequest{
+		{MaxTime: 60},
+		{SessionID: "session1", MaxTime: -1},
+		{SessionID: "session1", MaxTime: 60, Mode: "sideways"},
+	} {
+		_, err := service.CreateTimer(req)
+		assert.Equal(t, KindInvalidArgument, KindOf(err), "%+v", req)
+	}
+
+	_, err := service.ModifyTimer(1, 0, -1)
+	assert.Equal(t, KindInvalidArgument, KindOf(err))
+
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything)
+	mockRepo.AssertNotCalled(t, "FindByID", mock.Anything)
+}
+
+func TestMissingTimerIsNotFound(t *testing.T) {
+	mockRepo := new(MockTimerRepository)
+	mockRedis := redis.NewClient(&redis.Options{})
+	mockWS := new(MockWebSocketHandler)
+
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	service := NewTimerService(mockRepo, zap.NewNop().Sugar(), mockRedis, mockWS, clk)
+
+	mockRepo.On("FindByID", uint(9)).Return((*types.Timer)(nil), gorm.ErrRecordNotFound)
+
+	_, err := service.PauseTimer(9, 0)
+	assert.Equal(t, KindNotFound, KindOf(err))
+	assert.EqualError(t, err, "timer 9 not found")
+
+	_, err = service.StopTimer(9, 0)
+	assert.Equal(t, KindNotFound, KindOf(err))
+}
+
+// runTimerUpdates starts the tick loop and waits for its ticker to be
+// registered with the fake clock. The returned channel is closed once the
+// loop has exited.
+func runTimerUpdates(service TimerServiceInterface, clk *clocktest.Fake) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		service.StartTimerUpdates()
+		close(done)
+	}()
+	clk.BlockUntil(1)
+	return done
+}
+
+func TestPauseTimerRetriesVersionConflict(t *testing.T) {
+	mockRepo := new(MockTimerRepository)
+	mockRedis := redis.NewClient(&redis.Options{})
+	mockWS := new(MockWebSocketHandler)
+
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	service := NewTimerService(mockRepo, zap.NewNop().Sugar(), mockRedis, mockWS, clk)
+
+	runningTimer := func(version uint) *types.Timer {
+		return &types.Timer{
+			ID:        1,
+			SessionID: "session1",
+			MaxTime:   60,
+			State:     types.StateRunning,
+			EndsAt:    clk.Now().Add(30 * time.Second),
+			Version:   version,
+		}
+	}
+
+	// The tick loop saved the timer between the first load and its update.
+	mockRepo.On("FindByID", uint(1)).Return(runningTimer(1), nil).Once()
+	mockRepo.On("FindByID", uint(1)).Return(runningTimer(2), nil).Once()
+	mockRepo.On("Update", mock.MatchedBy(func(timer *types.Timer) bool {
+		return timer.Version == 1
+	})).Return(repository.ErrVersionConflict).Once()
+	mockRepo.On("Update", mock.MatchedBy(func(timer *types.Timer) bool {
+		return timer.Version == 2 && timer.IsPaused
+	})).Return(nil).Once()
+	mockWS.On("BroadcastTimerUpdate", mock.AnythingOfType("*types.Timer")).Return()
+
+	timer, err := service.PauseTimer(1, 0)
+	assert.NoError(t, err)
+	assert.True(t, timer.IsPaused)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPauseTimerGivesUpAfterRepeatedConflicts(t *testing.T) {
+	mockRepo := new(MockTimerRepository)
+	mockRedis := redis.NewClient(&redis.Options{})
+	mockWS := new(MockWebSocketHandler)
+
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	service := NewTimerService(mockRepo, zap.NewNop().Sugar(), mockRedis, mockWS, clk)
+
+	for i := 0; i < maxUpdateAttempts; i++ {
+		mockRepo.On("FindByID", uint(1)).Return(&types.Timer{
+			ID:        1,
+			SessionID: "session1",
+			MaxTime:   60,
+			State:     types.StateRunning,
+			EndsAt:    clk.Now().Add(30 * time.Second),
+			Version:   1,
+		}, nil).Once()
+	}
+	mockRepo.On("Update", mock.AnythingOfType("*types.Timer")).Return(repository.ErrVersionConflict)
+
+	_, err := service.PauseTimer(1, 0)
+	assert.Equal(t, KindConflict, KindOf(err))
+
+	mockRepo.AssertNumberOfCalls(t, "Update", maxUpdateAttempts)
+}
+
+func TestStaleIfMatchIsPreconditionFailed(t *testing.T) {
+	mockRepo := new(MockTimerRepository)
+	mockRedis := redis.NewClient(&redis.Options{})
+	mockWS := new(MockWebSocketHandler)
+
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	service := NewTimerService(mockRepo, zap.NewNop().Sugar(), mockRedis, mockWS, clk)
+
+	mockRepo.On("FindByID", uint(1)).Return(&types.Timer{
+		ID:        1,
+		SessionID: "session1",
+		MaxTime:   60,
+		State:     types.StateRunning,
+		EndsAt:    clk.Now().Add(30 * time.Second),
+		Version:   3,
+	}, nil)
+
+	_, err := service.PauseTimer(1, 2)
+	assert.Equal(t, KindPreconditionFailed, KindOf(err))
+
+	_, err = service.ModifyTimer(1, 2, 120)
+	assert.Equal(t, KindPreconditionFailed, KindOf(err))
+
+	_, err = service.StopTimer(1, 2)
+	assert.Equal(t, KindPreconditionFailed, KindOf(err))
+
+	mockRepo.AssertNotCalled(t, "Update", mock.Anything)
+}
+
+func TestTickSkipsTimerChangedConcurrently(t *testing.T) {
+	mockRepo := new(MockTimerRepository)
+	mockRedis := redis.NewClient(&redis.Options{})
+	mockWS := new(MockWebSocketHandler)
+
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	service := NewTimerService(mockRepo, zap.NewNop().Sugar(), mockRedis, mockWS, clk)
+
+	// Ran out, but a game master modified it after the tick loaded it.
+	mockRepo.On("GetActiveTimers").Return([]types.Timer{{
+		ID:        1,
+		SessionID: "session1",
+		MaxTime:   60,
+		State:     types.StateRunning,
+		EndsAt:    clk.Now().Add(-time.Second),
+		Version:   1,
+	}}, nil)
+	mockRepo.On("Update", mock.AnythingOfType("*types.Timer")).Return(repository.ErrVersionConflict)
+
+	service.(*TimerService).updateTimers(clk.Now())
+
+	mockWS.AssertNotCalled(t, "BroadcastTimerExpired", mock.Anything)
+	mockWS.AssertNotCalled(t, "BroadcastTimerUpdate", mock.Anything)
+}
+
+func TestOnlyLeaseHolderTicks(t *testing.T) {
+	redisClient, mr := newTestRedis(t)
+
+	newInstance := func() (*TimerService, *MockTimerRepository, *clocktest.Fake) {
+		service, mockRepo, clk := newTestService(t, redisClient)
+		service.SetTickLease(lease.New(redisClient, "lease:ticks", 3*time.Second))
+		return service, mockRepo, clk
+	}
+	first, firstRepo, firstClock := newInstance()
+	second, _, _ := newInstance()
+
+	assert.True(t, first.leadsTicks())
+	assert.False(t, second.leadsTicks())
+
+	firstRepo.On("GetActiveTimers").Return([]types.Timer{}, nil).Once()
+	done := runTimerUpdates(first, firstClock)
+	firstClock.Advance(time.Second)
+	first.StopTimerUpdates()
+	<-done
+	firstRepo.AssertExpectations(t)
+
+	// Stopping hands the tick loop over at once.
+	assert.True(t, second.leadsTicks())
+	assert.False(t, first.leadsTicks())
+
+	// An instance that dies loses it once the lease expires.
+	mr.FastForward(3 * time.Second)
+	assert.True(t, first.leadsTicks())
+	assert.False(t, second.leadsTicks())
+}
@@ -0,0 +1,137 @@
+// Package storage opens the repositories of the configured storage backend.
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+
+	"timer-microservice/internal/config"
+	"timer-microservice/internal/migrations"
+	"timer-microservice/internal/repository"
+
+	"go.uber.org/zap"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// Storage backends, as named by STORAGE_DRIVER.
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+	DriverMemory   = "memory"
+)
+
+// DefaultSQLitePath is the database file of the sqlite backend unless
+// SQLITE_PATH names another.
+const DefaultSQLitePath = "timers.db"
+
+// Storage holds the repositories of one backend.
+type Storage struct {
+	Timers repository.TimerRepository
+	Hints  repository.HintRepository
+
+	// db is nil for the memory backend.
+	db *gorm.DB
+}
+
+// Open connects to the backend named by cfg.StorageDriver, MySQL by
+// default, and applies its pending migrations.
+func Open(cfg *config.Config, logger *zap.SugaredLogger) (*Storage, error) {
+	if cfg.StorageDriver == DriverMemory {
+		return &Storage{
+			Timers: repository.NewMemoryTimerRepository(),
+			Hints:  repository.NewMemoryHintRepository(),
+		}, nil
+	}
+
+	db, err := OpenDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+	store := &Storage{
+		Timers: repository.NewTimerRepository(db),
+		Hints:  repository.NewHintRepository(db),
+		db:     db,
+	}
+
+	migrator, err := migrations.New(db, logger)
+	if err == nil {
+		_, err = migrator.Up()
+	}
+	if err != nil {
+		store.Close()
+		return nil, fmt.Errorf("migrate database schema: %w", err)
+	}
+	return store, nil
+}
+
+// OpenDB connects to the database of the backend named by
+// cfg.StorageDriver without migrating it.
+func OpenDB(cfg *config.Config) (*gorm.DB, error) {
+	switch cfg.StorageDriver {
+	case "", DriverMySQL:
+		db, err := sql.Open("mysql", cfg.GetDatabaseDSN())
+		if err != nil {
+			return nil, err
+		}
+		return openPool(mysql.New(mysql.Config{Conn: db}))
+	case DriverPostgres:
+		return openPool(postgres.Open(cfg.GetDatabaseDSN()))
+	case DriverSQLite:
+		path := cfg.SQLitePath
+		if path == "" {
+			path = DefaultSQLitePath
+		}
+		return openSQLite(path)
+	case DriverMemory:
+		return nil, fmt.Errorf("the %s backend has no database", DriverMemory)
+	}
+	return nil, fmt.Errorf("unknown STORAGE_DRIVER %q, want %s, %s, %s or %s", cfg.StorageDriver, DriverMySQL, DriverPostgres, DriverSQLite, DriverMemory)
+}
+
+// openPool opens a database server connection pool.
+func openPool(dialector gorm.Dialector) (*gorm.DB, error) {
+	gormDb, err := gorm.Open(dialector, &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	db, err := gormDb.DB()
+	if err != nil {
+		return nil, err
+	}
+	db.SetConnMaxLifetime(0)
+	db.SetMaxIdleConns(50)
+	db.SetMaxOpenConns(50)
+	return gormDb, nil
+}
+
+// openSQLite opens the database file at path, creating it if needed.
+func openSQLite(path string) (*gorm.DB, error) {
+	gormDb, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	db, err := gormDb.DB()
+	if err != nil {
+		return nil, err
+	}
+	// SQLite takes one writer at a time. A single connection queues writes
+	// instead of failing them as busy.
+	db.SetMaxOpenConns(1)
+	return gormDb, nil
+}
+
+// Close closes the database connections.
+func (s *Storage) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	db, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return db.Close()
+}
@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"timer-microservice/internal/config"
+	"timer-microservice/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestOpenSQLiteKeepsTimers(t *testing.T) {
+	cfg := &config.Config{StorageDriver: DriverSQLite, SQLitePath: filepath.Join(t.TempDir(), "timers.db")}
+
+	store, err := Open(cfg, zap.NewNop().Sugar())
+	require.NoError(t, err)
+	timer := &types.Timer{SessionID: "room-a", State: types.StateRunning, Version: 1}
+	require.NoError(t, store.Timers.Create(timer))
+	require.NoError(t, store.Close())
+
+	store, err = Open(cfg, zap.NewNop().Sugar())
+	require.NoError(t, err)
+	defer store.Close()
+	found, err := store.Timers.FindByID(timer.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "room-a", found.SessionID)
+}
+
+func TestOpenMemory(t *testing.T) {
+	store, err := Open(&config.Config{StorageDriver: DriverMemory}, zap.NewNop().Sugar())
+	require.NoError(t, err)
+	assert.NoError(t, store.Hints.Create(&types.Hint{SessionID: "room-a", Text: "look up"}))
+	assert.NoError(t, store.Close())
+}
+
+func TestOpenUnknownDriver(t *testing.T) {
+	_, err := Open(&config.Config{StorageDriver: "mongodb"}, zap.NewNop().Sugar())
+	assert.ErrorContains(t, err, `unknown STORAGE_DRIVER "mongodb"`)
+}
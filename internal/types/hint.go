@@ -0,0 +1,41 @@
+package types
+
+import "time"
+
+type Hint struct {
+	ID        uint   `gorm:"primarykey"`
+	SessionID string `gorm:"index"`
+	TimerID   uint   `gorm:"index"`
+	Text      string `gorm:"type:text"`
+	Penalty   int64
+	CreatedAt time.Time
+}
+
+// HintRequest is sent by a game master with HINT_SENT. TimerID may be left
+// out to use the session's current timer, and Penalty overrides the
+// configured hint penalty in seconds.
+type HintRequest struct {
+	TimerID uint   `json:"timerId,omitempty"`
+	Text    string `json:"text"`
+	Penalty *int64 `json:"penalty,omitempty"`
+}
+
+type HintResponse struct {
+	ID        uint      `json:"id"`
+	SessionID string    `json:"sessionId"`
+	TimerID   uint      `json:"timerId"`
+	Text      string    `json:"text"`
+	Penalty   int64     `json:"penalty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func NewHintResponse(hint *Hint) HintResponse {
+	return HintResponse{
+		ID:        hint.ID,
+		SessionID: hint.SessionID,
+		TimerID:   hint.TimerID,
+		Text:      hint.Text,
+		Penalty:   hint.Penalty,
+		CreatedAt: hint.CreatedAt,
+	}
+}
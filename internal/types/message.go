@@ -1,19 +1,113 @@
 package types
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 type MessageType string
 
 const (
-	TypeTimerUpdate MessageType = "TIMER_UPDATE"
-	TypeTimerCreate MessageType = "TIMER_CREATE"
-	TypeTimerPause  MessageType = "TIMER_PAUSE"
-	TypeTimerResume MessageType = "TIMER_RESUME"
-	TypeTimerStop   MessageType = "TIMER_STOP"
-	TypeTimerModify MessageType = "TIMER_MODIFY"
+	TypeTimerUpdate  MessageType = "TIMER_UPDATE"
+	TypeTimerCreate  MessageType = "TIMER_CREATE"
+	TypeTimerPause   MessageType = "TIMER_PAUSE"
+	TypeTimerResume  MessageType = "TIMER_RESUME"
+	TypeTimerStop    MessageType = "TIMER_STOP"
+	TypeTimerModify  MessageType = "TIMER_MODIFY"
+	TypeTimerAdjust  MessageType = "TIMER_ADJUST"
+	TypeTimerExpired MessageType = "TIMER_EXPIRED"
+	TypeTimerSoftCap MessageType = "TIMER_SOFT_CAP_REACHED"
+	TypeHintSent     MessageType = "HINT_SENT"
+	TypePresence     MessageType = "CLIENT_PRESENCE"
+	TypeSnapshot     MessageType = "SNAPSHOT"
+	TypeAck          MessageType = "ACK"
+	TypeError        MessageType = "ERROR"
+)
+
+// ErrorCode tells a client why its command failed.
+type ErrorCode string
+
+const (
+	ErrCodeInvalidMessage     ErrorCode = "invalid_message"
+	ErrCodeInvalidPayload     ErrorCode = "invalid_payload"
+	ErrCodeUnknownType        ErrorCode = "unknown_type"
+	ErrCodeUnauthorized       ErrorCode = "unauthorized"
+	ErrCodeForbidden          ErrorCode = "forbidden"
+	ErrCodeNotFound           ErrorCode = "not_found"
+	ErrCodeInvalidArgument    ErrorCode = "invalid_argument"
+	ErrCodeConflict           ErrorCode = "conflict"
+	ErrCodeInvalidState       ErrorCode = "invalid_state"
+	ErrCodePreconditionFailed ErrorCode = "precondition_failed"
+	ErrCodeInternal           ErrorCode = "internal"
 )
 
+// AckPayload is sent with ACK once a command has been carried out.
+type AckPayload struct {
+	Command MessageType `json:"command"`
+}
+
+// ErrorPayload is sent with ERROR when a command fails. Errors lists the
+// invalid fields of a rejected payload.
+type ErrorPayload struct {
+	Command MessageType  `json:"command,omitempty"`
+	Code    ErrorCode    `json:"code"`
+	Message string       `json:"message"`
+	Errors  []FieldError `json:"errors,omitempty"`
+}
+
+// SnapshotPayload is sent with SNAPSHOT when a client connects, so it can
+// render the current timers without waiting for the next tick. ServerTime
+// lets the client measure its clock offset and Protocol is the protocol
+// version negotiated for the connection.
+type SnapshotPayload struct {
+	Timers     []Timer   `json:"timers"`
+	ServerTime time.Time `json:"serverTime"`
+	Protocol   int       `json:"protocol"`
+}
+
+type PresenceStatus string
+
+const (
+	PresenceOnline  PresenceStatus = "online"
+	PresenceOffline PresenceStatus = "offline"
+)
+
+// PresencePayload is sent to game masters with CLIENT_PRESENCE when a
+// customer display connects or drops off. Clients is the number of displays
+// still connected to the session; Reason says why an offline client went
+// away, "timeout" meaning it stopped answering pings.
+type PresencePayload struct {
+	SessionID string         `json:"sessionId"`
+	Status    PresenceStatus `json:"status"`
+	Reason    string         `json:"reason,omitempty"`
+	Clients   int            `json:"clients"`
+	At        time.Time      `json:"at"`
+}
+
+// TimerIDPayload is the payload of TIMER_PAUSE, TIMER_RESUME and
+// TIMER_STOP from protocol version 2 on.
+type TimerIDPayload struct {
+	TimerID uint `json:"timerId"`
+}
+
+// TimerModifyPayload is the payload of TIMER_MODIFY from protocol version 2
+// on.
+type TimerModifyPayload struct {
+	TimerID uint  `json:"timerId"`
+	MaxTime int64 `json:"maxTime"`
+}
+
+// TimerAdjustedPayload is broadcast with TIMER_ADJUST once a penalty or
+// bonus has been applied.
+type TimerAdjustedPayload struct {
+	Timer      *Timer          `json:"timer"`
+	Adjustment TimerAdjustment `json:"adjustment"`
+}
+
+// WebSocketMessage is the envelope of every message. A client may set
+// RequestID on a command; the ACK or ERROR reply carries it back.
 type WebSocketMessage struct {
-	Type    MessageType     `json:"type"`
-	Payload json.RawMessage `json:"payload"`
+	Type      MessageType     `json:"type"`
+	RequestID string          `json:"requestId,omitempty"`
+	Payload   json.RawMessage `json:"payload"`
 }
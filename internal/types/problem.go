@@ -0,0 +1,23 @@
+package types
+
+// Problem is an RFC 7807 problem details body, sent as
+// application/problem+json when a REST request fails. Code is the same
+// error code WebSocket clients receive, RequestID the X-Request-Id the
+// request was logged under and Errors the invalid fields of the request.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      ErrorCode    `json:"code,omitempty"`
+	RequestID string       `json:"requestId,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes what is wrong with one field of a request. Field is
+// empty when the request as a whole is malformed.
+type FieldError struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
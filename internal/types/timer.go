@@ -1,22 +1,266 @@
 package types
 
+import (
+	"math"
+	"time"
+)
+
+type TimerState string
+
+const (
+	StateCreated TimerState = "created"
+	StateRunning TimerState = "running"
+	StatePaused  TimerState = "paused"
+	StateExpired TimerState = "expired"
+	StateStopped TimerState = "stopped"
+)
+
+// timerTransitions lists the states each state may move to. Stopped is
+// final; an expired timer can only be stopped.
+var timerTransitions = map[TimerState][]TimerState{
+	StateCreated: {StateRunning, StateStopped},
+	StateRunning: {StatePaused, StateExpired, StateStopped},
+	StatePaused:  {StateRunning, StateStopped},
+	StateExpired: {StateStopped},
+}
+
+// CanTransitionTo reports whether a timer in state s may move to next.
+func (s TimerState) CanTransitionTo(next TimerState) bool {
+	for _, allowed := range timerTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+type TimerMode string
+
+const (
+	ModeCountdown TimerMode = "countdown"
+	ModeCountUp   TimerMode = "countup"
+)
+
 type Timer struct {
 	ID          uint   `gorm:"primarykey"`
 	SessionID   string `gorm:"index"`
 	MaxTime     int64
 	CurrentTime int64
 	IsPaused    bool
+	State       TimerState `gorm:"type:varchar(16);index"`
+	Mode        TimerMode  `gorm:"type:varchar(16);default:countdown"`
+	ElapsedTime int64      `gorm:"-"`
+
+	// AllowOvertime lets a countdown keep running past zero. Overtime is
+	// how far it has overrun, in seconds; it is kept on the stopped timer
+	// for reporting.
+	AllowOvertime bool
+	Overtime      int64
+
+	// EndsAt is the instant the countdown reaches zero. While the timer is
+	// paused it is frozen and PausedAt records when the pause began, so the
+	// remaining time is always derived from the clock rather than from a
+	// counter decremented on every tick. PausedTotal accumulates the time
+	// spent paused since StartedAt.
+	StartedAt   time.Time
+	EndsAt      time.Time `gorm:"index"`
+	PausedAt    *time.Time
+	PausedTotal time.Duration
+	ExpiredAt   *time.Time
+	StoppedAt   *time.Time
+
+	// SoftCapReachedAt is set once a count-up timer passes MaxTime, which
+	// acts as an optional soft cap for stopwatches: reaching it emits an
+	// event but the timer keeps counting.
+	SoftCapReachedAt *time.Time
+
+	// Adjustments lists the penalties and bonuses applied to the timer.
+	Adjustments []TimerAdjustment `gorm:"type:text;serializer:json"`
+	HintCount   int
+
+	CreatedAt time.Time `gorm:"index"`
+
+	// Version is incremented by every update. An update only applies to
+	// the version it was made from, so concurrent writers cannot silently
+	// overwrite each other.
+	Version uint `gorm:"not null;default:1"`
+}
+
+// TimerAdjustment is a signed change to a running timer. A positive Delta
+// gives a countdown more time and adds to a stopwatch's elapsed time.
+type TimerAdjustment struct {
+	Delta  int64     `json:"delta"`
+	Reason string    `json:"reason"`
+	At     time.Time `json:"at"`
+}
+
+// IsCountUp reports whether the timer is a stopwatch. Timers stored before
+// modes existed have no mode and count down.
+func (t *Timer) IsCountUp() bool {
+	return t.Mode == ModeCountUp
+}
+
+// reference returns the instant the timer's clock stands at: now while it
+// runs, or the moment it was paused, expired or stopped.
+func (t *Timer) reference(now time.Time) time.Time {
+	switch {
+	case t.IsPaused && t.PausedAt != nil:
+		return *t.PausedAt
+	case t.StoppedAt != nil:
+		return *t.StoppedAt
+	case t.State == StateExpired && t.ExpiredAt != nil:
+		return *t.ExpiredAt
+	}
+	return now
+}
+
+// Remaining returns the time left on the countdown at now. It never goes
+// below zero and stays frozen once the timer is paused or stopped.
+func (t *Timer) Remaining(now time.Time) time.Duration {
+	if t.IsCountUp() {
+		return 0
+	}
+
+	remaining := t.EndsAt.Sub(t.reference(now))
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// OvertimeAt returns how far a countdown has run past zero at now.
+func (t *Timer) OvertimeAt(now time.Time) time.Duration {
+	if t.IsCountUp() {
+		return 0
+	}
+
+	overtime := t.reference(now).Sub(t.EndsAt)
+	if overtime < 0 {
+		return 0
+	}
+	return overtime
+}
+
+// Elapsed returns how long the timer has been running at now, excluding
+// the time spent paused.
+func (t *Timer) Elapsed(now time.Time) time.Duration {
+	elapsed := t.reference(now).Sub(t.StartedAt) - t.PausedTotal
+	if elapsed < 0 {
+		return 0
+	}
+	return elapsed
+}
+
+// HasRunOut reports whether a countdown has reached zero at now. Count-up
+// timers never run out.
+func (t *Timer) HasRunOut(now time.Time) bool {
+	return !t.IsCountUp() && t.Remaining(now) == 0
+}
+
+// SoftCapDue reports whether a count-up timer has just passed its soft cap.
+func (t *Timer) SoftCapDue(now time.Time) bool {
+	return t.IsCountUp() && t.MaxTime > 0 && t.SoftCapReachedAt == nil &&
+		t.Elapsed(now) >= time.Duration(t.MaxTime)*time.Second
+}
+
+// Adjust applies delta seconds to the timer's clock without restarting it.
+func (t *Timer) Adjust(delta int64, reason string, now time.Time) TimerAdjustment {
+	shift := time.Duration(delta) * time.Second
+	if t.IsCountUp() {
+		t.StartedAt = t.StartedAt.Add(-shift)
+		if t.MaxTime > 0 && t.Elapsed(now) < time.Duration(t.MaxTime)*time.Second {
+			t.SoftCapReachedAt = nil
+		}
+	} else {
+		t.EndsAt = t.EndsAt.Add(shift)
+		if t.Remaining(now) > 0 {
+			t.ExpiredAt = nil
+		}
+	}
+
+	adjustment := TimerAdjustment{Delta: delta, Reason: reason, At: now}
+	t.Adjustments = append(t.Adjustments, adjustment)
+	t.Sync(now)
+	return adjustment
+}
+
+// Sync refreshes CurrentTime, ElapsedTime and Overtime from the clock.
+// CurrentTime is the value clients display: the remaining time for a
+// countdown, rounded up so it only shows 0 once it has actually run out and
+// negative while in overtime, or the elapsed time for a stopwatch.
+func (t *Timer) Sync(now time.Time) {
+	t.ElapsedTime = int64(t.Elapsed(now) / time.Second)
+	if t.IsCountUp() {
+		t.CurrentTime = t.ElapsedTime
+		return
+	}
+
+	t.Overtime = 0
+	if t.AllowOvertime {
+		t.Overtime = int64(t.OvertimeAt(now) / time.Second)
+	}
+	t.CurrentTime = int64(math.Ceil(t.Remaining(now).Seconds())) - t.Overtime
 }
 
 type TimerRequest struct {
-	SessionID string `json:"sessionId"`
-	MaxTime   int64  `json:"maxTime"`
+	SessionID     string    `json:"sessionId"`
+	MaxTime       int64     `json:"maxTime"`
+	Mode          TimerMode `json:"mode,omitempty"`
+	AllowOvertime bool      `json:"allowOvertime,omitempty"`
+}
+
+// TimerModifyRequest is the body of the REST modify endpoint, which takes
+// the timer ID from the URL.
+type TimerModifyRequest struct {
+	MaxTime int64 `json:"maxTime"`
+}
+
+type TimerAdjustRequest struct {
+	TimerID uint   `json:"timerId"`
+	Delta   int64  `json:"delta"`
+	Reason  string `json:"reason"`
 }
 
 type TimerResponse struct {
-	ID          uint   `json:"id"`
-	SessionID   string `json:"sessionId"`
-	CurrentTime int64  `json:"currentTime"`
-	MaxTime     int64  `json:"maxTime"`
-	IsPaused    bool   `json:"isPaused"`
+	ID            uint       `json:"id"`
+	SessionID     string     `json:"sessionId"`
+	CurrentTime   int64      `json:"currentTime"`
+	MaxTime       int64      `json:"maxTime"`
+	IsPaused      bool       `json:"isPaused"`
+	State         TimerState `json:"state"`
+	Mode          TimerMode  `json:"mode"`
+	ElapsedTime   int64      `json:"elapsedTime"`
+	AllowOvertime bool       `json:"allowOvertime"`
+	Overtime      int64      `json:"overtime"`
+
+	Adjustments []TimerAdjustment `json:"adjustments,omitempty"`
+	HintCount   int               `json:"hintCount"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	Version     uint              `json:"version"`
+}
+
+// TimerListResponse is a page of timers. NextCursor is set when there are
+// more timers to fetch.
+type TimerListResponse struct {
+	Timers     []TimerResponse `json:"timers"`
+	NextCursor string          `json:"nextCursor,omitempty"`
+}
+
+func NewTimerResponse(timer *Timer) TimerResponse {
+	return TimerResponse{
+		ID:            timer.ID,
+		SessionID:     timer.SessionID,
+		CurrentTime:   timer.CurrentTime,
+		MaxTime:       timer.MaxTime,
+		IsPaused:      timer.IsPaused,
+		State:         timer.State,
+		Mode:          timer.Mode,
+		ElapsedTime:   timer.ElapsedTime,
+		AllowOvertime: timer.AllowOvertime,
+		Overtime:      timer.Overtime,
+		Adjustments:   timer.Adjustments,
+		HintCount:     timer.HintCount,
+		CreatedAt:     timer.CreatedAt,
+		Version:       timer.Version,
+	}
 }
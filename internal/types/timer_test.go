@@ -0,0 +1,96 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemainingHasNoDrift(t *testing.T) {
+	start := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	timer := &Timer{
+		MaxTime:   3600,
+		StartedAt: start,
+		EndsAt:    start.Add(time.Hour),
+	}
+
+	// Irregular ticks must not affect the remaining time.
+	now := start
+	for _, step := range []time.Duration{900 * time.Millisecond, 3 * time.Second, 1100 * time.Millisecond} {
+		now = now.Add(step)
+		timer.Sync(now)
+	}
+	assert.Equal(t, int64(3595), timer.CurrentTime)
+
+	timer.Sync(start.Add(time.Hour))
+	assert.Equal(t, int64(0), timer.CurrentTime)
+
+	timer.Sync(start.Add(2 * time.Hour))
+	assert.Equal(t, int64(0), timer.CurrentTime)
+}
+
+func TestRemainingWhilePaused(t *testing.T) {
+	start := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	pausedAt := start.Add(10 * time.Minute)
+	timer := &Timer{
+		MaxTime:   3600,
+		IsPaused:  true,
+		StartedAt: start,
+		EndsAt:    start.Add(time.Hour),
+		PausedAt:  &pausedAt,
+	}
+
+	assert.Equal(t, 50*time.Minute, timer.Remaining(start.Add(45*time.Minute)))
+}
+
+func TestStateTransitions(t *testing.T) {
+	assert.True(t, StateRunning.CanTransitionTo(StatePaused))
+	assert.True(t, StatePaused.CanTransitionTo(StateRunning))
+	assert.True(t, StateExpired.CanTransitionTo(StateStopped))
+	assert.False(t, StateExpired.CanTransitionTo(StatePaused))
+	assert.False(t, StateExpired.CanTransitionTo(StateRunning))
+	assert.False(t, StateStopped.CanTransitionTo(StateRunning))
+}
+
+func TestOvertimeCountsBelowZero(t *testing.T) {
+	start := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	timer := &Timer{
+		MaxTime:       60,
+		State:         StateRunning,
+		AllowOvertime: true,
+		StartedAt:     start,
+		EndsAt:        start.Add(time.Minute),
+	}
+
+	timer.Sync(start.Add(30 * time.Second))
+	assert.Equal(t, int64(30), timer.CurrentTime)
+	assert.Equal(t, int64(0), timer.Overtime)
+
+	timer.Sync(start.Add(95 * time.Second))
+	assert.Equal(t, int64(-35), timer.CurrentTime)
+	assert.Equal(t, int64(35), timer.Overtime)
+
+	stoppedAt := start.Add(100 * time.Second)
+	timer.StoppedAt = &stoppedAt
+	timer.Sync(start.Add(time.Hour))
+	assert.Equal(t, int64(40), timer.Overtime)
+}
+
+func TestAdjust(t *testing.T) {
+	start := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	now := start.Add(10 * time.Minute)
+
+	countdown := &Timer{MaxTime: 3600, State: StateRunning, StartedAt: start, EndsAt: start.Add(time.Hour)}
+	countdown.Adjust(-120, "hint", now)
+	assert.Equal(t, int64(48*60), countdown.CurrentTime)
+	assert.Equal(t, int64(3600), countdown.MaxTime)
+
+	countdown.Adjust(300, "bonus", now)
+	assert.Equal(t, int64(53*60), countdown.CurrentTime)
+	assert.Len(t, countdown.Adjustments, 2)
+
+	stopwatch := &Timer{State: StateRunning, Mode: ModeCountUp, StartedAt: start, EndsAt: start}
+	stopwatch.Adjust(120, "hint", now)
+	assert.Equal(t, int64(12*60), stopwatch.CurrentTime)
+}
@@ -0,0 +1,10 @@
+package types
+
+import "time"
+
+// TokenResponse carries a token minted for a room display.
+type TokenResponse struct {
+	Token     string    `json:"token"`
+	SessionID string    `json:"sessionId"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
@@ -0,0 +1,277 @@
+// Package validation checks the timer requests of the REST and WebSocket
+// APIs, so that both reject the same input with the same field errors.
+package validation
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"timer-microservice/internal/types"
+)
+
+// Rules are the limits requests are checked against.
+type Rules struct {
+	// MinDuration and MaxDuration bound the maxTime of a timer. A count-up
+	// timer may also have no soft cap at all.
+	MinDuration time.Duration
+	MaxDuration time.Duration
+
+	// SessionIDMaxLength and SessionIDPattern constrain session IDs. The
+	// default pattern leaves out the glob characters of token scopes.
+	SessionIDMaxLength int
+	SessionIDPattern   *regexp.Regexp
+}
+
+var defaultSessionIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+func DefaultRules() Rules {
+	return Rules{
+		MinDuration:        time.Second,
+		MaxDuration:        24 * time.Hour,
+		SessionIDMaxLength: 64,
+		SessionIDPattern:   defaultSessionIDPattern,
+	}
+}
+
+// withDefaults fills the zero fields of r from DefaultRules.
+func (r Rules) withDefaults() Rules {
+	defaults := DefaultRules()
+	if r.MinDuration <= 0 {
+		r.MinDuration = defaults.MinDuration
+	}
+	if r.MaxDuration <= 0 {
+		r.MaxDuration = defaults.MaxDuration
+	}
+	if r.SessionIDMaxLength <= 0 {
+		r.SessionIDMaxLength = defaults.SessionIDMaxLength
+	}
+	if r.SessionIDPattern == nil {
+		r.SessionIDPattern = defaults.SessionIDPattern
+	}
+	return r
+}
+
+// Error lists what is wrong with a request, field by field.
+type Error struct {
+	Fields []types.FieldError
+}
+
+func (e *Error) Error() string {
+	parts := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		if field.Field == "" {
+			parts = append(parts, field.Message)
+		} else {
+			parts = append(parts, field.Field+": "+field.Message)
+		}
+	}
+	return "invalid request: " + strings.Join(parts, "; ")
+}
+
+// ErrorCode reports the error to WebSocket clients as invalid_argument.
+func (e *Error) ErrorCode() types.ErrorCode {
+	return types.ErrCodeInvalidArgument
+}
+
+// FieldErrors returns the field errors carried by err, if any.
+func FieldErrors(err error) []types.FieldError {
+	var validationErr *Error
+	if errors.As(err, &validationErr) {
+		return validationErr.Fields
+	}
+	return nil
+}
+
+type Validator struct {
+	rules Rules
+}
+
+func New(rules Rules) *Validator {
+	return &Validator{rules: rules.withDefaults()}
+}
+
+// TimerRequest checks a request to create a timer.
+func (v *Validator) TimerRequest(req types.TimerRequest) error {
+	var fields []types.FieldError
+	fields = v.checkSessionID(fields, req.SessionID)
+
+	switch req.Mode {
+	case "", types.ModeCountdown:
+		fields = v.checkDuration(fields, "maxTime", req.MaxTime)
+	case types.ModeCountUp:
+		fields = v.checkSoftCap(fields, req.MaxTime)
+	default:
+		fields = append(fields, types.FieldError{
+			Field:   "mode",
+			Message: fmt.Sprintf("must be %q or %q", types.ModeCountdown, types.ModeCountUp),
+		})
+	}
+
+	return result(fields)
+}
+
+// MaxTime checks the new maximum of a timer being modified. The mode of the
+// timer is not known yet, so zero passes as the soft cap of a count-up
+// timer; the service refuses it for a countdown.
+func (v *Validator) MaxTime(maxTime int64) error {
+	return result(v.checkSoftCap(nil, maxTime))
+}
+
+// maxReasonLength and maxHintLength bound the free text of adjustments and
+// hints, in characters.
+const (
+	maxReasonLength = 255
+	maxHintLength   = 1000
+)
+
+// Adjustment checks a request to adjust a timer. Delta may be negative but
+// no larger than the longest timer.
+func (v *Validator) Adjustment(req types.TimerAdjustRequest) error {
+	var fields []types.FieldError
+	if maxSeconds := int64(v.rules.MaxDuration / time.Second); req.Delta < -maxSeconds || req.Delta > maxSeconds {
+		fields = append(fields, types.FieldError{
+			Field:   "delta",
+			Message: fmt.Sprintf("must be between -%d and %d seconds", maxSeconds, maxSeconds),
+		})
+	}
+	fields = checkLength(fields, "reason", req.Reason, maxReasonLength)
+	return result(fields)
+}
+
+// HintRequest checks a hint sent by a game master.
+func (v *Validator) HintRequest(req types.HintRequest) error {
+	var fields []types.FieldError
+	if req.Text == "" {
+		fields = append(fields, types.FieldError{Field: "text", Message: "is required"})
+	}
+	fields = checkLength(fields, "text", req.Text, maxHintLength)
+	if req.Penalty != nil {
+		maxSeconds := int64(v.rules.MaxDuration / time.Second)
+		switch {
+		case *req.Penalty < 0:
+			fields = append(fields, types.FieldError{Field: "penalty", Message: "must be at least 0 seconds"})
+		case *req.Penalty > maxSeconds:
+			fields = append(fields, types.FieldError{Field: "penalty", Message: "must be at most " + pluralSeconds(maxSeconds)})
+		}
+	}
+	return result(fields)
+}
+
+// maxIdempotencyKeyLength bounds the idempotency keys clients may send.
+const maxIdempotencyKeyLength = 255
+
+// IdempotencyKey checks a client-chosen idempotency key sent in field,
+// which must be printable ASCII.
+func (v *Validator) IdempotencyKey(field, key string) error {
+	if len(key) > maxIdempotencyKeyLength {
+		return result([]types.FieldError{{Field: field, Message: fmt.Sprintf("must be at most %d characters", maxIdempotencyKeyLength)}})
+	}
+	for _, r := range key {
+		if r < ' ' || r > '~' {
+			return result([]types.FieldError{{Field: field, Message: "must be printable ASCII"}})
+		}
+	}
+	return nil
+}
+
+func (v *Validator) checkSessionID(fields []types.FieldError, sessionID string) []types.FieldError {
+	switch {
+	case sessionID == "":
+		return append(fields, types.FieldError{Field: "sessionId", Message: "is required"})
+	case len(sessionID) > v.rules.SessionIDMaxLength:
+		return append(fields, types.FieldError{
+			Field:   "sessionId",
+			Message: fmt.Sprintf("must be at most %d characters", v.rules.SessionIDMaxLength),
+		})
+	case !v.rules.SessionIDPattern.MatchString(sessionID):
+		return append(fields, types.FieldError{
+			Field:   "sessionId",
+			Message: fmt.Sprintf("must match %s", v.rules.SessionIDPattern),
+		})
+	}
+	return fields
+}
+
+// checkSoftCap checks the maxTime of a count-up timer, where zero leaves
+// the stopwatch without a soft cap.
+func (v *Validator) checkSoftCap(fields []types.FieldError, maxTime int64) []types.FieldError {
+	if maxTime == 0 {
+		return fields
+	}
+	return v.checkDuration(fields, "maxTime", maxTime)
+}
+
+func checkLength(fields []types.FieldError, field, value string, maxLength int) []types.FieldError {
+	if utf8.RuneCountInString(value) > maxLength {
+		return append(fields, types.FieldError{Field: field, Message: fmt.Sprintf("must be at most %d characters", maxLength)})
+	}
+	return fields
+}
+
+// checkDuration bounds seconds, compared in seconds so that absurd values
+// cannot overflow a time.Duration.
+func (v *Validator) checkDuration(fields []types.FieldError, field string, seconds int64) []types.FieldError {
+	minSeconds := int64(v.rules.MinDuration / time.Second)
+	maxSeconds := int64(v.rules.MaxDuration / time.Second)
+	switch {
+	case seconds < minSeconds:
+		return append(fields, types.FieldError{Field: field, Message: "must be at least " + pluralSeconds(minSeconds)})
+	case seconds > maxSeconds:
+		return append(fields, types.FieldError{Field: field, Message: "must be at most " + pluralSeconds(maxSeconds)})
+	}
+	return fields
+}
+
+func pluralSeconds(n int64) string {
+	if n == 1 {
+		return "1 second"
+	}
+	return fmt.Sprintf("%d seconds", n)
+}
+
+func result(fields []types.FieldError) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	return &Error{Fields: fields}
+}
+
+// Decode reads a single JSON value from r into v, rejecting fields v does
+// not have.
+func Decode(r io.Reader, v interface{}) error {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return decodeError(err)
+	}
+	if decoder.More() {
+		return &Error{Fields: []types.FieldError{{Message: "body must hold a single JSON value"}}}
+	}
+	return nil
+}
+
+// Unmarshal is Decode for a payload already in memory.
+func Unmarshal(data []byte, v interface{}) error {
+	return Decode(bytes.NewReader(data), v)
+}
+
+func decodeError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &typeErr):
+		return &Error{Fields: []types.FieldError{{Field: typeErr.Field, Message: "must be of type " + typeErr.Type.String()}}}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no error type for unknown fields.
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return &Error{Fields: []types.FieldError{{Field: field, Message: "unknown field"}}}
+	default:
+		return &Error{Fields: []types.FieldError{{Message: "malformed JSON: " + err.Error()}}}
+	}
+}
@@ -0,0 +1,129 @@
+package validation
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"timer-microservice/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimerRequest(t *testing.T) {
+	validator := New(DefaultRules())
+
+	tests := []struct {
+		name   string
+		req    types.TimerRequest
+		fields []types.FieldError
+	}{
+		{"valid countdown", types.TimerRequest{SessionID: "paris-1", MaxTime: 3600}, nil},
+		{"count-up without soft cap", types.TimerRequest{SessionID: "paris-1", Mode: types.ModeCountUp}, nil},
+		{"missing session", types.TimerRequest{MaxTime: 60}, []types.FieldError{
+			{Field: "sessionId", Message: "is required"},
+		}},
+		{"session too long", types.TimerRequest{SessionID: strings.Repeat("a", 65), MaxTime: 60}, []types.FieldError{
+			{Field: "sessionId", Message: "must be at most 64 characters"},
+		}},
+		{"session with glob", types.TimerRequest{SessionID: "paris-*", MaxTime: 60}, []types.FieldError{
+			{Field: "sessionId", Message: "must match ^[A-Za-z0-9][A-Za-z0-9_.-]*$"},
+		}},
+		{"zero countdown", types.TimerRequest{SessionID: "paris-1"}, []types.FieldError{
+			{Field: "maxTime", Message: "must be at least 1 second"},
+		}},
+		{"absurd countdown", types.TimerRequest{SessionID: "paris-1", MaxTime: 1 << 62}, []types.FieldError{
+			{Field: "maxTime", Message: "must be at most 86400 seconds"},
+		}},
+		{"negative soft cap", types.TimerRequest{SessionID: "paris-1", MaxTime: -5, Mode: types.ModeCountUp}, []types.FieldError{
+			{Field: "maxTime", Message: "must be at least 1 second"},
+		}},
+		{"every field", types.TimerRequest{MaxTime: -1, Mode: "sideways"}, []types.FieldError{
+			{Field: "sessionId", Message: "is required"},
+			{Field: "mode", Message: `must be "countdown" or "countup"`},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validator.TimerRequest(tt.req)
+			if tt.fields == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, tt.fields, FieldErrors(err))
+		})
+	}
+}
+
+func TestConfiguredRules(t *testing.T) {
+	validator := New(Rules{
+		MinDuration:        time.Minute,
+		MaxDuration:        2 * time.Hour,
+		SessionIDMaxLength: 8,
+		SessionIDPattern:   regexp.MustCompile(`^room-[0-9]+$`),
+	})
+
+	assert.NoError(t, validator.TimerRequest(types.TimerRequest{SessionID: "room-1", MaxTime: 60}))
+	assert.Error(t, validator.TimerRequest(types.TimerRequest{SessionID: "paris-1", MaxTime: 60}))
+	assert.Error(t, validator.TimerRequest(types.TimerRequest{SessionID: "room-1234", MaxTime: 60}))
+
+	assert.NoError(t, validator.MaxTime(7200))
+	assert.Equal(t, []types.FieldError{{Field: "maxTime", Message: "must be at least 60 seconds"}}, FieldErrors(validator.MaxTime(59)))
+	assert.Equal(t, []types.FieldError{{Field: "maxTime", Message: "must be at most 7200 seconds"}}, FieldErrors(validator.MaxTime(7201)))
+	// Zero removes the soft cap of a stopwatch.
+	assert.NoError(t, validator.MaxTime(0))
+}
+
+func TestAdjustment(t *testing.T) {
+	validator := New(DefaultRules())
+
+	assert.NoError(t, validator.Adjustment(types.TimerAdjustRequest{TimerID: 1, Delta: -120, Reason: "hint"}))
+	assert.NoError(t, validator.Adjustment(types.TimerAdjustRequest{TimerID: 1, Delta: 86400}))
+	assert.Equal(t, []types.FieldError{
+		{Field: "delta", Message: "must be between -86400 and 86400 seconds"},
+		{Field: "reason", Message: "must be at most 255 characters"},
+	}, FieldErrors(validator.Adjustment(types.TimerAdjustRequest{TimerID: 1, Delta: -1 << 62, Reason: strings.Repeat("é", 256)})))
+}
+
+func TestHintRequest(t *testing.T) {
+	validator := New(DefaultRules())
+	penalty := func(seconds int64) *int64 { return &seconds }
+
+	assert.NoError(t, validator.HintRequest(types.HintRequest{Text: "Look under the rug"}))
+	assert.NoError(t, validator.HintRequest(types.HintRequest{Text: strings.Repeat("é", 1000), Penalty: penalty(0)}))
+
+	tests := []struct {
+		req    types.HintRequest
+		fields []types.FieldError
+	}{
+		{types.HintRequest{}, []types.FieldError{{Field: "text", Message: "is required"}}},
+		{types.HintRequest{Text: strings.Repeat("x", 1001)}, []types.FieldError{{Field: "text", Message: "must be at most 1000 characters"}}},
+		{types.HintRequest{Text: "Free", Penalty: penalty(-30)}, []types.FieldError{{Field: "penalty", Message: "must be at least 0 seconds"}}},
+		{types.HintRequest{Text: "Costly", Penalty: penalty(86401)}, []types.FieldError{{Field: "penalty", Message: "must be at most 86400 seconds"}}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.fields, FieldErrors(validator.HintRequest(tt.req)), tt.req.Text)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	var req types.TimerRequest
+	assert.NoError(t, Decode(strings.NewReader(`{"sessionId":"paris-1","maxTime":60}`), &req))
+	assert.Equal(t, types.TimerRequest{SessionID: "paris-1", MaxTime: 60}, req)
+
+	tests := []struct {
+		body  string
+		field types.FieldError
+	}{
+		{`{"sessionId":"paris-1","maxTime":60,"duration":60}`, types.FieldError{Field: "duration", Message: "unknown field"}},
+		{`{"sessionId":"paris-1","maxTime":"60"}`, types.FieldError{Field: "maxTime", Message: "must be of type int64"}},
+		{`{"sessionId":`, types.FieldError{Message: "malformed JSON: unexpected EOF"}},
+		{`{} {}`, types.FieldError{Message: "body must hold a single JSON value"}},
+	}
+	for _, tt := range tests {
+		err := Unmarshal([]byte(tt.body), &types.TimerRequest{})
+		assert.Equal(t, []types.FieldError{tt.field}, FieldErrors(err), tt.body)
+	}
+}
@@ -0,0 +1,187 @@
+package websocket
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// DefaultEventsChannel is the Redis channel instances relay broadcasts on.
+const DefaultEventsChannel = "timer-events"
+
+// Broadcasts wait in a queue of publishQueueSize envelopes for a single
+// goroutine to publish them, so a slow Redis never holds up the tick loop.
+// Each publish may take up to publishTimeout; broadcasts arriving while the
+// queue is full are dropped.
+const (
+	publishQueueSize = 1024
+	publishTimeout   = time.Second
+)
+
+// Audience says which clients a broadcast is for.
+type Audience string
+
+const (
+	// AudienceSession is the clients of a session and the game masters
+	// whose token grants it.
+	AudienceSession Audience = "session"
+	// AudienceGameMasters is the game masters whose token grants a
+	// session.
+	AudienceGameMasters Audience = "gamemasters"
+)
+
+// Envelope carries a broadcast from the instance that made it to the
+// others. Message is the encoded WebSocket message.
+type Envelope struct {
+	Origin    string          `json:"origin"`
+	Audience  Audience        `json:"audience"`
+	SessionID string          `json:"sessionId,omitempty"`
+	Message   json.RawMessage `json:"message"`
+}
+
+// Broker relays broadcasts between the instances of the service, so that a
+// client sees the events caused by commands another instance processed.
+type Broker interface {
+	Publish(ctx context.Context, envelope Envelope) error
+	// Subscribe calls deliver with every envelope published by any
+	// instance until ctx is done.
+	Subscribe(ctx context.Context, deliver func(Envelope)) error
+}
+
+// RedisBroker relays broadcasts over a Redis pub/sub channel.
+type RedisBroker struct {
+	client  *redis.Client
+	channel string
+	logger  *zap.SugaredLogger
+}
+
+func NewRedisBroker(client *redis.Client, channel string, logger *zap.SugaredLogger) *RedisBroker {
+	if channel == "" {
+		channel = DefaultEventsChannel
+	}
+	return &RedisBroker{client: client, channel: channel, logger: logger}
+}
+
+func (b *RedisBroker) Publish(ctx context.Context, envelope Envelope) error {
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, b.channel, data).Err()
+}
+
+// Subscribe listens on the channel until ctx is done. The client
+// resubscribes by itself after a lost connection; envelopes published in
+// the meantime are missed, and clients catch up with the next update.
+func (b *RedisBroker) Subscribe(ctx context.Context, deliver func(Envelope)) error {
+	pubsub := b.client.Subscribe(ctx, b.channel)
+	defer pubsub.Close()
+
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return err
+	}
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case message, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			var envelope Envelope
+			if err := json.Unmarshal([]byte(message.Payload), &envelope); err != nil {
+				b.logger.Warnw("Ignored malformed broadcast", "error", err, "channel", b.channel)
+				continue
+			}
+			deliver(envelope)
+		}
+	}
+}
+
+// SetBroker relays the handler's broadcasts to the other instances, and
+// starts the goroutine publishing them. Call RelayBroadcasts to receive
+// theirs.
+func (h *Handler) SetBroker(broker Broker) {
+	h.broker = broker
+	h.publications = make(chan Envelope, publishQueueSize)
+	go h.publish(broker, h.publications)
+}
+
+// publish hands the queued broadcasts to broker one at a time, for the
+// life of the process.
+func (h *Handler) publish(broker Broker, queue <-chan Envelope) {
+	for envelope := range queue {
+		ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+		err := broker.Publish(ctx, envelope)
+		cancel()
+		if err != nil {
+			h.logger.Errorw("Failed to relay broadcast", "error", err, "audience", envelope.Audience, "sessionID", envelope.SessionID)
+		}
+	}
+}
+
+// RelayBroadcasts delivers the broadcasts of the other instances to this
+// instance's clients until ctx is done.
+func (h *Handler) RelayBroadcasts(ctx context.Context) error {
+	if h.broker == nil {
+		return nil
+	}
+	return h.broker.Subscribe(ctx, func(envelope Envelope) {
+		if envelope.Origin == h.instanceID {
+			return
+		}
+		h.deliver(envelope.Audience, envelope.SessionID, envelope.Message)
+	})
+}
+
+// broadcast sends message to audience on every instance: to the local
+// clients directly and to the others through the broker. It never waits on
+// the broker; see publishQueueSize.
+func (h *Handler) broadcast(audience Audience, sessionID string, message interface{}) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		h.logger.Errorw("Failed to marshal broadcast", "error", err, "audience", audience)
+		return
+	}
+	h.deliver(audience, sessionID, data)
+
+	if h.broker == nil {
+		return
+	}
+	select {
+	case h.publications <- Envelope{Origin: h.instanceID, Audience: audience, SessionID: sessionID, Message: data}:
+	default:
+		atomic.AddUint64(&h.stats.droppedBroadcasts, 1)
+		h.logger.Warnw("Dropped broadcast for other instances, the broker is not keeping up", "audience", audience, "sessionID", sessionID)
+	}
+}
+
+// deliver queues an encoded message for the local clients of audience.
+func (h *Handler) deliver(audience Audience, sessionID string, data []byte) {
+	var clients []*client
+	switch audience {
+	case AudienceSession:
+		clients = h.connections.recipients(sessionID)
+	case AudienceGameMasters:
+		clients = h.connections.gameMasters(sessionID)
+	}
+	for _, c := range clients {
+		h.enqueue(c, data)
+	}
+}
+
+// newInstanceID names this instance in the envelopes it publishes, so it
+// can skip its own broadcasts when they come back.
+func newInstanceID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,125 @@
+package websocket
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"timer-microservice/internal/types"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// relayThrough connects handler to the events channel of mr and waits
+// until it is subscribed.
+func relayThrough(t *testing.T, mr *miniredis.Miniredis, handler *Handler) {
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	subscribers := mr.PubSubNumSub(DefaultEventsChannel)[DefaultEventsChannel]
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	handler.SetBroker(NewRedisBroker(client, "", zap.NewNop().Sugar()))
+	go handler.RelayBroadcasts(ctx)
+
+	assert.Eventually(t, func() bool {
+		return mr.PubSubNumSub(DefaultEventsChannel)[DefaultEventsChannel] == subscribers+1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func readMessage(t *testing.T, ws *websocket.Conn) types.WebSocketMessage {
+	ws.SetReadDeadline(time.Now().Add(time.Second))
+	defer ws.SetReadDeadline(time.Time{})
+
+	var message types.WebSocketMessage
+	assert.NoError(t, ws.ReadJSON(&message))
+	return message
+}
+
+func TestBroadcastsReachOtherInstances(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	serverA, instanceA, _ := setupWebSocketServer(t)
+	defer serverA.Close()
+	serverB, instanceB, _ := setupWebSocketServer(t)
+	defer serverB.Close()
+	relayThrough(t, mr, instanceA)
+	relayThrough(t, mr, instanceB)
+
+	gameMaster := dialWebSocket(t, serverA, "/ws/gamemaster/room-a")
+	defer gameMaster.Close()
+	customer := dialWebSocket(t, serverB, "/ws/customer/room-a")
+	defer customer.Close()
+
+	// The game master on A is told the display on B came online.
+	if presence := readPresence(t, gameMaster); assert.NotNil(t, presence) {
+		assert.Equal(t, types.PresenceOnline, presence.Status)
+	}
+
+	// An update made on A reaches the customer on B, and the game master
+	// on A exactly once.
+	instanceA.BroadcastTimerUpdate(&types.Timer{ID: 7, SessionID: "room-a", CurrentTime: 42})
+
+	message := readMessage(t, customer)
+	assert.Equal(t, types.TypeTimerUpdate, message.Type)
+	assert.Contains(t, string(message.Payload), `"CurrentTime":42`)
+
+	message = readMessage(t, gameMaster)
+	assert.Equal(t, types.TypeTimerUpdate, message.Type)
+
+	// So do the ticks of the instance running the tick loop.
+	instanceB.BroadcastTimerUpdate(&types.Timer{ID: 7, SessionID: "room-a", CurrentTime: 41})
+
+	message = readMessage(t, gameMaster)
+	assert.Contains(t, string(message.Payload), `"CurrentTime":41`)
+	message = readMessage(t, customer)
+	assert.Contains(t, string(message.Payload), `"CurrentTime":41`)
+
+	// Nothing else was queued for either client.
+	gameMaster.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	_, _, err := gameMaster.ReadMessage()
+	assert.Error(t, err)
+}
+
+// blockingBroker never completes a publish before its deadline.
+type blockingBroker struct{}
+
+func (blockingBroker) Publish(ctx context.Context, envelope Envelope) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (blockingBroker) Subscribe(ctx context.Context, deliver func(Envelope)) error {
+	<-ctx.Done()
+	return nil
+}
+
+func TestBlockedBrokerDoesNotStallBroadcasts(t *testing.T) {
+	server, handler, _ := setupWebSocketServer(t, Options{SlowClientPolicy: PolicyDrop})
+	defer server.Close()
+	handler.SetBroker(blockingBroker{})
+
+	customer := dialWebSocket(t, server, "/ws/customer/room-a")
+	defer customer.Close()
+
+	// More broadcasts than the queue holds, each of which would have
+	// waited publishTimeout for the broker.
+	const extra = 10
+	start := time.Now()
+	for i := 0; i < publishQueueSize+extra; i++ {
+		handler.BroadcastTimerUpdate(&types.Timer{ID: 1, SessionID: "room-a"})
+	}
+	assert.Less(t, time.Since(start), publishTimeout)
+
+	// The publisher holds one envelope; the rest of the overflow is dropped.
+	assert.GreaterOrEqual(t, handler.Stats().DroppedBroadcasts, uint64(extra-1))
+	assert.LessOrEqual(t, handler.Stats().DroppedBroadcasts, uint64(extra))
+
+	// Local clients are not affected.
+	assert.Equal(t, types.TypeTimerUpdate, readMessage(t, customer).Type)
+}
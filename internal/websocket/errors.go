@@ -0,0 +1,53 @@
+package websocket
+
+import (
+	"errors"
+
+	"timer-microservice/internal/types"
+
+	"gorm.io/gorm"
+)
+
+// commandError is a command failure caused by the client, reported with
+// its own code.
+type commandError struct {
+	code types.ErrorCode
+	err  error
+}
+
+func (e *commandError) Error() string {
+	return e.err.Error()
+}
+
+func (e *commandError) Unwrap() error {
+	return e.err
+}
+
+func (e *commandError) ErrorCode() types.ErrorCode {
+	return e.code
+}
+
+func invalidPayload(err error) error {
+	return &commandError{code: types.ErrCodeInvalidPayload, err: err}
+}
+
+// codedError is implemented by errors that know their protocol error code,
+// such as the service's Error and InvalidStateError.
+type codedError interface {
+	error
+	ErrorCode() types.ErrorCode
+}
+
+// describeError returns the code and message reported to the client for
+// err. Unexpected errors are not echoed back.
+func describeError(err error) (types.ErrorCode, string) {
+	var coded codedError
+	switch {
+	case errors.As(err, &coded):
+		return coded.ErrorCode(), coded.Error()
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return types.ErrCodeNotFound, "timer not found"
+	default:
+		return types.ErrCodeInternal, "internal error"
+	}
+}
@@ -2,11 +2,18 @@ package websocket
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
 	"strconv"
-	"sync"
+	"time"
 
+	"timer-microservice/internal/auth"
+	"timer-microservice/internal/clock"
+	"timer-microservice/internal/problem"
 	"timer-microservice/internal/types"
+	"timer-microservice/internal/validation"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/gorilla/websocket"
@@ -16,36 +23,66 @@ import (
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
+	// Preferred first; see subprotocols.
+	Subprotocols: []string{"timer.v2", "timer.v1"},
 	CheckOrigin: func(r *http.Request) bool {
 		return true // Allow all origins
 	},
 }
 
 type TimerServiceInterface interface {
-	CreateTimer(sessionID string, maxTime int64) (*types.Timer, error)
-	PauseTimer(id uint) (*types.Timer, error)
-	ResumeTimer(id uint) (*types.Timer, error)
-	StopTimer(id uint) error
-	ModifyTimer(id uint, newMaxTime int64) (*types.Timer, error)
+	CreateTimer(req types.TimerRequest) (*types.Timer, error)
+	CreateTimerIdempotent(key string, req types.TimerRequest) (*types.Timer, bool, error)
+	PauseTimer(id, ifMatch uint) (*types.Timer, error)
+	ResumeTimer(id, ifMatch uint) (*types.Timer, error)
+	StopTimer(id, ifMatch uint) (*types.Timer, error)
+	ModifyTimer(id, ifMatch uint, newMaxTime int64) (*types.Timer, error)
+	AdjustTimer(id, ifMatch uint, delta int64, reason string) (*types.Timer, error)
+	GetTimer(id uint) (*types.Timer, error)
+	GetLiveTimers(sessionID string) ([]types.Timer, error)
+}
+
+type HintServiceInterface interface {
+	SendHint(sessionID string, req types.HintRequest) (*types.Hint, error)
 }
 
 type HandlerInterface interface {
 	BroadcastTimerUpdate(timer *types.Timer)
+	BroadcastTimerExpired(timer *types.Timer)
+	BroadcastTimerSoftCapReached(timer *types.Timer)
+	BroadcastTimerAdjusted(timer *types.Timer, adjustment types.TimerAdjustment)
+	SendHint(hint *types.Hint)
 	SetService(service TimerServiceInterface)
 }
 
 type Handler struct {
 	service     TimerServiceInterface
+	hints       HintServiceInterface
+	verifier    auth.Verifier
+	validator   *validation.Validator
 	logger      *zap.SugaredLogger
-	connections map[string]*websocket.Conn
-	mutex       sync.RWMutex
+	clock       clock.Clock
+	options     Options
+	connections *registry
+	stats       stats
+
+	// broker relays broadcasts between instances, published from the
+	// publications queue; instanceID tells this instance's own broadcasts
+	// apart. Without a broker broadcasts stay local.
+	broker       Broker
+	publications chan Envelope
+	instanceID   string
 }
 
-func NewHandler(service TimerServiceInterface, logger *zap.SugaredLogger) *Handler {
+func NewHandler(service TimerServiceInterface, logger *zap.SugaredLogger, clk clock.Clock, options Options) *Handler {
 	return &Handler{
 		service:     service,
 		logger:      logger,
-		connections: make(map[string]*websocket.Conn),
+		validator:   validation.New(validation.DefaultRules()),
+		clock:       clk,
+		options:     options.withDefaults(),
+		connections: newRegistry(),
+		instanceID:  newInstanceID(),
 	}
 }
 
@@ -53,6 +90,21 @@ func (h *Handler) SetService(service TimerServiceInterface) {
 	h.service = service
 }
 
+func (h *Handler) SetHintService(hints HintServiceInterface) {
+	h.hints = hints
+}
+
+// SetVerifier makes every connection present a valid token. Without a
+// verifier connections are not authenticated.
+func (h *Handler) SetVerifier(verifier auth.Verifier) {
+	h.verifier = verifier
+}
+
+// SetValidator replaces the default limits of create and modify commands.
+func (h *Handler) SetValidator(validator *validation.Validator) {
+	h.validator = validator
+}
+
 func (h *Handler) HandleCustomerWebSocket(w http.ResponseWriter, r *http.Request) {
 	sessionID := chi.URLParam(r, "sessionID")
 	h.handleWebSocket(w, r, sessionID, false)
@@ -64,164 +116,468 @@ func (h *Handler) HandleGameMasterWebSocket(w http.ResponseWriter, r *http.Reque
 }
 
 func (h *Handler) handleWebSocket(w http.ResponseWriter, r *http.Request, sessionID string, isGameMaster bool) {
+	claims, status, err := h.authorize(r, sessionID, isGameMaster)
+	if err != nil {
+		code := types.ErrCodeForbidden
+		if status == http.StatusUnauthorized {
+			code = types.ErrCodeUnauthorized
+		}
+		problem.Write(w, r, status, code, err.Error())
+		return
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		h.logger.Errorw("Failed to upgrade connection", "error", err)
 		return
 	}
 
-	h.mutex.Lock()
-	h.connections[sessionID] = conn
-	h.mutex.Unlock()
+	role := RoleCustomer
+	if isGameMaster {
+		role = RoleGameMaster
+	}
+	c := newClient(conn, sessionID, role, negotiatedProtocol(conn.Subprotocol()), h.options.SendBufferSize)
+	c.claims = claims
+	h.connections.add(c)
+	go h.writePump(c)
+
+	h.logger.Infow("New WebSocket connection established", "sessionID", sessionID, "role", role, "protocol", c.protocol)
+	h.sendSnapshot(c)
+	h.broadcastPresence(c, types.PresenceOnline, "")
 
-	h.logger.Infow("New WebSocket connection established", "sessionID", sessionID, "isGameMaster", isGameMaster)
+	reason := h.readLoop(c)
+	h.closeConnection(c, reason)
+}
 
-	defer h.closeConnection(sessionID, conn)
+// authorize checks the upgrade request's token, see auth.WebSocketToken.
+// Game master routes need a game master token, and the token must grant
+// access to sessionID. When the connection may not proceed it returns the
+// status to reject it with and why.
+func (h *Handler) authorize(r *http.Request, sessionID string, isGameMaster bool) (*auth.Claims, int, error) {
+	if h.verifier == nil {
+		return nil, http.StatusOK, nil
+	}
+
+	token := auth.WebSocketToken(r)
+	if token == "" {
+		h.logger.Warnw("Rejected WebSocket connection without token", "sessionID", sessionID)
+		return nil, http.StatusUnauthorized, errors.New("missing token")
+	}
+	claims, err := h.verifier.Verify(token)
+	if err != nil {
+		h.logger.Warnw("Rejected WebSocket connection", "error", err, "sessionID", sessionID)
+		return nil, http.StatusUnauthorized, err
+	}
+	if isGameMaster && claims.Role != auth.RoleGameMaster {
+		h.logger.Warnw("Rejected WebSocket connection without game master role", "sessionID", sessionID, "subject", claims.Subject)
+		return nil, http.StatusForbidden, errors.New("game master token required")
+	}
+	if !claims.AllowsSession(sessionID) {
+		h.logger.Warnw("Rejected WebSocket connection for session outside token scope", "sessionID", sessionID, "subject", claims.Subject)
+		return nil, http.StatusForbidden, fmt.Errorf("token does not grant access to session %s", sessionID)
+	}
+	return claims, http.StatusOK, nil
+}
+
+// readLoop handles c's messages until the connection fails and returns why
+// it did. Every pong pushes the read deadline back, so a client that stops
+// answering pings times out after PongWait.
+func (h *Handler) readLoop(c *client) string {
+	c.conn.SetReadLimit(h.options.ReadLimit)
+	c.conn.SetReadDeadline(time.Now().Add(h.options.PongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(h.options.PongWait))
+	})
 
 	for {
-		var wsMessage types.WebSocketMessage
-		err := conn.ReadJSON(&wsMessage)
+		_, data, err := c.conn.ReadMessage()
 		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				h.logger.Warnw("WebSocket client stopped answering pings", "sessionID", c.sessionID, "role", c.role)
+				return "timeout"
+			}
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				h.logger.Errorw("WebSocket read error", "error", err, "sessionID", sessionID)
+				h.logger.Errorw("WebSocket read error", "error", err, "sessionID", c.sessionID)
 			}
-			break
+			return "closed"
+		}
+
+		// A malformed message is answered, not treated as a broken
+		// connection.
+		var wsMessage types.WebSocketMessage
+		if err := json.Unmarshal(data, &wsMessage); err != nil {
+			h.logger.Warnw("Failed to unmarshal WebSocket message", "error", err, "sessionID", c.sessionID)
+			h.replyError(c, wsMessage, &commandError{code: types.ErrCodeInvalidMessage, err: err})
+			continue
+		}
+
+		h.handleMessage(c, wsMessage)
+	}
+}
+
+func (h *Handler) closeConnection(c *client, reason string) {
+	h.connections.remove(c)
+	c.close()
+	h.logger.Infow("WebSocket connection closed", "sessionID", c.sessionID, "role", c.role, "reason", reason)
+	h.broadcastPresence(c, types.PresenceOffline, reason)
+}
+
+// sendSnapshot sends c the created, running and paused timers it watches:
+// those of its session for a customer, those of every session its token
+// grants for a game master.
+func (h *Handler) sendSnapshot(c *client) {
+	sessionID := c.sessionID
+	if c.role == RoleGameMaster {
+		sessionID = ""
+	}
+	timers, err := h.service.GetLiveTimers(sessionID)
+	if err != nil {
+		h.logger.Errorw("Failed to load timers for snapshot", "error", err, "sessionID", c.sessionID, "role", c.role)
+		return
+	}
+
+	snapshot := types.SnapshotPayload{
+		Timers:     []types.Timer{},
+		ServerTime: h.clock.Now(),
+		Protocol:   c.protocol,
+	}
+	for _, timer := range timers {
+		if c.watches(timer.SessionID) {
+			snapshot.Timers = append(snapshot.Timers, timer)
 		}
+	}
+
+	payload, err := json.Marshal(snapshot)
+	if err != nil {
+		h.logger.Errorw("Failed to marshal snapshot payload", "error", err)
+		return
+	}
 
-		h.handleMessage(wsMessage, sessionID, isGameMaster)
+	message := types.WebSocketMessage{
+		Type:    types.TypeSnapshot,
+		Payload: json.RawMessage(payload),
 	}
+
+	h.send([]*client{c}, message)
 }
 
-func (h *Handler) closeConnection(sessionID string, conn *websocket.Conn) {
-	h.mutex.Lock()
-	delete(h.connections, sessionID)
-	h.mutex.Unlock()
-	conn.Close()
-	h.logger.Infow("WebSocket connection closed", "sessionID", sessionID)
+// broadcastPresence tells the game masters watching c's session that a
+// customer display came online or went offline. Game master connections are
+// not reported.
+func (h *Handler) broadcastPresence(c *client, status types.PresenceStatus, reason string) {
+	if c.role != RoleCustomer {
+		return
+	}
+
+	payload, err := json.Marshal(types.PresencePayload{
+		SessionID: c.sessionID,
+		Status:    status,
+		Reason:    reason,
+		Clients:   h.connections.customers(c.sessionID),
+		At:        h.clock.Now(),
+	})
+	if err != nil {
+		h.logger.Errorw("Failed to marshal presence payload", "error", err)
+		return
+	}
+
+	message := types.WebSocketMessage{
+		Type:    types.TypePresence,
+		Payload: json.RawMessage(payload),
+	}
+
+	h.broadcast(AudienceGameMasters, c.sessionID, message)
 }
 
-func (h *Handler) handleMessage(message types.WebSocketMessage, sessionID string, isGameMaster bool) {
+// handleMessage runs a client's command and replies to the sender with ACK
+// or ERROR, echoing the message's request ID.
+func (h *Handler) handleMessage(c *client, message types.WebSocketMessage) {
+	if err := h.dispatch(c, message); err != nil {
+		h.logger.Errorw("Failed to handle WebSocket message", "error", err, "type", message.Type, "sessionID", c.sessionID)
+		h.replyError(c, message, err)
+		return
+	}
+
+	h.reply(c, types.TypeAck, message.RequestID, types.AckPayload{Command: message.Type})
+}
+
+// dispatch runs message's command. Only game masters may send commands.
+// From protocol version 2 on, the payload must match the command's schema
+// before it is decoded.
+func (h *Handler) dispatch(c *client, message types.WebSocketMessage) error {
+	schema, isCommand := commandSchemas[message.Type]
+	if isCommand && c.role != RoleGameMaster {
+		return &commandError{
+			code: types.ErrCodeForbidden,
+			err:  fmt.Errorf("customer connections cannot send %s", message.Type),
+		}
+	}
+
+	if isCommand && c.protocol >= ProtocolV2 {
+		if err := schema.validate(message.Payload); err != nil {
+			return invalidPayload(err)
+		}
+	}
+
 	switch message.Type {
 	case types.TypeTimerCreate:
-		h.handleTimerCreate(message.Payload, isGameMaster)
+		return h.handleTimerCreate(message.Payload, message.RequestID, c)
 	case types.TypeTimerPause:
-		h.handleTimerPause(message.Payload, isGameMaster)
+		return h.handleTimerPause(message.Payload, c)
 	case types.TypeTimerResume:
-		h.handleTimerResume(message.Payload, isGameMaster)
+		return h.handleTimerResume(message.Payload, c)
 	case types.TypeTimerStop:
-		h.handleTimerStop(message.Payload, isGameMaster)
+		return h.handleTimerStop(message.Payload, c)
 	case types.TypeTimerModify:
-		h.handleTimerModify(message.Payload, isGameMaster)
+		return h.handleTimerModify(message.Payload, c)
+	case types.TypeTimerAdjust:
+		return h.handleTimerAdjust(message.Payload, c)
+	case types.TypeHintSent:
+		return h.handleHintSent(message.Payload, c)
 	default:
-		h.logger.Warnw("Unknown message type received", "type", message.Type, "sessionID", sessionID)
+		return &commandError{
+			code: types.ErrCodeUnknownType,
+			err:  fmt.Errorf("unknown message type %q", message.Type),
+		}
 	}
 }
 
-func (h *Handler) handleTimerCreate(payload json.RawMessage, isGameMaster bool) {
+// handleTimerCreate creates a timer. A command with a requestId creates it
+// at most once: a retry is answered with the timer it created, sent to the
+// retrying client alone.
+func (h *Handler) handleTimerCreate(payload json.RawMessage, requestID string, c *client) error {
 	var createPayload types.TimerRequest
-	if err := json.Unmarshal(payload, &createPayload); err != nil {
-		h.logger.Errorw("Failed to unmarshal timer create payload", "error", err)
-		return
+	if err := validation.Unmarshal(payload, &createPayload); err != nil {
+		return invalidPayload(err)
+	}
+	if err := h.validator.TimerRequest(createPayload); err != nil {
+		return err
+	}
+	if c.claims != nil && !c.claims.AllowsSession(createPayload.SessionID) {
+		return &commandError{
+			code: types.ErrCodeForbidden,
+			err:  fmt.Errorf("token does not grant access to session %s", createPayload.SessionID),
+		}
+	}
+	if requestID == "" {
+		timer, err := h.service.CreateTimer(createPayload)
+		if err != nil {
+			return err
+		}
+		h.broadcastTimerUpdate(timer)
+		return nil
+	}
+
+	if err := h.validator.IdempotencyKey("requestId", requestID); err != nil {
+		return err
 	}
-	timer, err := h.service.CreateTimer(createPayload.SessionID, createPayload.MaxTime)
+	timer, replayed, err := h.service.CreateTimerIdempotent(requestID, createPayload)
 	if err != nil {
-		h.logger.Errorw("Failed to create timer", "error", err)
-		return
+		return err
+	}
+	if replayed {
+		h.reply(c, types.TypeTimerUpdate, "", timer)
+		return nil
 	}
-	h.broadcastTimerUpdate(timer, isGameMaster)
+	h.broadcastTimerUpdate(timer)
+	return nil
 }
 
-// Implement similar handler functions for pause, resume, stop, and modify
+func (h *Handler) handleTimerPause(payload json.RawMessage, c *client) error {
+	id, err := parseTimerID(payload, c.protocol)
+	if err != nil {
+		return err
+	}
+	if err := h.authorizeTimer(c, id); err != nil {
+		return err
+	}
 
-func (h *Handler) handleTimerPause(payload json.RawMessage, isGameMaster bool) {
-	var pausePayload types.TimerRequest
-	if err := json.Unmarshal(payload, &pausePayload); err != nil {
-		h.logger.Errorw("Failed to unmarshal timer pause payload", "error", err)
-		return
+	timer, err := h.service.PauseTimer(id, 0)
+	if err != nil {
+		return err
 	}
 
-	id, err := strconv.ParseUint(pausePayload.SessionID, 10, 64)
+	h.broadcastTimerUpdate(timer)
+	return nil
+}
+
+func (h *Handler) handleTimerResume(payload json.RawMessage, c *client) error {
+	id, err := parseTimerID(payload, c.protocol)
 	if err != nil {
-		h.logger.Errorw("Invalid timer ID", "error", err)
-		return
+		return err
+	}
+	if err := h.authorizeTimer(c, id); err != nil {
+		return err
 	}
 
-	timer, err := h.service.PauseTimer(uint(id))
+	timer, err := h.service.ResumeTimer(id, 0)
 	if err != nil {
-		h.logger.Errorw("Failed to pause timer", "error", err)
-		return
+		return err
 	}
 
-	h.broadcastTimerUpdate(timer, isGameMaster)
+	h.broadcastTimerUpdate(timer)
+	return nil
 }
 
-func (h *Handler) handleTimerResume(payload json.RawMessage, isGameMaster bool) {
-	var resumePayload types.TimerRequest
-	if err := json.Unmarshal(payload, &resumePayload); err != nil {
-		h.logger.Errorw("Failed to unmarshal timer resume payload", "error", err)
-		return
+func (h *Handler) handleTimerStop(payload json.RawMessage, c *client) error {
+	id, err := parseTimerID(payload, c.protocol)
+	if err != nil {
+		return err
+	}
+	if err := h.authorizeTimer(c, id); err != nil {
+		return err
 	}
 
-	id, err := strconv.ParseUint(resumePayload.SessionID, 10, 64)
+	timer, err := h.service.StopTimer(id, 0)
 	if err != nil {
-		h.logger.Errorw("Invalid timer ID", "error", err)
-		return
+		return err
 	}
 
-	timer, err := h.service.ResumeTimer(uint(id))
+	h.broadcastTimerStop(timer)
+	return nil
+}
+
+func (h *Handler) handleTimerModify(payload json.RawMessage, c *client) error {
+	var modifyPayload types.TimerModifyPayload
+	if c.protocol >= ProtocolV2 {
+		if err := validation.Unmarshal(payload, &modifyPayload); err != nil {
+			return invalidPayload(err)
+		}
+	} else {
+		var legacy types.TimerRequest
+		if err := validation.Unmarshal(payload, &legacy); err != nil {
+			return invalidPayload(err)
+		}
+		id, err := parseLegacyTimerID(legacy.SessionID)
+		if err != nil {
+			return err
+		}
+		modifyPayload = types.TimerModifyPayload{TimerID: id, MaxTime: legacy.MaxTime}
+	}
+	if err := h.validator.MaxTime(modifyPayload.MaxTime); err != nil {
+		return err
+	}
+	if err := h.authorizeTimer(c, modifyPayload.TimerID); err != nil {
+		return err
+	}
+
+	timer, err := h.service.ModifyTimer(modifyPayload.TimerID, 0, modifyPayload.MaxTime)
 	if err != nil {
-		h.logger.Errorw("Failed to resume timer", "error", err)
-		return
+		return err
 	}
 
-	h.broadcastTimerUpdate(timer, isGameMaster)
+	h.broadcastTimerUpdate(timer)
+	return nil
 }
 
-func (h *Handler) handleTimerStop(payload json.RawMessage, isGameMaster bool) {
-	var stopPayload types.TimerRequest
-	if err := json.Unmarshal(payload, &stopPayload); err != nil {
-		h.logger.Errorw("Failed to unmarshal timer stop payload", "error", err)
-		return
+// handleTimerAdjust applies a penalty or bonus. The service broadcasts the
+// result to every client of the session.
+func (h *Handler) handleTimerAdjust(payload json.RawMessage, c *client) error {
+	var adjustPayload types.TimerAdjustRequest
+	if err := validation.Unmarshal(payload, &adjustPayload); err != nil {
+		return invalidPayload(err)
+	}
+	if err := h.validator.Adjustment(adjustPayload); err != nil {
+		return err
+	}
+	if err := h.authorizeTimer(c, adjustPayload.TimerID); err != nil {
+		return err
 	}
 
-	id, err := strconv.ParseUint(stopPayload.SessionID, 10, 64)
-	if err != nil {
-		h.logger.Errorw("Invalid timer ID", "error", err)
-		return
+	_, err := h.service.AdjustTimer(adjustPayload.TimerID, 0, adjustPayload.Delta, adjustPayload.Reason)
+	return err
+}
+
+// handleHintSent records a hint from a game master. The hint service pushes
+// it to the session's customer screen.
+func (h *Handler) handleHintSent(payload json.RawMessage, c *client) error {
+	var hintPayload types.HintRequest
+	if err := validation.Unmarshal(payload, &hintPayload); err != nil {
+		return invalidPayload(err)
+	}
+	if err := h.validator.HintRequest(hintPayload); err != nil {
+		return err
 	}
 
-	err = h.service.StopTimer(uint(id))
-	if err != nil {
-		h.logger.Errorw("Failed to stop timer", "error", err)
-		return
+	_, err := h.hints.SendHint(c.sessionID, hintPayload)
+	return err
+}
+
+// authorizeTimer loads timer id and checks that c's token grants its
+// session.
+func (h *Handler) authorizeTimer(c *client, id uint) error {
+	if c.claims == nil || c.claims.AllowsAllSessions() {
+		return nil
 	}
 
-	h.broadcastTimerStop(uint(id), isGameMaster)
+	timer, err := h.service.GetTimer(id)
+	if err != nil {
+		return err
+	}
+	if !c.claims.AllowsSession(timer.SessionID) {
+		return &commandError{
+			code: types.ErrCodeForbidden,
+			err:  fmt.Errorf("token does not grant access to session %s", timer.SessionID),
+		}
+	}
+	return nil
 }
 
-func (h *Handler) handleTimerModify(payload json.RawMessage, isGameMaster bool) {
-	var modifyPayload types.TimerRequest
-	if err := json.Unmarshal(payload, &modifyPayload); err != nil {
-		h.logger.Errorw("Failed to unmarshal timer modify payload", "error", err)
-		return
+// parseTimerID reads the timer ID of the pause, resume and stop commands.
+// Version 1 clients send it in the sessionId field of a TimerRequest.
+func parseTimerID(payload json.RawMessage, protocol int) (uint, error) {
+	if protocol >= ProtocolV2 {
+		var request types.TimerIDPayload
+		if err := json.Unmarshal(payload, &request); err != nil {
+			return 0, invalidPayload(err)
+		}
+		return request.TimerID, nil
 	}
 
-	id, err := strconv.ParseUint(modifyPayload.SessionID, 10, 64)
+	var legacy types.TimerRequest
+	if err := json.Unmarshal(payload, &legacy); err != nil {
+		return 0, invalidPayload(err)
+	}
+	return parseLegacyTimerID(legacy.SessionID)
+}
+
+func parseLegacyTimerID(sessionID string) (uint, error) {
+	id, err := strconv.ParseUint(sessionID, 10, 64)
 	if err != nil {
-		h.logger.Errorw("Invalid timer ID", "error", err)
-		return
+		return 0, invalidPayload(fmt.Errorf("invalid timer ID: %w", err))
 	}
+	return uint(id), nil
+}
 
-	timer, err := h.service.ModifyTimer(uint(id), modifyPayload.MaxTime)
+// reply sends a message to c alone.
+func (h *Handler) reply(c *client, messageType types.MessageType, requestID string, payload interface{}) {
+	data, err := json.Marshal(payload)
 	if err != nil {
-		h.logger.Errorw("Failed to modify timer", "error", err)
+		h.logger.Errorw("Failed to marshal reply payload", "error", err, "type", messageType)
 		return
 	}
 
-	h.broadcastTimerUpdate(timer, isGameMaster)
+	message := types.WebSocketMessage{
+		Type:      messageType,
+		RequestID: requestID,
+		Payload:   json.RawMessage(data),
+	}
+
+	h.send([]*client{c}, message)
+}
+
+func (h *Handler) replyError(c *client, message types.WebSocketMessage, err error) {
+	code, text := describeError(err)
+	h.reply(c, types.TypeError, message.RequestID, types.ErrorPayload{
+		Command: message.Type,
+		Code:    code,
+		Message: text,
+		Errors:  validation.FieldErrors(err),
+	})
 }
 
-func (h *Handler) broadcastTimerUpdate(timer *types.Timer, isGameMaster bool) {
+func (h *Handler) broadcastTimerUpdate(timer *types.Timer) {
 	payload, err := json.Marshal(timer)
 	if err != nil {
 		h.logger.Errorw("Failed to marshal timer update payload", "error", err)
@@ -233,13 +589,28 @@ func (h *Handler) broadcastTimerUpdate(timer *types.Timer, isGameMaster bool) {
 		Payload: json.RawMessage(payload),
 	}
 
-	h.broadcastMessage(message, timer.SessionID, isGameMaster)
+	h.broadcastMessage(message, timer.SessionID)
 }
 
-func (h *Handler) broadcastTimerStop(timerID uint, isGameMaster bool) {
+func (h *Handler) broadcastTimerEvent(messageType types.MessageType, timer *types.Timer) {
+	payload, err := json.Marshal(timer)
+	if err != nil {
+		h.logger.Errorw("Failed to marshal timer event payload", "error", err, "type", messageType)
+		return
+	}
+
+	message := types.WebSocketMessage{
+		Type:    messageType,
+		Payload: json.RawMessage(payload),
+	}
+
+	h.broadcastMessage(message, timer.SessionID)
+}
+
+func (h *Handler) broadcastTimerStop(timer *types.Timer) {
 	payload, err := json.Marshal(struct {
 		ID uint `json:"id"`
-	}{ID: timerID})
+	}{ID: timer.ID})
 	if err != nil {
 		h.logger.Errorw("Failed to marshal timer stop payload", "error", err)
 		return
@@ -250,24 +621,75 @@ func (h *Handler) broadcastTimerStop(timerID uint, isGameMaster bool) {
 		Payload: json.RawMessage(payload),
 	}
 
-	h.broadcastMessage(message, "", isGameMaster)
+	h.broadcastMessage(message, timer.SessionID)
 }
 
-func (h *Handler) broadcastMessage(message types.WebSocketMessage, sessionID string, isGameMaster bool) {
-	h.mutex.RLock()
-	defer h.mutex.RUnlock()
+// broadcastMessage sends message to the clients watching sessionID, on
+// every instance.
+func (h *Handler) broadcastMessage(message types.WebSocketMessage, sessionID string) {
+	h.broadcast(AudienceSession, sessionID, message)
+}
 
-	for connSessionID, conn := range h.connections {
-		if isGameMaster || connSessionID == sessionID {
-			if err := conn.WriteJSON(message); err != nil {
-				h.logger.Errorw("Failed to send WebSocket message", "error", err, "sessionID", connSessionID)
-				// Consider closing the connection here if it's a persistent error
-			}
-		}
+// send encodes message once and queues it for each client. It never waits
+// on the network, so a stalled client cannot hold up the tick loop.
+func (h *Handler) send(clients []*client, message types.WebSocketMessage) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		h.logger.Errorw("Failed to marshal WebSocket message", "error", err, "type", message.Type)
+		return
+	}
+
+	for _, c := range clients {
+		h.enqueue(c, data)
 	}
 }
 
-// Add a method to broadcast updates from the timer service
+// BroadcastTimerUpdate sends a timer's current state to the clients of its
+// session.
 func (h *Handler) BroadcastTimerUpdate(timer *types.Timer) {
-	h.broadcastTimerUpdate(timer, true) // Broadcast to all, including game masters
+	h.broadcastTimerUpdate(timer)
+}
+
+// BroadcastTimerExpired tells every client that a timer has run out.
+func (h *Handler) BroadcastTimerExpired(timer *types.Timer) {
+	h.broadcastTimerEvent(types.TypeTimerExpired, timer)
+}
+
+// BroadcastTimerSoftCapReached tells every client that a stopwatch passed
+// its soft cap.
+func (h *Handler) BroadcastTimerSoftCapReached(timer *types.Timer) {
+	h.broadcastTimerEvent(types.TypeTimerSoftCap, timer)
+}
+
+// BroadcastTimerAdjusted sends the adjusted timer and the applied
+// adjustment to every client.
+func (h *Handler) BroadcastTimerAdjusted(timer *types.Timer, adjustment types.TimerAdjustment) {
+	payload, err := json.Marshal(types.TimerAdjustedPayload{Timer: timer, Adjustment: adjustment})
+	if err != nil {
+		h.logger.Errorw("Failed to marshal timer adjust payload", "error", err)
+		return
+	}
+
+	message := types.WebSocketMessage{
+		Type:    types.TypeTimerAdjust,
+		Payload: json.RawMessage(payload),
+	}
+
+	h.broadcastMessage(message, timer.SessionID)
+}
+
+// SendHint pushes a hint to the clients of its session.
+func (h *Handler) SendHint(hint *types.Hint) {
+	payload, err := json.Marshal(types.NewHintResponse(hint))
+	if err != nil {
+		h.logger.Errorw("Failed to marshal hint payload", "error", err)
+		return
+	}
+
+	message := types.WebSocketMessage{
+		Type:    types.TypeHintSent,
+		Payload: json.RawMessage(payload),
+	}
+
+	h.broadcastMessage(message, hint.SessionID)
 }
@@ -0,0 +1,75 @@
+package websocket
+
+import "time"
+
+// SlowClientPolicy decides what happens to a client whose outbound queue is
+// full.
+type SlowClientPolicy string
+
+const (
+	// PolicyDrop discards the message and keeps the client connected.
+	PolicyDrop SlowClientPolicy = "drop"
+	// PolicyDisconnect closes the client; it is expected to reconnect.
+	PolicyDisconnect SlowClientPolicy = "disconnect"
+)
+
+const (
+	defaultSendBufferSize = 256
+	defaultWriteWait      = 10 * time.Second
+	defaultPongWait       = 60 * time.Second
+	defaultReadLimit      = 64 << 10
+)
+
+// Options tunes the per-connection write pumps and heartbeats.
+type Options struct {
+	// SendBufferSize is the number of messages queued per client before the
+	// SlowClientPolicy applies.
+	SendBufferSize int
+	// WriteWait is how long a single write may take before the client is
+	// considered dead.
+	WriteWait        time.Duration
+	SlowClientPolicy SlowClientPolicy
+
+	// PingInterval is how often clients are pinged. A client that sends
+	// nothing, not even a pong, for PongWait is considered gone.
+	// PingInterval must be shorter than PongWait.
+	PingInterval time.Duration
+	PongWait     time.Duration
+	// ReadLimit is the largest message accepted from a client, in bytes.
+	ReadLimit int64
+}
+
+func DefaultOptions() Options {
+	return Options{
+		SendBufferSize:   defaultSendBufferSize,
+		WriteWait:        defaultWriteWait,
+		SlowClientPolicy: PolicyDisconnect,
+		PingInterval:     defaultPongWait * 9 / 10,
+		PongWait:         defaultPongWait,
+		ReadLimit:        defaultReadLimit,
+	}
+}
+
+// withDefaults fills in the unset fields.
+func (o Options) withDefaults() Options {
+	defaults := DefaultOptions()
+	if o.SendBufferSize <= 0 {
+		o.SendBufferSize = defaults.SendBufferSize
+	}
+	if o.WriteWait <= 0 {
+		o.WriteWait = defaults.WriteWait
+	}
+	if o.SlowClientPolicy != PolicyDrop && o.SlowClientPolicy != PolicyDisconnect {
+		o.SlowClientPolicy = defaults.SlowClientPolicy
+	}
+	if o.PongWait <= 0 {
+		o.PongWait = defaults.PongWait
+	}
+	if o.PingInterval <= 0 || o.PingInterval >= o.PongWait {
+		o.PingInterval = o.PongWait * 9 / 10
+	}
+	if o.ReadLimit <= 0 {
+		o.ReadLimit = defaults.ReadLimit
+	}
+	return o
+}
@@ -0,0 +1,61 @@
+package websocket
+
+import (
+	"timer-microservice/internal/types"
+)
+
+// Protocol versions. Version 1 is the original protocol, where pause,
+// resume, stop and modify carry the timer ID in the sessionId field of a
+// TimerRequest. Version 2 uses a dedicated payload per command and
+// validates every payload against its schema.
+const (
+	ProtocolV1 = 1
+	ProtocolV2 = 2
+)
+
+// subprotocols maps the Sec-WebSocket-Protocol values a client may offer to
+// protocol versions. Clients that offer none speak version 1.
+var subprotocols = map[string]int{
+	"timer.v2": ProtocolV2,
+	"timer.v1": ProtocolV1,
+}
+
+// negotiatedProtocol returns the protocol version for the subprotocol the
+// upgrader selected.
+func negotiatedProtocol(subprotocol string) int {
+	if version, ok := subprotocols[subprotocol]; ok {
+		return version
+	}
+	return ProtocolV1
+}
+
+var timerIDSchema = payloadSchema{
+	"timerId": {kind: kindInteger, required: true, minimum: minimum(1)},
+}
+
+// commandSchemas are the version 2 payload schemas of the client commands.
+var commandSchemas = map[types.MessageType]payloadSchema{
+	types.TypeTimerCreate: {
+		"sessionId":     {kind: kindString, required: true},
+		"maxTime":       {kind: kindInteger, required: true, minimum: minimum(0)},
+		"mode":          {kind: kindString, enum: []string{string(types.ModeCountdown), string(types.ModeCountUp)}},
+		"allowOvertime": {kind: kindBoolean},
+	},
+	types.TypeTimerPause:  timerIDSchema,
+	types.TypeTimerResume: timerIDSchema,
+	types.TypeTimerStop:   timerIDSchema,
+	types.TypeTimerModify: {
+		"timerId": {kind: kindInteger, required: true, minimum: minimum(1)},
+		"maxTime": {kind: kindInteger, required: true, minimum: minimum(0)},
+	},
+	types.TypeTimerAdjust: {
+		"timerId": {kind: kindInteger, required: true, minimum: minimum(1)},
+		"delta":   {kind: kindInteger, required: true},
+		"reason":  {kind: kindString},
+	},
+	types.TypeHintSent: {
+		"timerId": {kind: kindInteger, minimum: minimum(1)},
+		"text":    {kind: kindString, required: true},
+		"penalty": {kind: kindInteger, minimum: minimum(0)},
+	},
+}
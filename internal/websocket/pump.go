@@ -0,0 +1,88 @@
+package websocket
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Stats counts the messages and clients lost to slow connections, and the
+// broadcasts other instances missed because the broker was slow.
+type Stats struct {
+	DroppedMessages   uint64 `json:"droppedMessages"`
+	EvictedClients    uint64 `json:"evictedClients"`
+	DroppedBroadcasts uint64 `json:"droppedBroadcasts"`
+}
+
+type stats struct {
+	droppedMessages   uint64
+	evictedClients    uint64
+	droppedBroadcasts uint64
+}
+
+// Stats returns a snapshot of the drop counters.
+func (h *Handler) Stats() Stats {
+	return Stats{
+		DroppedMessages:   atomic.LoadUint64(&h.stats.droppedMessages),
+		EvictedClients:    atomic.LoadUint64(&h.stats.evictedClients),
+		DroppedBroadcasts: atomic.LoadUint64(&h.stats.droppedBroadcasts),
+	}
+}
+
+// enqueue queues data for c without blocking. A full queue means the client
+// is not keeping up, and the slow client policy decides its fate.
+func (h *Handler) enqueue(c *client, data []byte) {
+	select {
+	case c.send <- data:
+		return
+	case <-c.done:
+		return
+	default:
+	}
+
+	atomic.AddUint64(&h.stats.droppedMessages, 1)
+
+	if h.options.SlowClientPolicy == PolicyDrop {
+		h.logger.Warnw("Dropped WebSocket message for slow client", "sessionID", c.sessionID, "role", c.role)
+		return
+	}
+
+	if h.connections.remove(c) {
+		atomic.AddUint64(&h.stats.evictedClients, 1)
+		h.logger.Warnw("Disconnecting slow WebSocket client", "sessionID", c.sessionID, "role", c.role)
+	}
+	c.close()
+}
+
+// writePump is the only goroutine writing to c's connection. It also pings
+// the client so the read loop notices when pongs stop coming. It exits when
+// the client is closed or a write fails.
+//
+// Socket deadlines and pings are wall-clock matters, so they use the time
+// package rather than the handler's clock.
+func (h *Handler) writePump(c *client) {
+	ticker := time.NewTicker(h.options.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case data := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(h.options.WriteWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				h.logger.Warnw("Failed to send WebSocket message", "error", err, "sessionID", c.sessionID, "role", c.role)
+				c.close()
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(h.options.WriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				h.logger.Warnw("Failed to ping WebSocket client", "error", err, "sessionID", c.sessionID, "role", c.role)
+				c.close()
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package websocket
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"timer-microservice/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// floodClient sends large hints to sessionID until done reports true or the
+// attempts run out. The client under test never reads, so its socket buffers
+// fill and its write pump stalls.
+func floodClient(handler *Handler, sessionID string, done func() bool) {
+	hint := &types.Hint{SessionID: sessionID, Text: strings.Repeat("x", 1<<20)}
+	for i := 0; i < 200 && !done(); i++ {
+		handler.SendHint(hint)
+	}
+}
+
+func TestSlowClientIsDisconnected(t *testing.T) {
+	server, handler, _ := setupWebSocketServer(t, Options{
+		SendBufferSize:   1,
+		WriteWait:        time.Minute,
+		SlowClientPolicy: PolicyDisconnect,
+	})
+	defer server.Close()
+
+	ws := dialWebSocket(t, server, "/ws/customer/room-a")
+	defer ws.Close()
+
+	assert.Eventually(t, func() bool {
+		return handler.connections.count("room-a") == 1
+	}, time.Second, 10*time.Millisecond)
+
+	start := time.Now()
+	floodClient(handler, "room-a", func() bool {
+		return handler.Stats().EvictedClients > 0
+	})
+
+	// The broadcasts must not wait for the stalled client.
+	assert.Less(t, time.Since(start), 30*time.Second)
+	assert.Equal(t, uint64(1), handler.Stats().EvictedClients)
+	assert.Equal(t, 0, handler.connections.count("room-a"))
+}
+
+func TestSlowClientMessagesAreDropped(t *testing.T) {
+	server, handler, _ := setupWebSocketServer(t, Options{
+		SendBufferSize:   1,
+		WriteWait:        time.Minute,
+		SlowClientPolicy: PolicyDrop,
+	})
+	defer server.Close()
+
+	ws := dialWebSocket(t, server, "/ws/customer/room-a")
+	defer ws.Close()
+
+	assert.Eventually(t, func() bool {
+		return handler.connections.count("room-a") == 1
+	}, time.Second, 10*time.Millisecond)
+
+	floodClient(handler, "room-a", func() bool {
+		return handler.Stats().DroppedMessages > 0
+	})
+
+	assert.NotZero(t, handler.Stats().DroppedMessages)
+	assert.Zero(t, handler.Stats().EvictedClients)
+	assert.Equal(t, 1, handler.connections.count("room-a"))
+
+	// The client is still served once it catches up.
+	var response types.WebSocketMessage
+	assert.NoError(t, ws.ReadJSON(&response))
+	assert.Equal(t, types.TypeHintSent, response.Type)
+}
+
+func TestOptionsWithDefaults(t *testing.T) {
+	options := Options{SlowClientPolicy: "unknown"}.withDefaults()
+	assert.Equal(t, DefaultOptions(), options)
+
+	options = Options{SendBufferSize: 8, WriteWait: time.Second, SlowClientPolicy: PolicyDrop}.withDefaults()
+	assert.Equal(t, 8, options.SendBufferSize)
+	assert.Equal(t, time.Second, options.WriteWait)
+	assert.Equal(t, PolicyDrop, options.SlowClientPolicy)
+
+	// Pings must come before the pong deadline.
+	options = Options{PingInterval: time.Minute, PongWait: 10 * time.Second}.withDefaults()
+	assert.Equal(t, 9*time.Second, options.PingInterval)
+}
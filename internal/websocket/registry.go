@@ -0,0 +1,162 @@
+package websocket
+
+import (
+	"sync"
+
+	"timer-microservice/internal/auth"
+
+	"github.com/gorilla/websocket"
+)
+
+type Role string
+
+const (
+	RoleCustomer   Role = "customer"
+	RoleGameMaster Role = "gamemaster"
+)
+
+// client is one WebSocket connection. Several clients can share a session,
+// e.g. the room TV and a tablet, or a customer screen and a game master.
+type client struct {
+	conn      *websocket.Conn
+	sessionID string
+	role      Role
+	protocol  int
+	// claims are the verified token claims, nil when authentication is
+	// off.
+	claims *auth.Claims
+
+	// send queues encoded messages for the client's write pump. done is
+	// closed when the client goes away so neither side blocks on send.
+	send      chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newClient(conn *websocket.Conn, sessionID string, role Role, protocol int, bufferSize int) *client {
+	return &client{
+		conn:      conn,
+		sessionID: sessionID,
+		role:      role,
+		protocol:  protocol,
+		send:      make(chan []byte, bufferSize),
+		done:      make(chan struct{}),
+	}
+}
+
+// watches reports whether c is sent the events of sessionID: those of its
+// own session and, for a game master, those of every session its token
+// grants.
+func (c *client) watches(sessionID string) bool {
+	if c.sessionID == sessionID {
+		return true
+	}
+	return c.role == RoleGameMaster && (c.claims == nil || c.claims.AllowsSession(sessionID))
+}
+
+// close stops the write pump and closes the connection, which also ends the
+// read loop. It is safe to call more than once.
+func (c *client) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+// registry tracks the connected clients by session.
+type registry struct {
+	mutex    sync.RWMutex
+	sessions map[string]map[*client]struct{}
+}
+
+func newRegistry() *registry {
+	return &registry{sessions: make(map[string]map[*client]struct{})}
+}
+
+func (r *registry) add(c *client) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	clients, ok := r.sessions[c.sessionID]
+	if !ok {
+		clients = make(map[*client]struct{})
+		r.sessions[c.sessionID] = clients
+	}
+	clients[c] = struct{}{}
+}
+
+// remove unregisters c only, leaving the other clients of its session
+// connected. It reports whether c was registered.
+func (r *registry) remove(c *client) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	clients, ok := r.sessions[c.sessionID]
+	if !ok {
+		return false
+	}
+	if _, ok := clients[c]; !ok {
+		return false
+	}
+
+	delete(clients, c)
+	if len(clients) == 0 {
+		delete(r.sessions, c.sessionID)
+	}
+	return true
+}
+
+// recipients returns the clients watching sessionID: its own clients and
+// the game masters whose token grants it, each client once.
+func (r *registry) recipients(sessionID string) []*client {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var result []*client
+	for _, clients := range r.sessions {
+		for c := range clients {
+			if c.watches(sessionID) {
+				result = append(result, c)
+			}
+		}
+	}
+	return result
+}
+
+// gameMasters returns the game masters whose token grants sessionID.
+func (r *registry) gameMasters(sessionID string) []*client {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var result []*client
+	for _, clients := range r.sessions {
+		for c := range clients {
+			if c.role == RoleGameMaster && c.watches(sessionID) {
+				result = append(result, c)
+			}
+		}
+	}
+	return result
+}
+
+// count returns the number of clients connected to sessionID.
+func (r *registry) count(sessionID string) int {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return len(r.sessions[sessionID])
+}
+
+// customers returns the number of customers connected to sessionID, leaving
+// out the game masters on its route.
+func (r *registry) customers(sessionID string) int {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	n := 0
+	for c := range r.sessions[sessionID] {
+		if c.role == RoleCustomer {
+			n++
+		}
+	}
+	return n
+}
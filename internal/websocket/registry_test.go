@@ -0,0 +1,97 @@
+package websocket
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"timer-microservice/internal/auth"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryConcurrentJoinsAndLeaves(t *testing.T) {
+	r := newRegistry()
+
+	const perSession = 50
+	sessions := []string{"room-a", "room-b", "room-c"}
+
+	var clients []*client
+	for _, sessionID := range sessions {
+		for i := 0; i < perSession; i++ {
+			role := RoleCustomer
+			if i%10 == 0 {
+				role = RoleGameMaster
+			}
+			clients = append(clients, &client{sessionID: sessionID, role: role})
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, c := range clients {
+		wg.Add(1)
+		go func(c *client) {
+			defer wg.Done()
+			r.add(c)
+			_ = r.recipients(c.sessionID)
+		}(c)
+	}
+	wg.Wait()
+
+	for _, sessionID := range sessions {
+		assert.Equal(t, perSession, r.count(sessionID))
+	}
+
+	// Every other client leaves while broadcasts are computed.
+	for i, c := range clients {
+		if i%2 == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(c *client) {
+			defer wg.Done()
+			assert.True(t, r.remove(c))
+			_ = r.recipients(c.sessionID)
+		}(c)
+	}
+	wg.Wait()
+
+	for _, sessionID := range sessions {
+		assert.Equal(t, perSession/2, r.count(sessionID), fmt.Sprintf("session %s", sessionID))
+	}
+}
+
+func TestRegistryRemoveOnlyClosingClient(t *testing.T) {
+	r := newRegistry()
+
+	tv := &client{sessionID: "room-a", role: RoleCustomer}
+	tablet := &client{sessionID: "room-a", role: RoleCustomer}
+	r.add(tv)
+	r.add(tablet)
+
+	assert.True(t, r.remove(tablet))
+	assert.False(t, r.remove(tablet))
+	assert.Equal(t, 1, r.count("room-a"))
+	assert.Equal(t, []*client{tv}, r.recipients("room-a"))
+}
+
+func TestRegistryRecipients(t *testing.T) {
+	r := newRegistry()
+
+	customer := &client{sessionID: "room-a", role: RoleCustomer}
+	otherCustomer := &client{sessionID: "room-b", role: RoleCustomer}
+	gameMaster := &client{sessionID: "room-b", role: RoleGameMaster}
+	scopedGameMaster := &client{
+		sessionID: "paris-1",
+		role:      RoleGameMaster,
+		claims:    &auth.Claims{Role: auth.RoleGameMaster, Sessions: []string{"paris-*"}},
+	}
+	r.add(customer)
+	r.add(otherCustomer)
+	r.add(gameMaster)
+	r.add(scopedGameMaster)
+
+	assert.ElementsMatch(t, []*client{customer, gameMaster}, r.recipients("room-a"))
+	assert.ElementsMatch(t, []*client{gameMaster}, r.gameMasters("room-a"))
+	assert.ElementsMatch(t, []*client{gameMaster, scopedGameMaster}, r.gameMasters("paris-2"))
+}
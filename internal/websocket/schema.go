@@ -0,0 +1,113 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+type fieldKind string
+
+const (
+	kindString  fieldKind = "string"
+	kindInteger fieldKind = "integer"
+	kindBoolean fieldKind = "boolean"
+)
+
+// fieldSchema describes one property of a payload object, in the spirit of
+// a JSON Schema property: its type, whether it is required, a lower bound
+// for integers and the allowed values for strings.
+type fieldSchema struct {
+	kind     fieldKind
+	required bool
+	minimum  *int64
+	enum     []string
+}
+
+// payloadSchema describes a payload object. Properties not listed are
+// rejected, like additionalProperties: false.
+type payloadSchema map[string]fieldSchema
+
+func minimum(n int64) *int64 {
+	return &n
+}
+
+// validate checks payload against the schema and returns the first
+// violation found. A null property counts as absent.
+func (s payloadSchema) validate(payload json.RawMessage) error {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &object); err != nil || object == nil {
+		return fmt.Errorf("payload must be a JSON object")
+	}
+
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := s[name]; !ok {
+			return fmt.Errorf("unknown field %q", name)
+		}
+	}
+
+	names = names[:0]
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		field := s[name]
+
+		var value interface{}
+		if raw, ok := object[name]; ok {
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return fmt.Errorf("field %q is not valid JSON", name)
+			}
+		}
+		if value == nil {
+			if field.required {
+				return fmt.Errorf("field %q is required", name)
+			}
+			continue
+		}
+
+		if err := field.check(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f fieldSchema) check(name string, value interface{}) error {
+	switch f.kind {
+	case kindString:
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("field %q must be a string", name)
+		}
+		if len(f.enum) == 0 {
+			return nil
+		}
+		for _, allowed := range f.enum {
+			if str == allowed {
+				return nil
+			}
+		}
+		return fmt.Errorf("field %q must be one of %v", name, f.enum)
+	case kindInteger:
+		number, ok := value.(float64)
+		if !ok || number != math.Trunc(number) {
+			return fmt.Errorf("field %q must be an integer", name)
+		}
+		if f.minimum != nil && number < float64(*f.minimum) {
+			return fmt.Errorf("field %q must be at least %d", name, *f.minimum)
+		}
+	case kindBoolean:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("field %q must be a boolean", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPayloadSchemaValidate(t *testing.T) {
+	schema := payloadSchema{
+		"timerId": {kind: kindInteger, required: true, minimum: minimum(1)},
+		"reason":  {kind: kindString, enum: []string{"hint", "bonus"}},
+		"silent":  {kind: kindBoolean},
+	}
+
+	tests := []struct {
+		payload string
+		err     string
+	}{
+		{`{"timerId": 1}`, ""},
+		{`{"timerId": 1, "reason": "hint", "silent": true}`, ""},
+		{`{"timerId": 1, "reason": null}`, ""},
+		{`{"timerId": null}`, `field "timerId" is required`},
+		{`{"timerId": 1.5}`, `field "timerId" must be an integer`},
+		{`{"timerId": 0}`, `field "timerId" must be at least 1`},
+		{`{"timerId": 1, "reason": "luck"}`, `field "reason" must be one of [hint bonus]`},
+		{`{"timerId": 1, "silent": "yes"}`, `field "silent" must be a boolean`},
+		{`{"timerId": 1, "extra": 2}`, `unknown field "extra"`},
+		{`"timerId"`, `payload must be a JSON object`},
+		{`null`, `payload must be a JSON object`},
+	}
+	for _, tt := range tests {
+		err := schema.validate(json.RawMessage(tt.payload))
+		if tt.err == "" {
+			assert.NoError(t, err, tt.payload)
+		} else {
+			assert.EqualError(t, err, tt.err, tt.payload)
+		}
+	}
+}
+
+func TestNegotiatedProtocol(t *testing.T) {
+	assert.Equal(t, ProtocolV2, negotiatedProtocol("timer.v2"))
+	assert.Equal(t, ProtocolV1, negotiatedProtocol("timer.v1"))
+	assert.Equal(t, ProtocolV1, negotiatedProtocol(""))
+}
@@ -2,18 +2,25 @@ package websocket
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
 
+	"timer-microservice/internal/auth"
+	"timer-microservice/internal/auth/authtest"
+	"timer-microservice/internal/clock/clocktest"
 	"timer-microservice/internal/types"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 // MockTimerService is a mock of TimerService
@@ -21,52 +28,120 @@ type MockTimerService struct {
 	mock.Mock
 }
 
-func (m *MockTimerService) CreateTimer(sessionID string, maxTime int64) (*types.Timer, error) {
-	args := m.Called(sessionID, maxTime)
+func (m *MockTimerService) CreateTimer(req types.TimerRequest) (*types.Timer, error) {
+	args := m.Called(req)
 	return args.Get(0).(*types.Timer), args.Error(1)
 }
 
-func (m *MockTimerService) PauseTimer(id uint) (*types.Timer, error) {
-	args := m.Called(id)
+func (m *MockTimerService) CreateTimerIdempotent(key string, req types.TimerRequest) (*types.Timer, bool, error) {
+	args := m.Called(key, req)
+	return args.Get(0).(*types.Timer), args.Bool(1), args.Error(2)
+}
+
+func (m *MockTimerService) PauseTimer(id, ifMatch uint) (*types.Timer, error) {
+	args := m.Called(id, ifMatch)
 	return args.Get(0).(*types.Timer), args.Error(1)
 }
 
-func (m *MockTimerService) ResumeTimer(id uint) (*types.Timer, error) {
-	args := m.Called(id)
+func (m *MockTimerService) ResumeTimer(id, ifMatch uint) (*types.Timer, error) {
+	args := m.Called(id, ifMatch)
 	return args.Get(0).(*types.Timer), args.Error(1)
 }
 
-func (m *MockTimerService) StopTimer(id uint) error {
-	args := m.Called(id)
-	return args.Error(0)
+func (m *MockTimerService) StopTimer(id, ifMatch uint) (*types.Timer, error) {
+	args := m.Called(id, ifMatch)
+	return args.Get(0).(*types.Timer), args.Error(1)
 }
 
-func (m *MockTimerService) ModifyTimer(id uint, newMaxTime int64) (*types.Timer, error) {
-	args := m.Called(id, newMaxTime)
+func (m *MockTimerService) ModifyTimer(id, ifMatch uint, newMaxTime int64) (*types.Timer, error) {
+	args := m.Called(id, ifMatch, newMaxTime)
 	return args.Get(0).(*types.Timer), args.Error(1)
 }
 
-func setupWebSocketServer(t *testing.T) (*httptest.Server, *Handler, *MockTimerService) {
+func (m *MockTimerService) AdjustTimer(id, ifMatch uint, delta int64, reason string) (*types.Timer, error) {
+	args := m.Called(id, ifMatch, delta, reason)
+	return args.Get(0).(*types.Timer), args.Error(1)
+}
+
+func (m *MockTimerService) GetTimer(id uint) (*types.Timer, error) {
+	args := m.Called(id)
+	return args.Get(0).(*types.Timer), args.Error(1)
+}
+
+func (m *MockTimerService) GetLiveTimers(sessionID string) ([]types.Timer, error) {
+	args := m.Called(sessionID)
+	return args.Get(0).([]types.Timer), args.Error(1)
+}
+
+// expectSnapshots lets every connection load an empty snapshot.
+func expectSnapshots(mockService *MockTimerService) {
+	mockService.On("GetLiveTimers", mock.Anything).Return([]types.Timer{}, nil).Maybe()
+}
+
+// MockHintService is a mock of HintServiceInterface
+type MockHintService struct {
+	mock.Mock
+}
+
+func (m *MockHintService) SendHint(sessionID string, req types.HintRequest) (*types.Hint, error) {
+	args := m.Called(sessionID, req)
+	return args.Get(0).(*types.Hint), args.Error(1)
+}
+
+// setupWebSocketServer serves a Handler on a mock service, with
+// DefaultOptions unless options are given. Clients join sessions by URL
+// under /ws/customer/ and /ws/gamemaster/, and connect as a game master
+// outside any session on /.
+func setupWebSocketServer(t *testing.T, options ...Options) (*httptest.Server, *Handler, *MockTimerService) {
 	mockService := new(MockTimerService)
+	expectSnapshots(mockService)
 	logger, _ := zap.NewDevelopment()
 	sugar := logger.Sugar()
 
-	handler := NewHandler(mockService, sugar)
+	handlerOptions := DefaultOptions()
+	if len(options) > 0 {
+		handlerOptions = options[0]
+	}
+	handler := NewHandler(mockService, sugar, clocktest.NewFake(time.Now()), handlerOptions)
 
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		handler.HandleGameMasterWebSocket(w, r)
-	}))
+	router := chi.NewRouter()
+	router.Get("/", handler.HandleGameMasterWebSocket)
+	router.Get("/ws/customer/{sessionID}", handler.HandleCustomerWebSocket)
+	router.Get("/ws/gamemaster/{sessionID}", handler.HandleGameMasterWebSocket)
 
-	return server, handler, mockService
+	return httptest.NewServer(router), handler, mockService
 }
 
-func connectWebSocket(t *testing.T, server *httptest.Server) *websocket.Conn {
-	url := "ws" + strings.TrimPrefix(server.URL, "http")
-	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
-	assert.NoError(t, err)
+// dialWebSocket connects to path and reads the snapshot every connection
+// starts with.
+func dialWebSocket(t *testing.T, server *httptest.Server, path string) *websocket.Conn {
+	ws, _ := dialWebSocketProtocol(t, server, path)
 	return ws
 }
 
+// dialWebSocketProtocol connects to path offering subprotocols and returns
+// the connection with its snapshot.
+func dialWebSocketProtocol(t *testing.T, server *httptest.Server, path string, subprotocols ...string) (*websocket.Conn, types.SnapshotPayload) {
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + path
+	dialer := websocket.Dialer{Subprotocols: subprotocols}
+	ws, _, err := dialer.Dial(url, nil)
+	assert.NoError(t, err)
+
+	ws.SetReadDeadline(time.Now().Add(time.Second))
+	var message types.WebSocketMessage
+	assert.NoError(t, ws.ReadJSON(&message))
+	assert.Equal(t, types.TypeSnapshot, message.Type)
+	ws.SetReadDeadline(time.Time{})
+
+	var snapshot types.SnapshotPayload
+	assert.NoError(t, json.Unmarshal(message.Payload, &snapshot))
+	return ws, snapshot
+}
+
+func connectWebSocket(t *testing.T, server *httptest.Server) *websocket.Conn {
+	return dialWebSocket(t, server, "")
+}
+
 func TestCreateTimer(t *testing.T) {
 	server, _, mockService := setupWebSocketServer(t)
 	defer server.Close()
@@ -89,7 +164,7 @@ func TestCreateTimer(t *testing.T) {
 		CurrentTime: 60,
 		IsPaused:    false,
 	}
-	mockService.On("CreateTimer", "test-session", int64(60)).Return(expectedTimer, nil)
+	mockService.On("CreateTimer", types.TimerRequest{SessionID: "test-session", MaxTime: 60}).Return(expectedTimer, nil)
 
 	err := ws.WriteJSON(createMsg)
 	assert.NoError(t, err)
@@ -111,6 +186,51 @@ func TestCreateTimer(t *testing.T) {
 	mockService.AssertExpectations(t)
 }
 
+func TestCreateTimerWithRequestIDIsIdempotent(t *testing.T) {
+	server, _, mockService := setupWebSocketServer(t)
+	defer server.Close()
+
+	ws := connectWebSocket(t, server)
+	defer ws.Close()
+	observer := connectWebSocket(t, server)
+	defer observer.Close()
+
+	req := types.TimerRequest{SessionID: "test-session", MaxTime: 60}
+	timer := &types.Timer{ID: 1, SessionID: "test-session", MaxTime: 60, CurrentTime: 60}
+	mockService.On("CreateTimerIdempotent", "create-1", req).Return(timer, false, nil).Once()
+	mockService.On("CreateTimerIdempotent", "create-1", req).Return(timer, true, nil).Once()
+
+	create := types.WebSocketMessage{
+		Type:      types.TypeTimerCreate,
+		RequestID: "create-1",
+		Payload:   json.RawMessage(`{"sessionId": "test-session", "maxTime": 60}`),
+	}
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, ws.WriteJSON(create))
+
+		var update types.WebSocketMessage
+		assert.NoError(t, ws.ReadJSON(&update))
+		assert.Equal(t, types.TypeTimerUpdate, update.Type)
+		var created types.Timer
+		assert.NoError(t, json.Unmarshal(update.Payload, &created))
+		assert.Equal(t, uint(1), created.ID)
+
+		reply := readReply(t, ws)
+		assert.Equal(t, types.TypeAck, reply.Type)
+		assert.Equal(t, "create-1", reply.RequestID)
+	}
+
+	// Only the first creation is broadcast.
+	var update types.WebSocketMessage
+	assert.NoError(t, observer.ReadJSON(&update))
+	assert.Equal(t, types.TypeTimerUpdate, update.Type)
+	observer.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	assert.Error(t, observer.ReadJSON(&update))
+
+	mockService.AssertExpectations(t)
+	mockService.AssertNotCalled(t, "CreateTimer", mock.Anything)
+}
+
 func TestPauseTimer(t *testing.T) {
 	server, _, mockService := setupWebSocketServer(t)
 	defer server.Close()
@@ -132,7 +252,7 @@ func TestPauseTimer(t *testing.T) {
 		CurrentTime: 30,
 		IsPaused:    true,
 	}
-	mockService.On("PauseTimer", uint(1)).Return(pausedTimer, nil)
+	mockService.On("PauseTimer", uint(1), uint(0)).Return(pausedTimer, nil)
 
 	err := ws.WriteJSON(pauseMsg)
 	assert.NoError(t, err)
@@ -172,7 +292,7 @@ func TestResumeTimer(t *testing.T) {
 		CurrentTime: 30,
 		IsPaused:    false,
 	}
-	mockService.On("ResumeTimer", uint(1)).Return(resumedTimer, nil)
+	mockService.On("ResumeTimer", uint(1), uint(0)).Return(resumedTimer, nil)
 
 	err := ws.WriteJSON(resumeMsg)
 	assert.NoError(t, err)
@@ -213,7 +333,7 @@ func TestModifyTimer(t *testing.T) {
 		CurrentTime: 60,
 		IsPaused:    false,
 	}
-	mockService.On("ModifyTimer", uint(1), int64(90)).Return(modifiedTimer, nil)
+	mockService.On("ModifyTimer", uint(1), uint(0), int64(90)).Return(modifiedTimer, nil)
 
 	err := ws.WriteJSON(modifyMsg)
 	assert.NoError(t, err)
@@ -233,9 +353,17 @@ func TestModifyTimer(t *testing.T) {
 }
 
 func TestStopTimer(t *testing.T) {
-	server, _, mockService := setupWebSocketServer(t)
+	server, handler, mockService := setupWebSocketServer(t)
 	defer server.Close()
 
+	display := dialWebSocket(t, server, "/ws/customer/room-a")
+	defer display.Close()
+	otherRoom := dialWebSocket(t, server, "/ws/customer/room-c")
+	defer otherRoom.Close()
+	assert.Eventually(t, func() bool {
+		return handler.connections.count("room-a") == 1 && handler.connections.count("room-c") == 1
+	}, time.Second, 10*time.Millisecond)
+
 	ws := connectWebSocket(t, server)
 	defer ws.Close()
 
@@ -246,26 +374,119 @@ func TestStopTimer(t *testing.T) {
 		}`),
 	}
 
-	mockService.On("StopTimer", uint(1)).Return(nil)
+	mockService.On("StopTimer", uint(1), uint(0)).Return(&types.Timer{ID: 1, SessionID: "room-a", State: types.StateStopped}, nil)
 
 	err := ws.WriteJSON(stopMsg)
 	assert.NoError(t, err)
 
+	for _, conn := range []*websocket.Conn{ws, display} {
+		response := readMessage(t, conn)
+		assert.Equal(t, types.TypeTimerStop, response.Type)
+
+		var stopResponse struct {
+			ID uint `json:"id"`
+		}
+		err = json.Unmarshal(response.Payload, &stopResponse)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(1), stopResponse.ID)
+	}
+
+	// The stop only reaches the timer's session.
+	otherRoom.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	var unexpected types.WebSocketMessage
+	assert.Error(t, otherRoom.ReadJSON(&unexpected))
+
+	mockService.AssertExpectations(t)
+}
+
+func TestAdjustTimer(t *testing.T) {
+	server, handler, mockService := setupWebSocketServer(t)
+	defer server.Close()
+
+	ws := connectWebSocket(t, server)
+	defer ws.Close()
+
+	adjustMsg := types.WebSocketMessage{
+		Type: types.TypeTimerAdjust,
+		Payload: json.RawMessage(`{
+			"timerId": 1,
+			"delta": -120,
+			"reason": "hint"
+		}`),
+	}
+
+	adjustedTimer := &types.Timer{
+		ID:          1,
+		SessionID:   "test-session",
+		MaxTime:     3600,
+		CurrentTime: 1080,
+	}
+	adjustment := types.TimerAdjustment{Delta: -120, Reason: "hint"}
+
+	// The service broadcasts adjustments itself.
+	mockService.On("AdjustTimer", uint(1), uint(0), int64(-120), "hint").Run(func(args mock.Arguments) {
+		handler.BroadcastTimerAdjusted(adjustedTimer, adjustment)
+	}).Return(adjustedTimer, nil)
+
+	err := ws.WriteJSON(adjustMsg)
+	assert.NoError(t, err)
+
 	var response types.WebSocketMessage
 	err = ws.ReadJSON(&response)
 	assert.NoError(t, err)
-	assert.Equal(t, types.TypeTimerStop, response.Type)
+	assert.Equal(t, types.TypeTimerAdjust, response.Type)
 
-	var stopResponse struct {
-		ID uint `json:"id"`
-	}
-	err = json.Unmarshal(response.Payload, &stopResponse)
+	var adjustedResponse types.TimerAdjustedPayload
+	err = json.Unmarshal(response.Payload, &adjustedResponse)
 	assert.NoError(t, err)
-	assert.Equal(t, uint(1), stopResponse.ID)
+	assert.Equal(t, adjustedTimer.CurrentTime, adjustedResponse.Timer.CurrentTime)
+	assert.Equal(t, adjustment.Delta, adjustedResponse.Adjustment.Delta)
+	assert.Equal(t, adjustment.Reason, adjustedResponse.Adjustment.Reason)
 
 	mockService.AssertExpectations(t)
 }
 
+func TestSendHint(t *testing.T) {
+	server, handler, _ := setupWebSocketServer(t)
+	defer server.Close()
+
+	mockHints := new(MockHintService)
+	handler.SetHintService(mockHints)
+
+	ws := connectWebSocket(t, server)
+	defer ws.Close()
+
+	hintMsg := types.WebSocketMessage{
+		Type: types.TypeHintSent,
+		Payload: json.RawMessage(`{
+			"text": "Look under the rug"
+		}`),
+	}
+
+	hint := &types.Hint{ID: 1, TimerID: 1, Text: "Look under the rug", Penalty: 120}
+
+	// The hint service pushes the hint to the session itself.
+	mockHints.On("SendHint", "", types.HintRequest{Text: "Look under the rug"}).Run(func(args mock.Arguments) {
+		handler.SendHint(hint)
+	}).Return(hint, nil)
+
+	err := ws.WriteJSON(hintMsg)
+	assert.NoError(t, err)
+
+	var response types.WebSocketMessage
+	err = ws.ReadJSON(&response)
+	assert.NoError(t, err)
+	assert.Equal(t, types.TypeHintSent, response.Type)
+
+	var hintResponse types.HintResponse
+	err = json.Unmarshal(response.Payload, &hintResponse)
+	assert.NoError(t, err)
+	assert.Equal(t, hint.Text, hintResponse.Text)
+	assert.Equal(t, hint.Penalty, hintResponse.Penalty)
+
+	mockHints.AssertExpectations(t)
+}
+
 func TestBroadcastTimerUpdate(t *testing.T) {
 	server, handler, _ := setupWebSocketServer(t)
 	defer server.Close()
@@ -284,6 +505,11 @@ func TestBroadcastTimerUpdate(t *testing.T) {
 		IsPaused:    false,
 	}
 
+	// Both connections share a session and must stay registered
+	assert.Eventually(t, func() bool {
+		return handler.connections.count("") == 2
+	}, time.Second, 10*time.Millisecond)
+
 	// Use a goroutine to broadcast the update
 	go handler.BroadcastTimerUpdate(updateTimer)
 
@@ -323,3 +549,632 @@ func TestBroadcastTimerUpdate(t *testing.T) {
 		t.Fatal("Test timed out")
 	}
 }
+
+func TestMultipleClientsPerSession(t *testing.T) {
+	server, handler, _ := setupWebSocketServer(t)
+	defer server.Close()
+
+	tv := dialWebSocket(t, server, "/ws/customer/room-a")
+	defer tv.Close()
+	tablet := dialWebSocket(t, server, "/ws/customer/room-a")
+	defer tablet.Close()
+	gameMaster := dialWebSocket(t, server, "/ws/gamemaster/room-b")
+	defer gameMaster.Close()
+	otherRoom := dialWebSocket(t, server, "/ws/customer/room-c")
+	defer otherRoom.Close()
+
+	assert.Eventually(t, func() bool {
+		return handler.connections.count("room-a") == 2 &&
+			handler.connections.count("room-b") == 1 &&
+			handler.connections.count("room-c") == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// readTimer skips the presence events game masters receive.
+	readTimer := func(ws *websocket.Conn) *types.Timer {
+		ws.SetReadDeadline(time.Now().Add(time.Second))
+		var response types.WebSocketMessage
+		for response.Type == "" || response.Type == types.TypePresence {
+			if err := ws.ReadJSON(&response); err != nil {
+				return nil
+			}
+		}
+		var timer types.Timer
+		assert.NoError(t, json.Unmarshal(response.Payload, &timer))
+		return &timer
+	}
+
+	handler.BroadcastTimerUpdate(&types.Timer{ID: 1, SessionID: "room-a", CurrentTime: 30})
+
+	for _, ws := range []*websocket.Conn{tv, tablet, gameMaster} {
+		timer := readTimer(ws)
+		if assert.NotNil(t, timer) {
+			assert.Equal(t, int64(30), timer.CurrentTime)
+		}
+	}
+
+	// Other rooms' customers only see their own timers.
+	otherRoom.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	var unexpected types.WebSocketMessage
+	assert.Error(t, otherRoom.ReadJSON(&unexpected))
+
+	// Closing the tablet leaves the TV connected.
+	tablet.Close()
+	assert.Eventually(t, func() bool {
+		return handler.connections.count("room-a") == 1
+	}, time.Second, 10*time.Millisecond)
+
+	handler.BroadcastTimerUpdate(&types.Timer{ID: 1, SessionID: "room-a", CurrentTime: 29})
+
+	timer := readTimer(tv)
+	if assert.NotNil(t, timer) {
+		assert.Equal(t, int64(29), timer.CurrentTime)
+	}
+}
+
+// readPresence returns the next presence event on a game master connection.
+func readPresence(t *testing.T, ws *websocket.Conn) *types.PresencePayload {
+	ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		var message types.WebSocketMessage
+		if err := ws.ReadJSON(&message); err != nil {
+			return nil
+		}
+		if message.Type != types.TypePresence {
+			continue
+		}
+		var presence types.PresencePayload
+		assert.NoError(t, json.Unmarshal(message.Payload, &presence))
+		return &presence
+	}
+}
+
+func TestDeadClientIsUnregistered(t *testing.T) {
+	server, handler, _ := setupWebSocketServer(t, Options{
+		PingInterval: 20 * time.Millisecond,
+		PongWait:     100 * time.Millisecond,
+	})
+	defer server.Close()
+
+	gameMaster := dialWebSocket(t, server, "/ws/gamemaster/ops")
+	defer gameMaster.Close()
+
+	// The display keeps reading but never answers pings, like a tablet
+	// whose Wi-Fi dropped without closing the socket.
+	display := dialWebSocket(t, server, "/ws/customer/room-a")
+	defer display.Close()
+	display.SetPingHandler(func(string) error { return nil })
+	go func() {
+		for {
+			if _, _, err := display.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	online := readPresence(t, gameMaster)
+	if assert.NotNil(t, online) {
+		assert.Equal(t, "room-a", online.SessionID)
+		assert.Equal(t, types.PresenceOnline, online.Status)
+		assert.Equal(t, 1, online.Clients)
+	}
+
+	offline := readPresence(t, gameMaster)
+	if assert.NotNil(t, offline) {
+		assert.Equal(t, "room-a", offline.SessionID)
+		assert.Equal(t, types.PresenceOffline, offline.Status)
+		assert.Equal(t, "timeout", offline.Reason)
+		assert.Equal(t, 0, offline.Clients)
+	}
+	assert.Equal(t, 0, handler.connections.count("room-a"))
+}
+
+func TestPresenceCountsOnlyCustomers(t *testing.T) {
+	server, handler, _ := setupWebSocketServer(t)
+	defer server.Close()
+
+	gameMaster := dialWebSocket(t, server, "/ws/gamemaster/room-a")
+	defer gameMaster.Close()
+	assert.Eventually(t, func() bool {
+		return handler.connections.count("room-a") == 1
+	}, time.Second, 10*time.Millisecond)
+
+	display := dialWebSocket(t, server, "/ws/customer/room-a")
+	defer display.Close()
+
+	online := readPresence(t, gameMaster)
+	if assert.NotNil(t, online) {
+		assert.Equal(t, types.PresenceOnline, online.Status)
+		assert.Equal(t, 1, online.Clients)
+	}
+	assert.Equal(t, 2, handler.connections.count("room-a"))
+
+	display.Close()
+	offline := readPresence(t, gameMaster)
+	if assert.NotNil(t, offline) {
+		assert.Equal(t, types.PresenceOffline, offline.Status)
+		assert.Equal(t, 0, offline.Clients)
+	}
+}
+
+func TestLiveClientStaysConnected(t *testing.T) {
+	server, handler, _ := setupWebSocketServer(t, Options{
+		PingInterval: 20 * time.Millisecond,
+		PongWait:     100 * time.Millisecond,
+	})
+	defer server.Close()
+
+	// Reading lets the default ping handler answer with pongs.
+	display := dialWebSocket(t, server, "/ws/customer/room-a")
+	defer display.Close()
+	go func() {
+		for {
+			if _, _, err := display.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, 1, handler.connections.count("room-a"))
+}
+
+func TestOversizedMessageClosesConnection(t *testing.T) {
+	server, handler, _ := setupWebSocketServer(t, Options{ReadLimit: 64})
+	defer server.Close()
+
+	ws := dialWebSocket(t, server, "/ws/customer/room-a")
+	defer ws.Close()
+
+	assert.Eventually(t, func() bool {
+		return handler.connections.count("room-a") == 1
+	}, time.Second, 10*time.Millisecond)
+
+	err := ws.WriteJSON(types.WebSocketMessage{
+		Type:    types.TypeHintSent,
+		Payload: json.RawMessage(`{"text": "` + strings.Repeat("x", 128) + `"}`),
+	})
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		return handler.connections.count("room-a") == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestSnapshotOnConnect(t *testing.T) {
+	mockService := new(MockTimerService)
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	handler := NewHandler(mockService, zap.NewNop().Sugar(), clk, DefaultOptions())
+
+	router := chi.NewRouter()
+	router.Get("/ws/customer/{sessionID}", handler.HandleCustomerWebSocket)
+	router.Get("/ws/gamemaster/{sessionID}", handler.HandleGameMasterWebSocket)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	roomA := types.Timer{ID: 2, SessionID: "room-a", CurrentTime: 40, State: types.StateRunning}
+	roomB := types.Timer{ID: 3, SessionID: "room-b", CurrentTime: 10, State: types.StatePaused}
+	mockService.On("GetLiveTimers", "room-a").Return([]types.Timer{roomA}, nil)
+	mockService.On("GetLiveTimers", "").Return([]types.Timer{roomB, roomA}, nil)
+
+	readSnapshot := func(path string, header http.Header) types.SnapshotPayload {
+		url := "ws" + strings.TrimPrefix(server.URL, "http") + path
+		ws, _, err := websocket.DefaultDialer.Dial(url, header)
+		if !assert.NoError(t, err) {
+			return types.SnapshotPayload{}
+		}
+		defer ws.Close()
+
+		ws.SetReadDeadline(time.Now().Add(time.Second))
+		var message types.WebSocketMessage
+		assert.NoError(t, ws.ReadJSON(&message))
+		assert.Equal(t, types.TypeSnapshot, message.Type)
+
+		var snapshot types.SnapshotPayload
+		assert.NoError(t, json.Unmarshal(message.Payload, &snapshot))
+		return snapshot
+	}
+
+	ids := func(snapshot types.SnapshotPayload) []uint {
+		ids := []uint{}
+		for _, timer := range snapshot.Timers {
+			ids = append(ids, timer.ID)
+		}
+		return ids
+	}
+
+	// Customers see their own session's live timers.
+	snapshot := readSnapshot("/ws/customer/room-a", nil)
+	assert.True(t, clk.Now().Equal(snapshot.ServerTime))
+	if assert.Len(t, snapshot.Timers, 1) {
+		assert.Equal(t, uint(2), snapshot.Timers[0].ID)
+		assert.Equal(t, int64(40), snapshot.Timers[0].CurrentTime)
+	}
+
+	// Game masters see every session their token grants.
+	assert.Equal(t, []uint{3, 2}, ids(readSnapshot("/ws/gamemaster/ops", nil)))
+
+	signer := authtest.NewSigner(t, clk)
+	handler.SetVerifier(signer)
+	token, _ := signer.Sign(auth.Claims{Role: auth.RoleGameMaster, Sessions: []string{"ops", "room-b"}}, time.Hour)
+	snapshot = readSnapshot("/ws/gamemaster/ops", http.Header{"Authorization": {"Bearer " + token}})
+	assert.Equal(t, []uint{3}, ids(snapshot))
+
+	mockService.AssertExpectations(t)
+}
+
+// readReply returns the next ACK or ERROR message on ws.
+func readReply(t *testing.T, ws *websocket.Conn) types.WebSocketMessage {
+	ws.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		var message types.WebSocketMessage
+		if !assert.NoError(t, ws.ReadJSON(&message)) {
+			return message
+		}
+		if message.Type == types.TypeAck || message.Type == types.TypeError {
+			return message
+		}
+	}
+}
+
+func readErrorPayload(t *testing.T, message types.WebSocketMessage) types.ErrorPayload {
+	assert.Equal(t, types.TypeError, message.Type)
+	var payload types.ErrorPayload
+	assert.NoError(t, json.Unmarshal(message.Payload, &payload))
+	return payload
+}
+
+type stateError struct{}
+
+func (stateError) Error() string              { return "cannot pause timer 2: timer is expired" }
+func (stateError) ErrorCode() types.ErrorCode { return types.ErrCodeInvalidState }
+
+func TestCommandReplies(t *testing.T) {
+	server, _, mockService := setupWebSocketServer(t)
+	defer server.Close()
+
+	ws := connectWebSocket(t, server)
+	defer ws.Close()
+
+	mockService.On("PauseTimer", uint(1), uint(0)).Return(&types.Timer{ID: 1, IsPaused: true}, nil)
+	mockService.On("PauseTimer", uint(2), uint(0)).Return((*types.Timer)(nil), stateError{})
+	mockService.On("PauseTimer", uint(3), uint(0)).Return((*types.Timer)(nil), gorm.ErrRecordNotFound)
+	mockService.On("ResumeTimer", uint(4), uint(0)).Return((*types.Timer)(nil), errors.New("connection refused"))
+
+	send := func(messageType types.MessageType, requestID, payload string) types.WebSocketMessage {
+		err := ws.WriteJSON(types.WebSocketMessage{
+			Type:      messageType,
+			RequestID: requestID,
+			Payload:   json.RawMessage(payload),
+		})
+		assert.NoError(t, err)
+		return readReply(t, ws)
+	}
+
+	reply := send(types.TypeTimerPause, "req-1", `{"sessionId": "1"}`)
+	assert.Equal(t, types.TypeAck, reply.Type)
+	assert.Equal(t, "req-1", reply.RequestID)
+	var ack types.AckPayload
+	assert.NoError(t, json.Unmarshal(reply.Payload, &ack))
+	assert.Equal(t, types.TypeTimerPause, ack.Command)
+
+	reply = send(types.TypeTimerPause, "req-2", `{"sessionId": "2"}`)
+	assert.Equal(t, "req-2", reply.RequestID)
+	payload := readErrorPayload(t, reply)
+	assert.Equal(t, types.TypeTimerPause, payload.Command)
+	assert.Equal(t, types.ErrCodeInvalidState, payload.Code)
+	assert.Equal(t, "cannot pause timer 2: timer is expired", payload.Message)
+
+	reply = send(types.TypeTimerPause, "req-3", `{"sessionId": "3"}`)
+	assert.Equal(t, types.ErrCodeNotFound, readErrorPayload(t, reply).Code)
+
+	// Unexpected failures are not echoed to the client.
+	reply = send(types.TypeTimerResume, "req-4", `{"sessionId": "4"}`)
+	payload = readErrorPayload(t, reply)
+	assert.Equal(t, types.ErrCodeInternal, payload.Code)
+	assert.Equal(t, "internal error", payload.Message)
+
+	reply = send(types.TypeTimerPause, "req-5", `{"sessionId": "abc"}`)
+	assert.Equal(t, types.ErrCodeInvalidPayload, readErrorPayload(t, reply).Code)
+
+	reply = send("TIMER_EXPLODE", "req-6", `{}`)
+	assert.Equal(t, types.ErrCodeUnknownType, readErrorPayload(t, reply).Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestMalformedMessageKeepsConnection(t *testing.T) {
+	server, _, mockService := setupWebSocketServer(t)
+	defer server.Close()
+
+	ws := connectWebSocket(t, server)
+	defer ws.Close()
+
+	assert.NoError(t, ws.WriteMessage(websocket.TextMessage, []byte(`{"type": `)))
+	assert.Equal(t, types.ErrCodeInvalidMessage, readErrorPayload(t, readReply(t, ws)).Code)
+
+	mockService.On("PauseTimer", uint(1), uint(0)).Return(&types.Timer{ID: 1, IsPaused: true}, nil)
+	assert.NoError(t, ws.WriteJSON(types.WebSocketMessage{
+		Type:    types.TypeTimerPause,
+		Payload: json.RawMessage(`{"sessionId": "1"}`),
+	}))
+	assert.Equal(t, types.TypeAck, readReply(t, ws).Type)
+}
+
+func TestCustomerCannotSendHint(t *testing.T) {
+	server, _, _ := setupWebSocketServer(t)
+	defer server.Close()
+
+	ws := dialWebSocket(t, server, "/ws/customer/room-a")
+	defer ws.Close()
+
+	assert.NoError(t, ws.WriteJSON(types.WebSocketMessage{
+		Type:      types.TypeHintSent,
+		RequestID: "hint-1",
+		Payload:   json.RawMessage(`{"text": "Look under the rug"}`),
+	}))
+
+	reply := readReply(t, ws)
+	assert.Equal(t, "hint-1", reply.RequestID)
+	assert.Equal(t, types.ErrCodeForbidden, readErrorPayload(t, reply).Code)
+}
+
+func TestProtocolNegotiation(t *testing.T) {
+	server, _, _ := setupWebSocketServer(t)
+	defer server.Close()
+
+	legacy, snapshot := dialWebSocketProtocol(t, server, "")
+	defer legacy.Close()
+	assert.Equal(t, "", legacy.Subprotocol())
+	assert.Equal(t, ProtocolV1, snapshot.Protocol)
+
+	current, snapshot := dialWebSocketProtocol(t, server, "", "timer.v1", "timer.v2")
+	defer current.Close()
+	assert.Equal(t, "timer.v2", current.Subprotocol())
+	assert.Equal(t, ProtocolV2, snapshot.Protocol)
+}
+
+func TestProtocolV2Commands(t *testing.T) {
+	server, _, mockService := setupWebSocketServer(t)
+	defer server.Close()
+
+	ws, _ := dialWebSocketProtocol(t, server, "", "timer.v2")
+	defer ws.Close()
+
+	mockService.On("PauseTimer", uint(7), uint(0)).Return(&types.Timer{ID: 7, IsPaused: true}, nil)
+	mockService.On("ModifyTimer", uint(7), uint(0), int64(90)).Return(&types.Timer{ID: 7, MaxTime: 90}, nil)
+	mockService.On("StopTimer", uint(7), uint(0)).Return(&types.Timer{ID: 7, State: types.StateStopped}, nil)
+
+	send := func(messageType types.MessageType, payload string) types.WebSocketMessage {
+		err := ws.WriteJSON(types.WebSocketMessage{Type: messageType, Payload: json.RawMessage(payload)})
+		assert.NoError(t, err)
+		return readReply(t, ws)
+	}
+
+	assert.Equal(t, types.TypeAck, send(types.TypeTimerPause, `{"timerId": 7}`).Type)
+	assert.Equal(t, types.TypeAck, send(types.TypeTimerModify, `{"timerId": 7, "maxTime": 90}`).Type)
+	assert.Equal(t, types.TypeAck, send(types.TypeTimerStop, `{"timerId": 7}`).Type)
+
+	tests := []struct {
+		messageType types.MessageType
+		payload     string
+		message     string
+	}{
+		{types.TypeTimerPause, `{"sessionId": "7"}`, `unknown field "sessionId"`},
+		{types.TypeTimerPause, `{"timerId": "7"}`, `field "timerId" must be an integer`},
+		{types.TypeTimerResume, `{}`, `field "timerId" is required`},
+		{types.TypeTimerModify, `{"timerId": 7}`, `field "maxTime" is required`},
+		{types.TypeTimerCreate, `{"sessionId": "room-a", "maxTime": 60, "mode": "sideways"}`, `field "mode" must be one of [countdown countup]`},
+		{types.TypeHintSent, `{"text": "Try the clock", "penalty": -30}`, `field "penalty" must be at least 0`},
+		{types.TypeTimerStop, `[7]`, `payload must be a JSON object`},
+	}
+	for _, tt := range tests {
+		payload := readErrorPayload(t, send(tt.messageType, tt.payload))
+		assert.Equal(t, types.ErrCodeInvalidPayload, payload.Code, tt.payload)
+		assert.Equal(t, tt.message, payload.Message, tt.payload)
+	}
+
+	mockService.AssertExpectations(t)
+}
+
+func TestCommandsAreValidated(t *testing.T) {
+	server, _, mockService := setupWebSocketServer(t)
+	defer server.Close()
+
+	legacy := connectWebSocket(t, server)
+	defer legacy.Close()
+	current, _ := dialWebSocketProtocol(t, server, "", "timer.v2")
+	defer current.Close()
+
+	send := func(ws *websocket.Conn, messageType types.MessageType, payload string) types.ErrorPayload {
+		err := ws.WriteJSON(types.WebSocketMessage{Type: messageType, Payload: json.RawMessage(payload)})
+		assert.NoError(t, err)
+		return readErrorPayload(t, readReply(t, ws))
+	}
+
+	payload := send(legacy, types.TypeTimerCreate, `{"sessionId": "room a", "maxTime": 0}`)
+	assert.Equal(t, types.ErrCodeInvalidArgument, payload.Code)
+	assert.Equal(t, []types.FieldError{
+		{Field: "sessionId", Message: "must match ^[A-Za-z0-9][A-Za-z0-9_.-]*$"},
+		{Field: "maxTime", Message: "must be at least 1 second"},
+	}, payload.Errors)
+
+	// Version 1 payloads have no schema, but unknown fields are still
+	// rejected.
+	payload = send(legacy, types.TypeTimerCreate, `{"sessionId": "room-a", "maxTime": 60, "duration": 60}`)
+	assert.Equal(t, types.ErrCodeInvalidPayload, payload.Code)
+	assert.Equal(t, []types.FieldError{{Field: "duration", Message: "unknown field"}}, payload.Errors)
+
+	payload = send(legacy, types.TypeTimerModify, `{"sessionId": "7", "maxTime": 86401}`)
+	assert.Equal(t, types.ErrCodeInvalidArgument, payload.Code)
+	assert.Equal(t, []types.FieldError{{Field: "maxTime", Message: "must be at most 86400 seconds"}}, payload.Errors)
+
+	payload = send(legacy, types.TypeTimerModify, `{"sessionId": "7", "maxTime": -5}`)
+	assert.Equal(t, types.ErrCodeInvalidArgument, payload.Code)
+	assert.Equal(t, []types.FieldError{{Field: "maxTime", Message: "must be at least 1 second"}}, payload.Errors)
+
+	payload = send(legacy, types.TypeTimerAdjust, `{"timerId": 7, "delta": -60, "reason": "hint", "by": "alice"}`)
+	assert.Equal(t, types.ErrCodeInvalidPayload, payload.Code)
+	assert.Equal(t, []types.FieldError{{Field: "by", Message: "unknown field"}}, payload.Errors)
+
+	payload = send(current, types.TypeTimerAdjust, fmt.Sprintf(`{"timerId": 7, "delta": -90000, "reason": %q}`, strings.Repeat("x", 256)))
+	assert.Equal(t, types.ErrCodeInvalidArgument, payload.Code)
+	assert.Equal(t, []types.FieldError{
+		{Field: "delta", Message: "must be between -86400 and 86400 seconds"},
+		{Field: "reason", Message: "must be at most 255 characters"},
+	}, payload.Errors)
+
+	payload = send(legacy, types.TypeHintSent, fmt.Sprintf(`{"text": %q}`, strings.Repeat("x", 1001)))
+	assert.Equal(t, types.ErrCodeInvalidArgument, payload.Code)
+	assert.Equal(t, []types.FieldError{{Field: "text", Message: "must be at most 1000 characters"}}, payload.Errors)
+
+	mockService.AssertNotCalled(t, "CreateTimer", mock.Anything)
+	mockService.AssertNotCalled(t, "ModifyTimer", mock.Anything, mock.Anything, mock.Anything)
+	mockService.AssertNotCalled(t, "AdjustTimer", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpgradeRequiresToken(t *testing.T) {
+	server, handler, _ := setupWebSocketServer(t)
+	defer server.Close()
+
+	signer := authtest.NewSigner(t, clocktest.NewFake(time.Now()))
+	handler.SetVerifier(signer)
+
+	gameMaster, _ := signer.Sign(auth.Claims{Role: auth.RoleGameMaster, Sessions: []string{"*"}}, time.Hour)
+	display, _ := signer.Sign(auth.Claims{Role: auth.RoleCustomer, Sessions: []string{"room-a"}}, time.Hour)
+
+	dial := func(path, token string) int {
+		header := http.Header{}
+		if token != "" {
+			header.Set("Authorization", "Bearer "+token)
+		}
+		url := "ws" + strings.TrimPrefix(server.URL, "http") + path
+		ws, resp, err := websocket.DefaultDialer.Dial(url, header)
+		if err != nil {
+			// Rejections are problem details like any other HTTP error.
+			assert.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"), path)
+			var problem types.Problem
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&problem), path)
+			assert.Equal(t, resp.StatusCode, problem.Status, path)
+			assert.NotEmpty(t, problem.Code, path)
+			return resp.StatusCode
+		}
+		ws.Close()
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, dial("/ws/customer/room-a", ""))
+	assert.Equal(t, http.StatusUnauthorized, dial("/ws/customer/room-a", "forged"))
+	assert.Equal(t, http.StatusForbidden, dial("/ws/customer/room-b", display))
+	assert.Equal(t, http.StatusForbidden, dial("/ws/gamemaster/room-a", display))
+	assert.Equal(t, http.StatusSwitchingProtocols, dial("/ws/customer/room-a", display))
+	assert.Equal(t, http.StatusSwitchingProtocols, dial("/ws/customer/room-a", gameMaster))
+	assert.Equal(t, http.StatusSwitchingProtocols, dial("/ws/gamemaster/ops", gameMaster))
+}
+
+func TestCustomerCannotSendCommands(t *testing.T) {
+	server, _, _ := setupWebSocketServer(t)
+	defer server.Close()
+
+	ws, _ := dialWebSocketProtocol(t, server, "/ws/customer/room-a", "timer.v2")
+	defer ws.Close()
+
+	for _, messageType := range []types.MessageType{
+		types.TypeTimerCreate,
+		types.TypeTimerPause,
+		types.TypeTimerResume,
+		types.TypeTimerStop,
+		types.TypeTimerModify,
+		types.TypeTimerAdjust,
+	} {
+		assert.NoError(t, ws.WriteJSON(types.WebSocketMessage{Type: messageType, Payload: json.RawMessage(`{"timerId": 1}`)}))
+		payload := readErrorPayload(t, readReply(t, ws))
+		assert.Equal(t, types.ErrCodeForbidden, payload.Code, messageType)
+	}
+}
+
+func TestCreateTimerOutsideTokenScope(t *testing.T) {
+	server, handler, mockService := setupWebSocketServer(t)
+	defer server.Close()
+
+	signer := authtest.NewSigner(t, clocktest.NewFake(time.Now()))
+	handler.SetVerifier(signer)
+	token, _ := signer.Sign(auth.Claims{Role: auth.RoleGameMaster, Sessions: []string{"", "paris-*"}}, time.Hour)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	ws, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Authorization": {"Bearer " + token}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer ws.Close()
+
+	assert.NoError(t, ws.WriteJSON(types.WebSocketMessage{
+		Type:    types.TypeTimerCreate,
+		Payload: json.RawMessage(`{"sessionId": "lyon-1", "maxTime": 60}`),
+	}))
+	assert.Equal(t, types.ErrCodeForbidden, readErrorPayload(t, readReply(t, ws)).Code)
+
+	mockService.AssertNotCalled(t, "CreateTimer", mock.Anything)
+}
+
+func TestTimerCommandsOutsideTokenScope(t *testing.T) {
+	server, handler, mockService := setupWebSocketServer(t)
+	defer server.Close()
+
+	signer := authtest.NewSigner(t, clocktest.NewFake(time.Now()))
+	handler.SetVerifier(signer)
+	token, _ := signer.Sign(auth.Claims{Role: auth.RoleGameMaster, Sessions: []string{"", "paris-*"}}, time.Hour)
+
+	mockService.On("GetTimer", uint(7)).Return(&types.Timer{ID: 7, SessionID: "lyon-1", MaxTime: 60}, nil)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	dialer := websocket.Dialer{Subprotocols: []string{"timer.v2"}}
+	ws, _, err := dialer.Dial(url, http.Header{"Authorization": {"Bearer " + token}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer ws.Close()
+
+	tests := []struct {
+		messageType types.MessageType
+		payload     string
+	}{
+		{types.TypeTimerPause, `{"timerId": 7}`},
+		{types.TypeTimerResume, `{"timerId": 7}`},
+		{types.TypeTimerStop, `{"timerId": 7}`},
+		{types.TypeTimerModify, `{"timerId": 7, "maxTime": 120}`},
+		{types.TypeTimerAdjust, `{"timerId": 7, "delta": -60, "reason": "hint"}`},
+	}
+	for _, tt := range tests {
+		assert.NoError(t, ws.WriteJSON(types.WebSocketMessage{Type: tt.messageType, Payload: json.RawMessage(tt.payload)}))
+		assert.Equal(t, types.ErrCodeForbidden, readErrorPayload(t, readReply(t, ws)).Code, tt.messageType)
+	}
+
+	// The timer was loaded to check its session, and left alone.
+	for _, call := range mockService.Calls {
+		assert.Contains(t, []string{"GetTimer", "GetLiveTimers"}, call.Method)
+	}
+}
+
+func TestDisplayTokenFromQueryOrSubprotocol(t *testing.T) {
+	server, handler, _ := setupWebSocketServer(t)
+	defer server.Close()
+
+	signer := authtest.NewSigner(t, clocktest.NewFake(time.Now()))
+	handler.SetVerifier(signer)
+	token, _ := signer.Sign(auth.Claims{Role: auth.RoleCustomer, Sessions: []string{"room-a"}}, time.Hour)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/customer/room-a"
+
+	ws, _, err := websocket.DefaultDialer.Dial(url+"?access_token="+token, nil)
+	if assert.NoError(t, err) {
+		ws.Close()
+	}
+
+	// The token subprotocol is never echoed back; a protocol version is.
+	dialer := websocket.Dialer{Subprotocols: []string{"timer.v2", auth.TokenSubprotocolPrefix + token}}
+	ws, _, err = dialer.Dial(url, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "timer.v2", ws.Subprotocol())
+		ws.Close()
+	}
+}